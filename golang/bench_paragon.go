@@ -5,6 +5,11 @@
 //   go run ./bench_paragon.go               # verbose (prints outputs & per-index diffs)
 //   go run ./bench_paragon.go --quiet       # quiet summary only
 //   go run ./bench_paragon.go --csv out.csv # write CSV rows (append) in quiet or verbose
+//   go run ./bench_paragon.go --iters 200     # timed forwards per backend (default 50)
+//   go run ./bench_paragon.go --first-forward # report cold first GPU forward vs steady state
+//   go run ./bench_paragon.go --precision 6   # significant digits in printed/exported vectors
+//   go run ./bench_paragon.go --csv out.csv --decimal-sep ,  # 3,142;... for comma-decimal locales
+//   go run ./bench_paragon.go --json out.json # full rows + output vectors (overwrites)
 //
 // Backend hint (optional):
 //   WGPU_BACKEND=vulkan go run ./bench_paragon.go --quiet
@@ -20,10 +25,14 @@ package main
 
 import (
 	"encoding/csv"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"math"
 	"os"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -133,6 +142,63 @@ func forwardTimed(nn *paragon.Network[float32], input [][]float64) forwardOut {
 	return forwardOut{ms: elapsed, raw: out, flat: out}
 }
 
+type latencyStats struct {
+	Mean, Std, P50, P95, Min, Max float64
+}
+
+// percentile on an ascending slice, nearest-rank
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	return sorted[i]
+}
+
+func summarize(ms []float64) latencyStats {
+	if len(ms) == 0 {
+		return latencyStats{}
+	}
+	sorted := append([]float64(nil), ms...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range ms {
+		sum += v
+	}
+	mean := sum / float64(len(ms))
+	var sq float64
+	for _, v := range ms {
+		sq += (v - mean) * (v - mean)
+	}
+	return latencyStats{
+		Mean: mean,
+		Std:  math.Sqrt(sq / float64(len(ms))),
+		P50:  percentile(sorted, 50),
+		P95:  percentile(sorted, 95),
+		Min:  sorted[0],
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// time iters forwards (warmup already done by the caller); returns stats and the last output
+func forwardIters(nn *paragon.Network[float32], input [][]float64, iters int) (latencyStats, forwardOut) {
+	ms := make([]float64, 0, iters)
+	var last forwardOut
+	for i := 0; i < iters; i++ {
+		last = forwardTimed(nn, input)
+		ms = append(ms, last.ms)
+	}
+	return summarize(ms), last
+}
+
+func printStats(label string, st latencyStats) {
+	fmt.Printf("%s ⏱ mean=%.3f ms  std=%.3f  p50=%.3f  p95=%.3f  min=%.3f  max=%.3f\n",
+		label, st.Mean, st.Std, st.P50, st.P95, st.Min, st.Max)
+}
+
 func diffStats(a, b []float64) (mae, maxd float64, n int) {
 	n = min(len(a), len(b))
 	if n == 0 {
@@ -156,18 +222,28 @@ func min(a, b int) int {
 	return b
 }
 
+// significant digits for printed/exported output vectors (0 = default formatting);
+// diff/MAE math always uses full precision
+var precision int
+
+func fmtValue(x float64) string {
+	if precision > 0 {
+		return strconv.FormatFloat(x, 'g', precision, 64)
+	}
+	// compact-ish scientific for tiny/huge values
+	if (math.Abs(x) > 1e4) || (math.Abs(x) > 0 && math.Abs(x) < 1e-3) {
+		return fmt.Sprintf("%.6g", x)
+	}
+	return fmt.Sprintf("%.9g", x)
+}
+
 func printVector(label string, v []float64) {
 	fmt.Printf("%s: [", label)
 	for i, x := range v {
 		if i > 0 {
 			fmt.Print(", ")
 		}
-		// compact-ish scientific for tiny/huge values
-		if (math.Abs(x) > 1e4) || (math.Abs(x) > 0 && math.Abs(x) < 1e-3) {
-			fmt.Printf("%.6g", x)
-		} else {
-			fmt.Printf("%.9g", x)
-		}
+		fmt.Print(fmtValue(x))
 	}
 	fmt.Println("]")
 }
@@ -181,7 +257,10 @@ type benchRow struct {
 	Speedup  float64
 	MAE      float64
 	Max      float64
+	CPU      latencyStats
+	GPU      latencyStats
 	InitMS   float64
+	FirstMS  float64 // first GPU forward after init, includes pipeline compilation
 	Adapter  string
 	Enabled  bool
 	OutCPU   []float64
@@ -189,7 +268,7 @@ type benchRow struct {
 	InputHex string // optional placeholder if you ever serialize inputs
 }
 
-func runCase(spec caseShape, quiet bool) benchRow {
+func runCase(spec caseShape, quiet, firstForward bool, iters int) benchRow {
 	fmt.Printf("\n=== %s (%s) ===\n", spec.ID, shapeStr(spec))
 	seed := uint32(123)
 	x := fixedRow784(seed)
@@ -202,11 +281,11 @@ func runCase(spec caseShape, quiet bool) benchRow {
 	}
 	nn.Debug = false
 
-	// CPU warmup
+	// CPU warmup (discarded), then timed iterations
 	nn.WebGPUNative = false
 	nn.Forward(x)
 	_ = nn.ExtractOutput()
-	cpu := forwardTimed(nn, x)
+	cpuSt, cpu := forwardIters(nn, x, iters)
 
 	// GPU init
 	nn.WebGPUNative = true
@@ -222,21 +301,24 @@ func runCase(spec caseShape, quiet bool) benchRow {
 	}
 	fmt.Printf("GPU init: %s  in %.2f ms  enabled=%s\n", adapter, initMS, map[bool]string{true: "yes", false: "no"}[enabled])
 
-	// Warmup on GPU (or CPU fallback)
-	nn.Forward(x)
-	_ = nn.ExtractOutput()
-	gpu := forwardTimed(nn, x)
+	// Warmup on GPU (or CPU fallback); timed since it pays pipeline compilation
+	// and excluded from the steady-state stats
+	first := forwardTimed(nn, x)
+	gpuSt, gpu := forwardIters(nn, x, iters)
 
 	mae, maxd, n := diffStats(cpu.flat, gpu.flat)
 
 	// logs
-	fmt.Printf("CPU  ⏱ %.3f ms\n", cpu.ms)
-	fmt.Printf("GPU  ⏱ %.3f ms\n", gpu.ms)
+	printStats("CPU ", cpuSt)
+	printStats("GPU ", gpuSt)
+	if firstForward {
+		fmt.Printf("GPU first ⏱ %.3f ms (cold)  steady p50 ⏱ %.3f ms  penalty %.3f ms\n", first.ms, gpuSt.P50, first.ms-gpuSt.P50)
+	}
 	speed := math.Inf(1)
-	if gpu.ms > 0 {
-		speed = cpu.ms / gpu.ms
+	if gpuSt.P50 > 0 {
+		speed = cpuSt.P50 / gpuSt.P50
 	}
-	fmt.Printf("Speedup: %.2fx\n", speed)
+	fmt.Printf("Speedup (p50, %d iters): %.2fx\n", iters, speed)
 	fmt.Printf("Δ(CPU vs GPU)  mae=%.2E  max=%.2E  (n=%d)\n", mae, maxd, n)
 
 	if !quiet {
@@ -248,6 +330,10 @@ func runCase(spec caseShape, quiet bool) benchRow {
 			fmt.Printf("%-4s| %-22s | %-22s | %-s\n", "Idx", "CPU", "GPU", "Δ")
 			fmt.Println("----+------------------------+------------------------+------------------")
 			for i := 0; i < 10; i++ {
+				if precision > 0 {
+					fmt.Printf("%3d | %22s | %22s | %16.*e\n", i, fmtValue(cpu.raw[i]), fmtValue(gpu.raw[i]), precision-1, math.Abs(cpu.raw[i]-gpu.raw[i]))
+					continue
+				}
 				fmt.Printf("%3d | %22.16g | %22.16g | %16.9e\n", i, cpu.raw[i], gpu.raw[i], math.Abs(cpu.raw[i]-gpu.raw[i]))
 			}
 		}
@@ -262,12 +348,15 @@ func runCase(spec caseShape, quiet bool) benchRow {
 		ID:      spec.ID,
 		Shape:   shapeStr(spec),
 		EstMB:   estimateVramMB(spec),
-		CPUms:   cpu.ms,
-		GPUms:   gpu.ms,
+		CPUms:   cpuSt.Mean,
+		GPUms:   gpuSt.Mean,
+		CPU:     cpuSt,
+		GPU:     gpuSt,
 		Speedup: speed,
 		MAE:     mae,
 		Max:     maxd,
 		InitMS:  initMS,
+		FirstMS: first.ms,
 		Adapter: adapter,
 		Enabled: enabled,
 		OutCPU:  cpu.raw,
@@ -275,6 +364,18 @@ func runCase(spec caseShape, quiet bool) benchRow {
 	}
 }
 
+// decimal separator for CSV numbers; "," switches the field delimiter to ";"
+// so spreadsheets in comma-decimal locales import the file cleanly
+var decimalSep = "."
+
+func csvNum(format string, v float64) string {
+	s := fmt.Sprintf(format, v)
+	if decimalSep != "." {
+		s = strings.Replace(s, ".", decimalSep, 1)
+	}
+	return s
+}
+
 func appendCSV(path string, rows []benchRow) error {
 	newFile := false
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -286,21 +387,29 @@ func appendCSV(path string, rows []benchRow) error {
 	}
 	defer f.Close()
 	w := csv.NewWriter(f)
+	if decimalSep == "," {
+		w.Comma = ';'
+	}
 	if newFile {
-		_ = w.Write([]string{"id", "shape", "estMB", "cpu_ms", "gpu_ms", "speedup", "mae", "max", "gpu_init_ms", "adapter"})
+		_ = w.Write([]string{"id", "shape", "estMB", "cpu_ms", "gpu_ms", "speedup", "mae", "max", "gpu_init_ms", "adapter", "gpu_first_ms", "cpu_p50", "cpu_p95", "gpu_p50", "gpu_p95"})
 	}
 	for _, r := range rows {
 		rec := []string{
 			r.ID,
 			r.Shape,
-			fmt.Sprintf("%.2f", r.EstMB),
-			fmt.Sprintf("%.3f", r.CPUms),
-			fmt.Sprintf("%.3f", r.GPUms),
-			fmt.Sprintf("%.2f", r.Speedup),
-			fmt.Sprintf("%.2E", r.MAE),
-			fmt.Sprintf("%.2E", r.Max),
-			fmt.Sprintf("%.2f", r.InitMS),
+			csvNum("%.2f", r.EstMB),
+			csvNum("%.3f", r.CPUms),
+			csvNum("%.3f", r.GPUms),
+			csvNum("%.2f", r.Speedup),
+			csvNum("%.2E", r.MAE),
+			csvNum("%.2E", r.Max),
+			csvNum("%.2f", r.InitMS),
 			r.Adapter,
+			csvNum("%.3f", r.FirstMS),
+			csvNum("%.3f", r.CPU.P50),
+			csvNum("%.3f", r.CPU.P95),
+			csvNum("%.3f", r.GPU.P50),
+			csvNum("%.3f", r.GPU.P95),
 		}
 		_ = w.Write(rec)
 	}
@@ -308,17 +417,70 @@ func appendCSV(path string, rows []benchRow) error {
 	return w.Error()
 }
 
+type benchEnv struct {
+	WGPUBackend string `json:"wgpu_backend"`
+	GOOS        string `json:"goos"`
+	GOARCH      string `json:"goarch"`
+	Timestamp   string `json:"timestamp"`
+	Iters       int    `json:"iters"`
+}
+
+type benchReport struct {
+	Env  benchEnv   `json:"env"`
+	Rows []benchRow `json:"rows"`
+}
+
+// writeJSON overwrites path with the full rows, output vectors included, so
+// runs from different machines can be diffed directly.
+func writeJSON(path string, rows []benchRow, iters int) error {
+	rep := benchReport{
+		Env: benchEnv{
+			WGPUBackend: os.Getenv("WGPU_BACKEND"),
+			GOOS:        runtime.GOOS,
+			GOARCH:      runtime.GOARCH,
+			Timestamp:   time.Now().UTC().Format(time.RFC3339),
+			Iters:       iters,
+		},
+		Rows: make([]benchRow, len(rows)),
+	}
+	for i, r := range rows {
+		// encoding/json rejects ±Inf (speedup when the GPU p50 rounds to 0)
+		if math.IsInf(r.Speedup, 0) || math.IsNaN(r.Speedup) {
+			r.Speedup = 0
+		}
+		rep.Rows[i] = r
+	}
+	b, err := json.MarshalIndent(rep, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0o644)
+}
+
 func main() {
 	quiet := flag.Bool("quiet", false, "suppress per-index vectors")
 	csvPath := flag.String("csv", "", "append results to CSV file")
+	jsonPath := flag.String("json", "", "write full results (with output vectors) to JSON file, overwriting it")
+	flag.StringVar(&decimalSep, "decimal-sep", ".", `CSV decimal separator: "." or "," (comma also switches the delimiter to ";")`)
+	flag.IntVar(&precision, "precision", 0, "significant digits for printed/exported output vectors (0 = default)")
+	iters := flag.Int("iters", 50, "timed forwards per backend (after warmup)")
+	firstForward := flag.Bool("first-forward", false, "report cold first GPU forward vs steady-state")
 	flag.Parse()
+	if *iters < 1 {
+		fmt.Println("--iters must be >= 1")
+		os.Exit(2)
+	}
+	if decimalSep != "." && decimalSep != "," {
+		fmt.Println("--decimal-sep must be \".\" or \",\"")
+		os.Exit(2)
+	}
 
 	fmt.Println("Simple Paragon CPU vs GPU Benchmark (Go)")
 	fmt.Println("========================================")
 
 	results := make([]benchRow, 0, len(mnistZoo))
 	for _, spec := range mnistZoo {
-		r := runCase(spec, *quiet)
+		r := runCase(spec, *quiet, *firstForward, *iters)
 		results = append(results, r)
 	}
 
@@ -329,4 +491,11 @@ func main() {
 			fmt.Println("💾 CSV appended →", *csvPath)
 		}
 	}
+	if *jsonPath != "" {
+		if err := writeJSON(*jsonPath, results, *iters); err != nil {
+			fmt.Println("JSON write error:", err)
+		} else {
+			fmt.Println("💾 JSON written →", *jsonPath)
+		}
+	}
 }
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// TestWriteJSONRoundTrip checks --json output decodes back into the report
+// with the output vectors intact.
+func TestWriteJSONRoundTrip(t *testing.T) {
+	rows := []benchRow{
+		{
+			ID: "S1", Shape: "784→64→10", CPUms: 0.5, GPUms: 0.25, Speedup: 2, MAE: 1e-7, Max: 3e-7,
+			CPU: latencyStats{Mean: 0.5, P50: 0.5}, Adapter: "test", Enabled: true,
+			OutCPU: []float64{0.1, 0.2, 0.7}, OutGPU: []float64{0.1000001, 0.2, 0.6999999},
+		},
+		{ID: "S2", Speedup: math.Inf(1), OutCPU: []float64{1e-300, -0.5}},
+	}
+	path := filepath.Join(t.TempDir(), "out.json")
+	if err := writeJSON(path, rows, 7); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rep benchReport
+	if err := json.Unmarshal(b, &rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Env.Iters != 7 || len(rep.Rows) != len(rows) {
+		t.Fatalf("env %+v with %d rows, want 7 iters and %d rows", rep.Env, len(rep.Rows), len(rows))
+	}
+	for i, r := range rep.Rows {
+		if !slices.Equal(r.OutCPU, rows[i].OutCPU) || !slices.Equal(r.OutGPU, rows[i].OutGPU) {
+			t.Errorf("%s: vectors %v / %v, want %v / %v", r.ID, r.OutCPU, r.OutGPU, rows[i].OutCPU, rows[i].OutGPU)
+		}
+	}
+	if r := rep.Rows[0]; r.ID != "S1" || r.Speedup != 2 || r.CPU != rows[0].CPU || !r.Enabled {
+		t.Errorf("row 0 = %+v", r)
+	}
+	if s := rep.Rows[1].Speedup; s != 0 {
+		t.Errorf("infinite speedup written as %v, want 0", s)
+	}
+}
@@ -1,8 +1,7 @@
-module main
+module bench_paragon
 
 go 1.24.3
 
-require (
-	github.com/openfluke/paragon/v3 v3.1.4 // indirect
-	github.com/openfluke/webgpu v0.0.1 // indirect
-)
+require github.com/openfluke/paragon/v3 v3.1.4
+
+require github.com/openfluke/webgpu v0.0.1 // indirect
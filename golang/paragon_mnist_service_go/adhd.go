@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ADHDBucket struct {
+	RangeMin float64 `json:"range_min"`
+	RangeMax float64 `json:"range_max"`
+	Count    int     `json:"count"`
+}
+
+type ADHDReport struct {
+	Backend    string                `json:"backend"`
+	Samples    int                   `json:"samples"`
+	Score      float64               `json:"score"`
+	Total      int                   `json:"total"`
+	Failures   int                   `json:"failures"`
+	Buckets    map[string]ADHDBucket `json:"buckets"`
+	LatencySec float64               `json:"latency_sec"`
+}
+
+// handleADHD scores the DATASET test split and feeds label vs. argmax pairs to
+// Paragon's ADHD evaluation. ?limit=N evaluates the first N test images.
+func handleADHD(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	backend := strings.ToLower(strings.TrimSpace(q.Get("backend")))
+	limit := 0
+	if v := strings.TrimSpace(q.Get("limit")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			http.Error(w, "bad ?limit=", http.StatusBadRequest)
+			return
+		}
+		limit = n
+	}
+	backend, h, err := pickBackend(strings.TrimSpace(q.Get("model")), backend)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	imgRaw, labRaw, err := ensureTestIDX()
+	if err != nil {
+		http.Error(w, activeDataset.name+" test idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	images, err := cachedIDX(imgRaw, idxMagicImages)
+	if err != nil {
+		http.Error(w, activeDataset.name+" test idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	labels, err := cachedIDX(labRaw, idxMagicLabels)
+	if err != nil {
+		http.Error(w, activeDataset.name+" test idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if in := h.Input(); in.W != images.cols || in.H != images.rows {
+		http.Error(w, fmt.Sprintf("model expects %dx%d input; IDX images are %dx%d", in.W, in.H, images.cols, images.rows), http.StatusBadRequest)
+		return
+	}
+
+	n := min(images.Len(), labels.Len())
+	if limit > 0 {
+		n = min(n, limit)
+	}
+	start := time.Now()
+	expected := make([]float64, 0, n)
+	actual := make([]float64, 0, n)
+	for i := 0; i < n; i++ {
+		img, err := images.Image(i)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("image %d: %v", i, err), http.StatusInternalServerError)
+			return
+		}
+		lbl, err := labels.Label(i)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("label %d: %v", i, err), http.StatusInternalServerError)
+			return
+		}
+		out, err := forwardProbs(h, img)
+		if err != nil {
+			http.Error(w, "forward failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		expected = append(expected, float64(lbl))
+		actual = append(actual, float64(out.Pred))
+	}
+	perf := h.ADHD(expected, actual)
+
+	rep := ADHDReport{
+		Backend:    backend,
+		Samples:    n,
+		Score:      round6(perf.Score),
+		Total:      perf.Total,
+		Failures:   perf.Failures,
+		Buckets:    make(map[string]ADHDBucket, len(perf.Buckets)),
+		LatencySec: round6(time.Since(start).Seconds()),
+	}
+	// per-sample indices are left out; they can run to 10k entries
+	for k, b := range perf.Buckets {
+		rep.Buckets[k] = ADHDBucket{RangeMin: b.RangeMin, RangeMax: b.RangeMax, Count: b.Count}
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
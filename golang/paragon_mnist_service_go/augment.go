@@ -0,0 +1,154 @@
+package main
+
+import (
+	"math"
+	"math/rand/v2"
+)
+
+// Augment perturbs training images on the fly so the network sees a slightly
+// different digit every epoch. Geometric steps (shift, rotation, elastic
+// distortion) are combined into one displacement field and sampled
+// bilinearly; noise is added last. The zero value leaves images alone.
+type Augment struct {
+	Prob         float64 `json:"prob"`          // chance each sample is augmented
+	Shift        float64 `json:"shift"`         // max translation in pixels, per axis
+	Rotate       float64 `json:"rotate"`        // max rotation in degrees, either way
+	Elastic      float64 `json:"elastic"`       // elastic distortion strength in pixels (alpha), 0 = off
+	ElasticSigma float64 `json:"elastic_sigma"` // smoothness of the distortion field
+	Noise        float64 `json:"noise"`         // std dev of Gaussian pixel noise
+}
+
+func (a Augment) enabled() bool {
+	return a.Prob > 0 && (a.Shift > 0 || a.Rotate > 0 || a.Elastic > 0 || a.Noise > 0)
+}
+
+func (a Augment) validate() error {
+	switch {
+	case !(a.Prob >= 0 && a.Prob <= 1):
+		return newFieldError("augment.prob", "invalid", "augment.prob must be in [0,1]")
+	case !(a.Shift >= 0 && a.Shift <= 8):
+		return newFieldError("augment.shift", "invalid", "augment.shift must be in [0,8]")
+	case !(a.Rotate >= 0 && a.Rotate <= 45):
+		return newFieldError("augment.rotate", "invalid", "augment.rotate must be in [0,45]")
+	case !(a.Elastic >= 0 && a.Elastic <= 10):
+		return newFieldError("augment.elastic", "invalid", "augment.elastic must be in [0,10]")
+	case a.Elastic > 0 && !(a.ElasticSigma > 0 && a.ElasticSigma <= 10):
+		return newFieldError("augment.elastic_sigma", "invalid", "augment.elastic_sigma must be in (0,10]")
+	case !(a.Noise >= 0 && a.Noise <= 0.5):
+		return newFieldError("augment.noise", "invalid", "augment.noise must be in [0,0.5]")
+	}
+	return nil
+}
+
+// apply returns an augmented copy of img, or img itself when the sample is
+// skipped.
+func (a Augment) apply(img [][]float64, rng *rand.Rand) [][]float64 {
+	if !a.enabled() || rng.Float64() >= a.Prob {
+		return img
+	}
+	h, w := len(img), len(img[0])
+	dx, dy := 2*rng.Float64()-1, 2*rng.Float64()-1
+	dx, dy = dx*a.Shift, dy*a.Shift
+	theta := (2*rng.Float64() - 1) * a.Rotate * math.Pi / 180
+	sin, cos := math.Sincos(theta)
+	var ex, ey [][]float64
+	if a.Elastic > 0 {
+		ex, ey = elasticField(w, h, a.Elastic, a.ElasticSigma, rng)
+	}
+
+	cx, cy := float64(w-1)/2, float64(h-1)/2
+	out := make([][]float64, h)
+	for y := range out {
+		out[y] = make([]float64, w)
+		for x := range out[y] {
+			// inverse map: where this output pixel comes from in img
+			px, py := float64(x)-dx-cx, float64(y)-dy-cy
+			sx, sy := cos*px+sin*py+cx, -sin*px+cos*py+cy
+			if ex != nil {
+				sx, sy = sx+ex[y][x], sy+ey[y][x]
+			}
+			v := bilinear(img, sx, sy)
+			if a.Noise > 0 {
+				v = min(max(v+rng.NormFloat64()*a.Noise, 0), 1)
+			}
+			out[y][x] = v
+		}
+	}
+	return out
+}
+
+// elasticField is Simard et al.'s distortion: uniform random displacements
+// smoothed with a Gaussian of sigma and scaled by alpha.
+func elasticField(w, h int, alpha, sigma float64, rng *rand.Rand) ([][]float64, [][]float64) {
+	field := func() [][]float64 {
+		f := make([][]float64, h)
+		for y := range f {
+			f[y] = make([]float64, w)
+			for x := range f[y] {
+				f[y][x] = 2*rng.Float64() - 1
+			}
+		}
+		f = gaussianBlur(f, sigma)
+		for _, row := range f {
+			for x := range row {
+				row[x] *= alpha
+			}
+		}
+		return f
+	}
+	return field(), field()
+}
+
+// gaussianBlur is a separable blur with zero padding.
+func gaussianBlur(img [][]float64, sigma float64) [][]float64 {
+	r := int(math.Ceil(3 * sigma))
+	k := make([]float64, 2*r+1)
+	var sum float64
+	for i := range k {
+		d := float64(i - r)
+		k[i] = math.Exp(-d * d / (2 * sigma * sigma))
+		sum += k[i]
+	}
+	for i := range k {
+		k[i] /= sum
+	}
+	h, w := len(img), len(img[0])
+	pass := func(src [][]float64, horiz bool) [][]float64 {
+		dst := make([][]float64, h)
+		for y := range dst {
+			dst[y] = make([]float64, w)
+			for x := range dst[y] {
+				var v float64
+				for i, kv := range k {
+					sx, sy := x, y
+					if horiz {
+						sx += i - r
+					} else {
+						sy += i - r
+					}
+					if sx >= 0 && sx < w && sy >= 0 && sy < h {
+						v += kv * src[sy][sx]
+					}
+				}
+				dst[y][x] = v
+			}
+		}
+		return dst
+	}
+	return pass(pass(img, true), false)
+}
+
+// bilinear samples img at a fractional position, reading 0 outside it.
+func bilinear(img [][]float64, x, y float64) float64 {
+	x0, y0 := int(math.Floor(x)), int(math.Floor(y))
+	tx, ty := x-float64(x0), y-float64(y0)
+	at := func(x, y int) float64 {
+		if y < 0 || y >= len(img) || x < 0 || x >= len(img[y]) {
+			return 0
+		}
+		return img[y][x]
+	}
+	top := at(x0, y0)*(1-tx) + at(x0+1, y0)*tx
+	bot := at(x0, y0+1)*(1-tx) + at(x0+1, y0+1)*tx
+	return top*(1-ty) + bot*ty
+}
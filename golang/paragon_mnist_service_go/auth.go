@@ -0,0 +1,95 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// AUTH_API_KEYS (auth.api_keys in CONFIG_FILE) is a comma-separated list of
+// keys clients send as "Authorization: Bearer <key>" or X-API-Key; empty
+// leaves the API open. AUTH_ADMIN_KEYS guards admin endpoints (reloads,
+// uploads, /admin/*), which refuse every request while it is empty. Admin keys
+// are API keys too.
+var (
+	apiKeys   = splitKeys(getEnv("AUTH_API_KEYS", ""))
+	adminKeys = splitKeys(getEnv("AUTH_ADMIN_KEYS", ""))
+)
+
+// authOpen are paths served without a key: probes and the API docs.
+var authOpen = map[string]bool{"/": true, "/health": true, "/livez": true, "/readyz": true}
+
+func splitKeys(s string) []string {
+	var keys []string
+	for _, k := range strings.Split(s, ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// requestKey is the key r presents, if any.
+func requestKey(r *http.Request) string {
+	if k, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return strings.TrimSpace(k)
+	}
+	return strings.TrimSpace(r.Header.Get("X-API-Key"))
+}
+
+// keyIn reports whether key is one of keys, in constant time per key.
+func keyIn(key string, keys []string) bool {
+	found := false
+	for _, k := range keys {
+		if subtle.ConstantTimeCompare([]byte(key), []byte(k)) == 1 {
+			found = true
+		}
+	}
+	return key != "" && found
+}
+
+func validAPIKey(key string) bool {
+	return len(apiKeys) == 0 || keyIn(key, apiKeys) || keyIn(key, adminKeys)
+}
+
+// withAuth rejects requests without a valid API key when AUTH_API_KEYS is set.
+func withAuth(next http.Handler) http.Handler {
+	if len(apiKeys) == 0 {
+		return next
+	}
+	infof("🔑 API key auth enabled (%d keys)", len(apiKeys))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p := strings.TrimPrefix(r.URL.Path, apiPrefix)
+		if authOpen[r.URL.Path] || p == "/openapi.json" || p == "/docs" || validAPIKey(requestKey(r)) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="paragon"`)
+		http.Error(w, "missing or invalid API key", http.StatusUnauthorized)
+	})
+}
+
+// requireAdmin answers 401/403 and returns false unless r carries an admin key.
+func requireAdmin(w http.ResponseWriter, r *http.Request) bool {
+	switch key := requestKey(r); {
+	case len(adminKeys) == 0:
+		http.Error(w, "admin endpoints disabled (set AUTH_ADMIN_KEYS)", http.StatusForbidden)
+	case key == "":
+		w.Header().Set("WWW-Authenticate", `Bearer realm="paragon-admin"`)
+		http.Error(w, "admin key required", http.StatusUnauthorized)
+	case !keyIn(key, adminKeys):
+		http.Error(w, "invalid admin key", http.StatusForbidden)
+	default:
+		return true
+	}
+	return false
+}
+
+// adminOnly wraps an admin endpoint in requireAdmin.
+func adminOnly(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if requireAdmin(w, r) {
+			next(w, r)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BENCHMARK_ENABLED turns on POST /benchmark. It runs on the serving handles,
+// so live requests queue behind it; BENCHMARK_MAX_N caps the forwards per
+// backend (default 5000).
+var (
+	benchmarkOn   = getEnvBool("BENCHMARK_ENABLED", false)
+	benchmarkMaxN = max(getEnvInt("BENCHMARK_MAX_N", 5000), 1)
+)
+
+type BenchmarkRequest struct {
+	N           int      `json:"n"`           // timed forwards per backend, default 100
+	Warmup      *int     `json:"warmup"`      // untimed forwards first, default 5
+	Concurrency int      `json:"concurrency"` // parallel callers, default 1
+	Backends    []string `json:"backends"`    // "cpu", "gpu" and/or "cpu-int8"; cpu and gpu (if available) when empty
+	Model       string   `json:"model,omitempty"`
+}
+
+// BenchmarkResult is one backend's run. Latencies are per forward, QPS is
+// forwards over wall time, so it rises with concurrency up to the pool size.
+type BenchmarkResult struct {
+	Backend     string  `json:"backend"`
+	N           int     `json:"n"`
+	Concurrency int     `json:"concurrency"`
+	PoolSize    int     `json:"pool_size"`
+	MeanMS      float64 `json:"mean_ms"`
+	StdMS       float64 `json:"std_ms"`
+	P50MS       float64 `json:"p50_ms"`
+	P95MS       float64 `json:"p95_ms"`
+	P99MS       float64 `json:"p99_ms"`
+	MinMS       float64 `json:"min_ms"`
+	MaxMS       float64 `json:"max_ms"`
+	WallSec     float64 `json:"wall_sec"`
+	QPS         float64 `json:"qps"`
+	Error       string  `json:"error,omitempty"`
+}
+
+func handleBenchmark(w http.ResponseWriter, r *http.Request) {
+	if !benchmarkOn {
+		http.Error(w, "benchmark disabled (set BENCHMARK_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	var req BenchmarkRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if req.N == 0 {
+		req.N = 100
+	}
+	warmup := 5
+	if req.Warmup != nil {
+		warmup = *req.Warmup
+	}
+	if req.Concurrency == 0 {
+		req.Concurrency = 1
+	}
+	switch {
+	case req.N < 0 || req.N > benchmarkMaxN:
+		writeError(w, newFieldError("n", "invalid", fmt.Sprintf("n must be 1..%d", benchmarkMaxN)))
+		return
+	case warmup < 0 || warmup > benchmarkMaxN:
+		writeError(w, newFieldError("warmup", "invalid", fmt.Sprintf("warmup must be 0..%d", benchmarkMaxN)))
+		return
+	case req.Concurrency < 0 || req.Concurrency > 64:
+		writeError(w, newFieldError("concurrency", "invalid", "concurrency must be 1..64"))
+		return
+	}
+
+	model := strings.TrimSpace(req.Model)
+	cpu, _, ok, err := modelHandles(model)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	backends := make([]string, len(req.Backends))
+	for i, b := range req.Backends {
+		if b = strings.ToLower(strings.TrimSpace(b)); b != "cpu" && b != "gpu" && b != backendInt8 {
+			writeError(w, newFieldError("backends", "invalid", "bad backend "+b+" (want cpu, gpu or cpu-int8)"))
+			return
+		}
+		backends[i] = b
+	}
+	if len(backends) == 0 {
+		backends = []string{"cpu"}
+		if ok {
+			backends = append(backends, "gpu")
+		}
+	}
+	img := canaryInput(cpu.Input())
+	results := make([]BenchmarkResult, 0, len(backends))
+	for _, b := range backends {
+		h, err := pickModelHandle(model, b)
+		if err != nil {
+			results = append(results, BenchmarkResult{Backend: b, Error: err.Error()})
+			continue
+		}
+		res := runBenchmark(r, h, img, req.N, warmup, req.Concurrency)
+		res.Backend = b
+		results = append(results, res)
+		if err := r.Context().Err(); err != nil {
+			http.Error(w, ctxError(err).Error(), httpStatus(ctxError(err)))
+			return
+		}
+	}
+	infof("⏱️  benchmark n=%d concurrency=%d on %v", req.N, req.Concurrency, backends)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"model":   model,
+		"input":   fmt.Sprintf("%dx%d", cpu.Input().W, cpu.Input().H),
+		"warmup":  warmup,
+		"results": results,
+	})
+}
+
+// runBenchmark times n forwards of img on h from concurrency callers, after
+// warmup untimed ones.
+func runBenchmark(r *http.Request, h *ParagonHandle, img [][]float64, n, warmup, concurrency int) BenchmarkResult {
+	for i := 0; i < warmup && r.Context().Err() == nil; i++ {
+		h.Infer(img)
+	}
+	ms := make([]float64, n)
+	next := make(chan int)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for c := 0; c < concurrency; c++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				t := time.Now()
+				h.Infer(img)
+				ms[i] = float64(time.Since(t).Microseconds()) / 1000
+			}
+		}()
+	}
+	sent := 0
+feed:
+	for ; sent < n; sent++ {
+		select {
+		case next <- sent:
+		case <-r.Context().Done():
+			break feed
+		}
+	}
+	close(next)
+	wg.Wait()
+	wall := time.Since(start).Seconds()
+
+	n, ms = sent, ms[:sent]
+	res := BenchmarkResult{N: n, Concurrency: concurrency, PoolSize: h.Stats().Size, WallSec: round6(wall)}
+	if n == 0 {
+		return res
+	}
+	sorted := append([]float64(nil), ms...)
+	sort.Float64s(sorted)
+	var sum, sq float64
+	for _, v := range ms {
+		sum += v
+	}
+	mean := sum / float64(n)
+	for _, v := range ms {
+		sq += (v - mean) * (v - mean)
+	}
+	res.MeanMS = round6(mean)
+	res.StdMS = round6(math.Sqrt(sq / float64(n)))
+	res.P50MS = round6(percentile(sorted, 50))
+	res.P95MS = round6(percentile(sorted, 95))
+	res.P99MS = round6(percentile(sorted, 99))
+	res.MinMS, res.MaxMS = round6(sorted[0]), round6(sorted[n-1])
+	if wall > 0 {
+		res.QPS = round6(float64(n) / wall)
+	}
+	return res
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// inputCache holds decoded images keyed by content hash so repeat requests
+// (other backends, other params) skip image decode. INPUT_CACHE_SIZE=0 disables it.
+var inputCache = newLRU[decodedInput](getEnvInt("INPUT_CACHE_SIZE", 0), 0)
+
+// resultCache holds finished predictions keyed by content hash, input size,
+// backend and handle, so a reload or model switch never serves stale
+// results. RESULT_CACHE_SIZE=0 (default) disables it; entries expire after
+// RESULT_CACHE_TTL (default 5m, 0 = never).
+var resultCache = newLRU[*ProbResult](getEnvInt("RESULT_CACHE_SIZE", 0), getEnvDuration("RESULT_CACHE_TTL", 5*time.Minute))
+
+type decodedInput struct {
+	img  [][]float64
+	warn string // channel-conversion warning, if any
+}
+
+type lruEntry[V any] struct {
+	key     string
+	val     V
+	expires time.Time // zero when the cache has no TTL
+}
+
+type lru[V any] struct {
+	mu     sync.Mutex
+	cap    int
+	ttl    time.Duration
+	ll     *list.List
+	items  map[string]*list.Element
+	hits   uint64
+	misses uint64
+}
+
+func newLRU[V any](capacity int, ttl time.Duration) *lru[V] {
+	return &lru[V]{cap: capacity, ttl: ttl, ll: list.New(), items: map[string]*list.Element{}}
+}
+
+// get returns the cached value; callers must not mutate it.
+func (c *lru[V]) get(key string) (V, bool) {
+	var zero V
+	if c.cap <= 0 {
+		return zero, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.items[key]; ok {
+		e := el.Value.(*lruEntry[V])
+		if e.expires.IsZero() || time.Now().Before(e.expires) {
+			c.ll.MoveToFront(el)
+			c.hits++
+			return e.val, true
+		}
+		c.ll.Remove(el)
+		delete(c.items, key)
+	}
+	c.misses++
+	return zero, false
+}
+
+func (c *lru[V]) put(key string, v V) {
+	if c.cap <= 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var exp time.Time
+	if c.ttl > 0 {
+		exp = time.Now().Add(c.ttl)
+	}
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		e := el.Value.(*lruEntry[V])
+		e.val, e.expires = v, exp
+		return
+	}
+	c.items[key] = c.ll.PushFront(&lruEntry[V]{key, v, exp})
+	for c.ll.Len() > c.cap {
+		old := c.ll.Back()
+		c.ll.Remove(old)
+		delete(c.items, old.Value.(*lruEntry[V]).key)
+	}
+}
+
+func (c *lru[V]) stats() map[string]any {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	rate := 0.0
+	if total := c.hits + c.misses; total > 0 {
+		rate = round6(float64(c.hits) / float64(total))
+	}
+	s := map[string]any{
+		"enabled":  c.cap > 0,
+		"size":     c.ll.Len(),
+		"capacity": c.cap,
+		"hits":     c.hits,
+		"misses":   c.misses,
+		"hit_rate": rate,
+	}
+	if c.ttl > 0 {
+		s["ttl_sec"] = c.ttl.Seconds()
+	}
+	return s
+}
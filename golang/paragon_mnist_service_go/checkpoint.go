@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CHECKPOINT_DIR is where training jobs save checkpoints, every
+// CHECKPOINT_EVERY epochs and after the last one, keeping the newest
+// CHECKPOINT_KEEP per job. Empty disables checkpointing.
+var (
+	checkpointDir   = getEnv("CHECKPOINT_DIR", "./checkpoints")
+	checkpointEvery = max(getEnvInt("CHECKPOINT_EVERY", 1), 1)
+	checkpointKeep  = max(getEnvInt("CHECKPOINT_KEEP", 3), 1)
+)
+
+// TrainCheckpoint describes a saved checkpoint; its ID ("<job>-e<epoch>")
+// is what POST /train takes as "resume".
+type TrainCheckpoint struct {
+	ID        string       `json:"id"`
+	Job       string       `json:"job"`
+	Epoch     int          `json:"epoch"`
+	Samples   int          `json:"samples"`
+	Loss      float64      `json:"loss"`
+	ValLoss   float64      `json:"val_loss,omitempty"`
+	Params    TrainRequest `json:"params"` // the job's hyperparameters
+	CreatedAt time.Time    `json:"created_at"`
+}
+
+// checkpointFile is the on-disk form: the metadata plus what modelSnapshot
+// needs to rebuild the network.
+type checkpointFile struct {
+	TrainCheckpoint
+	Shapes      []struct{ Width, Height int } `json:"shapes"`
+	Activations []string                      `json:"activations"`
+	Trainable   []bool                        `json:"trainable"`
+	NumericType string                        `json:"numeric_type"`
+	Model       json.RawMessage               `json:"model"`
+}
+
+func checkpointPath(id string) string { return filepath.Join(checkpointDir, id+".json") }
+
+// saveCheckpoint writes the state of job after epoch atomically and prunes
+// the job's older checkpoints.
+func saveCheckpoint(job TrainJob, epoch int, nn network) (string, error) {
+	if err := ensureDir(checkpointDir); err != nil {
+		return "", err
+	}
+	state, err := nn.MarshalJSONModel()
+	if err != nil {
+		return "", err
+	}
+	shapes, acts, tr := nn.topology()
+	ck := checkpointFile{
+		TrainCheckpoint: TrainCheckpoint{
+			ID:        fmt.Sprintf("%s-e%03d", job.ID, epoch),
+			Job:       job.ID,
+			Epoch:     epoch,
+			Samples:   job.Samples,
+			Loss:      job.Loss,
+			ValLoss:   job.ValLoss,
+			Params:    job.Params,
+			CreatedAt: time.Now().UTC(),
+		},
+		Shapes:      shapes,
+		Activations: acts,
+		Trainable:   tr,
+		NumericType: nn.numericType(),
+		Model:       state,
+	}
+	b, err := json.Marshal(ck)
+	if err != nil {
+		return "", err
+	}
+	path := checkpointPath(ck.ID)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return "", err
+	}
+	pruneCheckpoints(job.ID, job.BestCheckpoint)
+	return ck.ID, nil
+}
+
+// pruneCheckpoints keeps the newest CHECKPOINT_KEEP checkpoints of job, and
+// its best one.
+func pruneCheckpoints(job, best string) {
+	list, err := listCheckpoints()
+	if err != nil {
+		return
+	}
+	var mine []TrainCheckpoint
+	for _, ck := range list {
+		if ck.Job == job && ck.ID != best {
+			mine = append(mine, ck)
+		}
+	}
+	for len(mine) > checkpointKeep {
+		if err := os.Remove(checkpointPath(mine[0].ID)); err != nil {
+			warnf("prune checkpoint %s: %v", mine[0].ID, err)
+		}
+		mine = mine[1:]
+	}
+}
+
+// listCheckpoints returns every readable checkpoint, by job then epoch.
+func listCheckpoints() ([]TrainCheckpoint, error) {
+	entries, err := os.ReadDir(checkpointDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var out []TrainCheckpoint
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		f, err := os.Open(filepath.Join(checkpointDir, e.Name()))
+		if err != nil {
+			continue
+		}
+		var ck TrainCheckpoint
+		err = json.NewDecoder(f).Decode(&ck)
+		f.Close()
+		if err != nil || ck.ID == "" {
+			warnf("skipping checkpoint %s: %v", e.Name(), err)
+			continue
+		}
+		out = append(out, ck)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		a, _ := strconv.Atoi(out[i].Job)
+		b, _ := strconv.Atoi(out[j].Job)
+		if a != b {
+			return a < b
+		}
+		return out[i].Epoch < out[j].Epoch
+	})
+	return out, nil
+}
+
+// loadCheckpoint reads a checkpoint back into a snapshot to resume from.
+func loadCheckpoint(id string) (*TrainCheckpoint, *modelSnapshot, error) {
+	if !modelNameRe.MatchString(id) {
+		return nil, nil, newHTTPError(http.StatusBadRequest, "bad checkpoint id "+strconv.Quote(id))
+	}
+	b, err := os.ReadFile(checkpointPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, newHTTPError(http.StatusNotFound, "unknown checkpoint: "+id)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var ck checkpointFile
+	if err := json.Unmarshal(b, &ck); err != nil {
+		return nil, nil, fmt.Errorf("checkpoint %s: %w", id, err)
+	}
+	if len(ck.Shapes) == 0 || len(ck.Activations) != len(ck.Shapes) || len(ck.Trainable) != len(ck.Shapes) {
+		return nil, nil, fmt.Errorf("checkpoint %s: bad topology", id)
+	}
+	snap := &modelSnapshot{ck.Shapes, ck.Activations, ck.Trainable, ck.Model, ck.NumericType}
+	return &ck.TrainCheckpoint, snap, nil
+}
+
+// lastCheckpointJob is the highest job number with a checkpoint on disk, so
+// job IDs don't collide with a previous run's checkpoints.
+func lastCheckpointJob() int {
+	entries, _ := os.ReadDir(checkpointDir)
+	last := 0
+	for _, e := range entries {
+		job, _, ok := strings.Cut(strings.TrimSuffix(e.Name(), ".json"), "-e")
+		if n, err := strconv.Atoi(job); ok && err == nil {
+			last = max(last, n)
+		}
+	}
+	return last
+}
+
+// handleCheckpoints lists the saved training checkpoints.
+func handleCheckpoints(w http.ResponseWriter, _ *http.Request) {
+	if checkpointDir == "" {
+		http.Error(w, "checkpoints disabled (set CHECKPOINT_DIR)", http.StatusNotFound)
+		return
+	}
+	list, err := listCheckpoints()
+	if err != nil {
+		http.Error(w, "list checkpoints: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if list == nil {
+		list = []TrainCheckpoint{}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"dir": checkpointDir, "checkpoints": list})
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// COALESCE=false disables sharing of identical in-flight predictions.
+var coalesceOn = getEnvBool("COALESCE", true)
+
+// predictFlight shares one forward pass between concurrent callers asking for
+// the same key. Only in-flight calls are shared, so errors are never cached.
+var predictFlight singleflight.Group
+
+// doFlight runs fn once per key on predictFlight; every caller, the first
+// included, stops waiting when its ctx ends while fn finishes for the rest.
+func doFlight(ctx context.Context, key string, fn func() (*ProbResult, error)) (*ProbResult, bool, error) {
+	ch := predictFlight.DoChan(key, func() (any, error) { return fn() })
+	select {
+	case res := <-ch:
+		if res.Err != nil {
+			return nil, res.Shared, res.Err
+		}
+		return res.Val.(*ProbResult), res.Shared, nil
+	case <-ctx.Done():
+		return nil, false, ctxError(ctx.Err())
+	}
+}
+
+// predictBytes decodes and forwards an image, coalescing concurrent requests for
+// identical image bytes on the same backend. Every caller gets its own copy of
+// the result so shared slices are never mutated across requests. The input
+// cache holds the plain decode; pre is applied on top of it.
+func predictBytes(ctx context.Context, data []byte, backend string, h *ParagonHandle, pre Preprocess) (*ProbResult, bool, error) {
+	in := h.Input()
+	hash := inputKey(data, in)
+	// shared work must not be cut short by whichever caller started it
+	runCtx := ctx
+	if coalesceOn {
+		runCtx = context.WithoutCancel(ctx)
+	}
+	run := func() (*ProbResult, error) {
+		dec, ok := inputCache.get(hash)
+		if ok {
+			debugf("input cache hit %s", hash[:12])
+		} else {
+			_, ds := startSpan(runCtx, "decode")
+			img, warn, err := decodeImageInput(bytes.NewReader(data), in.W, in.H)
+			ds.SetError(err)
+			ds.End()
+			if err != nil {
+				return nil, newStageError(stageDecode, http.StatusBadRequest, "bad image: "+err.Error())
+			}
+			dec = decodedInput{img, warn}
+			inputCache.put(hash, dec)
+		}
+		img := pre.apply(dec.img)
+		out, err := forwardProbsCtx(runCtx, h, img)
+		if he, ok := err.(*httpError); ok {
+			return nil, he
+		}
+		if err != nil {
+			return nil, newStageError(stageForward, http.StatusInternalServerError, "forward failed: "+err.Error())
+		}
+		if backend == "gpu" {
+			shadowGPU(runCtx, h, img, out)
+		}
+		if dec.warn != "" {
+			out.Warnings = []string{dec.warn}
+		}
+		return out, nil
+	}
+	resKey := resultKey(hash, backend, h, pre)
+	if out, ok := resultCache.get(resKey); ok {
+		debugf("result cache hit %s", hash[:12])
+		return copyResult(out), false, nil
+	}
+	cached := run
+	run = func() (*ProbResult, error) {
+		out, err := cached()
+		if err == nil {
+			resultCache.put(resKey, copyResult(out))
+		}
+		return out, err
+	}
+
+	if !coalesceOn {
+		out, err := run()
+		return out, false, err
+	}
+
+	// resKey carries the model id, so handles of different models never share
+	out, shared, err := doFlight(ctx, resKey, run)
+	if err != nil {
+		return nil, shared, err
+	}
+	if shared {
+		debugf("coalesced prediction backend=%s", backend)
+	}
+	return copyResult(out), shared, nil
+}
+
+// inputKey identifies decoded image bytes; decoded inputs depend on the
+// target size, so it is part of the key.
+func inputKey(data []byte, in inputShape) string {
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%s|%dx%d", hex.EncodeToString(sum[:]), in.W, in.H)
+}
+
+func resultKey(inKey, backend string, h *ParagonHandle, pre Preprocess) string {
+	return fmt.Sprintf("%s|%s|%d|%s", inKey, backend, h.id, pre.key())
+}
+
+func copyResult(out *ProbResult) *ProbResult {
+	cp := &ProbResult{Pred: out.Pred, Probs: append([]float64(nil), out.Probs...), Warnings: out.Warnings}
+	if out.RawProbs != nil {
+		cp.RawProbs = append([]float64(nil), out.RawProbs...)
+	}
+	return cp
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+)
+
+// COMPRESSION (default true) compresses JSON, CSV and markdown responses with
+// br or gzip, whichever the client's Accept-Encoding ranks higher (br on a
+// tie); probability arrays and /parity reports shrink several-fold. Bodies
+// under COMPRESS_MIN_BYTES (default 1024) are sent as is, since the framing
+// outweighs the saving.
+var (
+	compressionOn    = getEnvBool("COMPRESSION", true)
+	compressMinBytes = max(getEnvInt("COMPRESS_MIN_BYTES", 1024), 0)
+)
+
+// encoder is what gzip.Writer and brotli.Writer have in common.
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(io.Writer)
+}
+
+var encoderPools = map[string]*sync.Pool{
+	"br":   {New: func() any { return brotli.NewWriterLevel(nil, 5) }}, // quality 5: fast enough per request
+	"gzip": {New: func() any { return gzip.NewWriter(nil) }},
+}
+
+// compressible reports whether a response of media type ct is worth compressing.
+func compressible(ct string) bool {
+	mt, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+	switch mt {
+	case "application/json", "text/csv", "text/markdown", "text/plain":
+		return true
+	}
+	return strings.HasSuffix(mt, "+json")
+}
+
+// pickEncoding returns the encoding of encoderPools the client ranks
+// highest in Accept-Encoding, preferring br on a tie, or "" for none. A
+// coding given q=0 is refused, and "*" stands for those not listed.
+func pickEncoding(r *http.Request) string {
+	q := map[string]float64{}
+	for _, part := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		enc, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if enc = strings.ToLower(strings.TrimSpace(enc)); enc == "" {
+			continue
+		}
+		v := 1.0
+		if s, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q="); ok {
+			if f, err := strconv.ParseFloat(s, 64); err == nil {
+				v = f
+			}
+		}
+		q[enc] = v
+	}
+	best, bestQ := "", 0.0
+	for _, enc := range []string{"br", "gzip"} {
+		v, ok := q[enc]
+		if !ok {
+			v = q["*"]
+		}
+		if v > bestQ {
+			best, bestQ = enc, v
+		}
+	}
+	return best
+}
+
+// withCompression compresses responses for clients that accept it. Event
+// streams, websocket upgrades and pprof are left alone.
+func withCompression(next http.Handler) http.Handler {
+	if !compressionOn {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		enc := pickEncoding(r)
+		if r.Method == http.MethodHead || enc == "" || wantsSSE(r) ||
+			strings.HasPrefix(r.URL.Path, "/debug/pprof") || strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &compressResponseWriter{ResponseWriter: w, status: http.StatusOK, enc: enc}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	})
+}
+
+// compressResponseWriter holds back the first COMPRESS_MIN_BYTES of a body
+// to decide whether to compress it, then streams through a pooled encoder
+// or straight to the client.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool // by the handler; held until decided
+	decided     bool
+	enc         string // Content-Encoding to use
+	gz          encoder
+	buf         bytes.Buffer
+}
+
+func (g *compressResponseWriter) WriteHeader(code int) {
+	if g.wroteHeader {
+		return
+	}
+	g.wroteHeader, g.status = true, code
+	if code < http.StatusOK || code == http.StatusNoContent || code == http.StatusNotModified {
+		g.decide(false)
+	}
+}
+
+func (g *compressResponseWriter) Write(p []byte) (int, error) {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(p)
+		}
+		return g.ResponseWriter.Write(p)
+	}
+	g.buf.Write(p)
+	if g.buf.Len() >= compressMinBytes {
+		if err := g.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide sends the headers, compressing if big is set and the response is
+// of a compressible type not already encoded, then flushes what was held.
+func (g *compressResponseWriter) decide(big bool) error {
+	if g.decided {
+		return nil
+	}
+	g.decided = true
+	h := g.Header()
+	if h.Get("Content-Type") == "" && g.buf.Len() > 0 {
+		h.Set("Content-Type", http.DetectContentType(g.buf.Bytes()))
+	}
+	if big && h.Get("Content-Encoding") == "" && compressible(h.Get("Content-Type")) {
+		h.Set("Content-Encoding", g.enc)
+		h.Del("Content-Length")
+		g.gz = encoderPools[g.enc].Get().(encoder)
+		g.gz.Reset(g.ResponseWriter)
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	if g.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if g.gz != nil {
+		_, err = g.gz.Write(g.buf.Bytes())
+	} else {
+		_, err = g.ResponseWriter.Write(g.buf.Bytes())
+	}
+	g.buf.Reset()
+	return err
+}
+
+// close finishes the response once the handler returns.
+func (g *compressResponseWriter) close() {
+	if !g.decided {
+		if !g.wroteHeader {
+			return // nothing written; net/http sends its own 200
+		}
+		g.decide(false)
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		g.gz.Reset(nil)
+		encoderPools[g.enc].Put(g.gz)
+		g.gz = nil
+	}
+}
+
+// Flush sends what's been written so far, compressed if it's big enough.
+func (g *compressResponseWriter) Flush() {
+	if !g.wroteHeader {
+		g.WriteHeader(http.StatusOK)
+	}
+	g.decide(g.buf.Len() >= compressMinBytes)
+	if g.gz != nil {
+		g.gz.Flush()
+	}
+	http.NewResponseController(g.ResponseWriter).Flush()
+}
+
+// Hijack hands over the connection; nothing must have been written yet.
+func (g *compressResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(g.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (g *compressResponseWriter) Unwrap() http.ResponseWriter { return g.ResponseWriter }
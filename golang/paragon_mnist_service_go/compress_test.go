@@ -0,0 +1,69 @@
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestPickEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                       "",
+		"identity":               "",
+		"gzip":                   "gzip",
+		"br":                     "br",
+		"gzip, deflate, br":      "br",
+		"gzip;q=1, br;q=0.5":     "gzip",
+		"br;q=0, gzip;q=0.1":     "gzip",
+		"br;q=0":                 "",
+		"*":                      "br",
+		"*;q=0.5, br;q=0":        "gzip",
+		"GZIP; q=0.8, Br; q=0.9": "br",
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept-Encoding", accept)
+		if got := pickEncoding(r); got != want {
+			t.Errorf("Accept-Encoding %q: got %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestWithCompression(t *testing.T) {
+	body := `{"probs":[` + strings.Repeat("0.1,", 2000) + `0.1]}`
+	h := withCompression(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, body)
+	}))
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"":     func(r io.Reader) (io.Reader, error) { return r, nil },
+	}
+	for accept, want := range map[string]string{"br": "br", "gzip": "gzip", "br;q=0": ""} {
+		for range 2 { // the second round reuses pooled encoders
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("Accept-Encoding", accept)
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, r)
+			if got := rec.Header().Get("Content-Encoding"); got != want {
+				t.Fatalf("Accept-Encoding %q: Content-Encoding %q, want %q", accept, got, want)
+			}
+			dr, err := decoders[want](rec.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := io.ReadAll(dr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != body {
+				t.Fatalf("Accept-Encoding %q: body differs after decoding", accept)
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// CONFIG_FILE names a YAML, TOML (.toml) or JSON (.json) file of settings
+// keyed like the environment variables (model_json or MODEL_JSON).
+// Environment variables still win, so a config file can hold the defaults and
+// a deployment override a few keys.
+//
+// Sections flatten into their parent's name, so auth.admin_keys is
+// AUTH_ADMIN_KEYS; lists become comma-separated strings, as in the
+// environment. models is a list of {name, path} tables registered at startup
+// (MODELS="name=path,..." in the environment):
+//
+//	model_json: /models/mnist.json
+//	cors_origins: [https://app.example.com]
+//	auth:
+//	  api_keys: [client-key]
+//	  admin_keys: [ops-key]
+//	models:
+//	  - {name: small, path: /models/small.json}
+var fileConfig = mustLoadConfig(os.Getenv("CONFIG_FILE"))
+
+// lookupSetting returns the environment value for k, falling back to the
+// config file.
+func lookupSetting(k string) string {
+	if v := os.Getenv(k); v != "" {
+		return v
+	}
+	return fileConfig[k]
+}
+
+func mustLoadConfig(path string) map[string]string {
+	if path == "" {
+		return nil
+	}
+	cfg, err := loadConfig(path)
+	if err != nil {
+		// package init runs before the logger is guaranteed; fail loudly
+		fmt.Fprintf(os.Stderr, "config %s: %v\n", path, err)
+		os.Exit(1)
+	}
+	return cfg
+}
+
+func loadConfig(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw map[string]any
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		err = yaml.Unmarshal(data, &raw)
+	}
+	if err != nil {
+		return nil, err
+	}
+	cfg := map[string]string{}
+	if err := flattenConfig(cfg, "", raw); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// flattenConfig copies the settings in m into cfg, prefixing nested keys with
+// their section.
+func flattenConfig(cfg map[string]string, prefix string, m map[string]any) error {
+	for k, v := range m {
+		key := configKey(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		if key == "MODELS" {
+			s, err := configModelList(v)
+			if err != nil {
+				return fmt.Errorf("models: %w", err)
+			}
+			cfg[key] = s
+			continue
+		}
+		switch x := v.(type) {
+		case map[string]any:
+			if err := flattenConfig(cfg, key, x); err != nil {
+				return err
+			}
+		case []any:
+			parts := make([]string, len(x))
+			for i, p := range x {
+				s, ok := configScalar(p)
+				if !ok {
+					return fmt.Errorf("%s: lists may only hold plain values", k)
+				}
+				parts[i] = s
+			}
+			cfg[key] = strings.Join(parts, ",")
+		default:
+			s, ok := configScalar(x)
+			if !ok {
+				return fmt.Errorf("%s: unsupported value %v", k, x)
+			}
+			cfg[key] = s
+		}
+	}
+	return nil
+}
+
+// configModelList encodes a models list as the MODELS environment value.
+func configModelList(v any) (string, error) {
+	var list []any
+	switch x := v.(type) {
+	case []any:
+		list = x
+	case []map[string]any: // TOML [[models]]
+		for _, m := range x {
+			list = append(list, m)
+		}
+	default:
+		return "", fmt.Errorf("want a list of {name, path}")
+	}
+	parts := make([]string, len(list))
+	for i, e := range list {
+		m, ok := e.(map[string]any)
+		if !ok {
+			return "", fmt.Errorf("entry %d: want {name, path}", i)
+		}
+		name, _ := m["name"].(string)
+		path, _ := m["path"].(string)
+		if name == "" || path == "" {
+			return "", fmt.Errorf("entry %d: name and path are required", i)
+		}
+		parts[i] = name + "=" + path
+	}
+	return strings.Join(parts, ","), nil
+}
+
+func configScalar(v any) (string, bool) {
+	switch x := v.(type) {
+	case string:
+		return x, true
+	case bool, int, int64:
+		return fmt.Sprint(x), true
+	case float64:
+		return strconv.FormatFloat(x, 'f', -1, 64), true
+	case nil:
+		return "", true
+	}
+	return "", false
+}
+
+func configKey(k string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(k), "-", "_"))
+}
+
+// configuredModels parses MODELS into name -> path.
+func configuredModels() (map[string]string, error) {
+	out := map[string]string{}
+	for _, e := range strings.Split(lookupSetting("MODELS"), ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		name, path, ok := strings.Cut(e, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || path == "" || !modelNameRe.MatchString(name) || name == "default" {
+			return nil, fmt.Errorf("MODELS entry %q: want name=path with name matching %s", e, modelNameRe)
+		}
+		out[name] = path
+	}
+	return out, nil
+}
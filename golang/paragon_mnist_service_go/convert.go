@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// convertTypes are the targets of POST /models/{name}/convert.
+var convertTypes = map[string]bool{"int8": true, "float64": true}
+
+// castNetwork copies src into a new network of type D with activations acts,
+// mapping each weight and bias through conv(v, layer, from); from is the
+// weight's source layer, or -1 for a bias.
+func castNetwork[D, S paragon.Numeric](src *paragon.Network[S], acts []string, conv func(v float64, layer, from int) D) (*paragon.Network[D], error) {
+	shapes, _, tr := paragonNet[S]{src}.topology()
+	dst, err := paragon.NewNetwork[D](shapes, acts, tr)
+	if err != nil {
+		return nil, err
+	}
+	for l := 1; l < len(src.Layers); l++ {
+		for y, row := range src.Layers[l].Neurons {
+			for x, sn := range row {
+				dn := dst.Layers[l].Neurons[y][x]
+				if sn == nil || dn == nil {
+					continue
+				}
+				dn.Bias = conv(float64(sn.Bias), l, -1)
+				dn.Inputs = make([]paragon.Connection[D], len(sn.Inputs))
+				for i, c := range sn.Inputs {
+					dn.Inputs[i] = paragon.Connection[D]{
+						SourceLayer: c.SourceLayer,
+						SourceX:     c.SourceX,
+						SourceY:     c.SourceY,
+						Weight:      conv(float64(c.Weight), l, c.SourceLayer),
+					}
+				}
+			}
+		}
+	}
+	return dst, nil
+}
+
+func toFloat64(v float64, _, _ int) float64 { return v }
+
+// convertNet converts a float network to numType; int8 copies come with the
+// scales needed to serve them.
+func convertNet(nn network, numType string) (network, *quantScales, error) {
+	if numType == "int8" {
+		return quantizeNet(nn)
+	}
+	var (
+		dst *paragon.Network[float64]
+		err error
+	)
+	switch n := nn.(type) {
+	case paragonNet[float32]:
+		_, acts, _ := n.topology()
+		dst, err = castNetwork(n.Network, acts, toFloat64)
+	case paragonNet[float64]:
+		_, acts, _ := n.topology()
+		dst, err = castNetwork(n.Network, acts, toFloat64)
+	default:
+		return nil, nil, fmt.Errorf("model is %s, not a float type", nn.numericType())
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return paragonNet[float64]{dst}, nil, nil
+}
+
+// convertTargetFree answers 409 unless neither the model as nor its file
+// exists, so a conversion never replaces something already there.
+func convertTargetFree(as, path string) error {
+	registryMu.RLock()
+	_, taken := registry[as]
+	registryMu.RUnlock()
+	if taken {
+		return newHTTPError(http.StatusConflict, "model "+as+" already exists")
+	}
+	if path != "" {
+		if _, err := os.Stat(path); err == nil {
+			return newHTTPError(http.StatusConflict, path+" already exists")
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleModelConvert registers a copy of a model converted to ?type=
+// (int8 or float64) under ?as= (default "<name>-<type>") and scores both on
+// the labeled images. float64 copies are saved to MODELS_DIR when it is set;
+// int8 copies stay in memory since their scales aren't part of Paragon JSON.
+func handleModelConvert(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	q := r.URL.Query()
+	numType := strings.ToLower(strings.TrimSpace(q.Get("type")))
+	if !convertTypes[numType] {
+		http.Error(w, "type must be int8 or float64", http.StatusBadRequest)
+		return
+	}
+	as := strings.TrimSpace(q.Get("as"))
+	if as == "" {
+		as = name + "-" + numType
+	}
+	if !modelNameRe.MatchString(as) || as == "default" || as == name {
+		http.Error(w, "?as= must match "+modelNameRe.String()+" and name a new model", http.StatusBadRequest)
+		return
+	}
+	cpu, _, _, err := modelHandles(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	path := ""
+	if modelsDir != "" && numType == "float64" {
+		path = filepath.Join(modelsDir, as+".json")
+	}
+	if err := convertTargetFree(as, path); err != nil {
+		writeError(w, err)
+		return
+	}
+	start := time.Now()
+	nn := cpu.acquire()
+	conv, scales, err := convertNet(nn, numType)
+	cpu.put(nn)
+	if err != nil {
+		http.Error(w, "convert failed: "+err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	snap, err := snapshotOf(conv)
+	if err != nil {
+		http.Error(w, "convert failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c, g, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		http.Error(w, "load failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	c.q, g.q = scales, scales
+	if path != "" {
+		if err := conv.SaveJSON(path); err != nil {
+			g.release()
+			http.Error(w, "save model: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	m := &registeredModel{Name: as, Path: path, GPUOK: ok, cpu: c, gpu: g}
+	out := *m // m belongs to the registry once registered
+	if !registerNewModel(m) {
+		g.release()
+		if path != "" {
+			os.Remove(path)
+		}
+		http.Error(w, "model "+as+" already exists", http.StatusConflict)
+		return
+	}
+	infof("🔁 converted model %q to %s as %q in %.2fs", name, numType, as, time.Since(start).Seconds())
+
+	res := map[string]any{
+		"model":       out,
+		"source":      name,
+		"type":        numType,
+		"convert_sec": round6(time.Since(start).Seconds()),
+	}
+	if _, labeled, labels := labeledImages(); len(labeled) > 0 {
+		before, err := scoreConverted(r, name, labeled, labels)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		after, err := scoreConverted(r, as, labeled, labels)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res["accuracy"] = map[string]any{
+			"labeled":   len(labeled),
+			"source":    before,
+			"converted": after,
+			"delta":     round6(after.Accuracy - before.Accuracy),
+		}
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// scoreConverted scores model on the CPU, where both sides of a conversion
+// run the same code path.
+func scoreConverted(r *http.Request, model string, labeled []string, labels map[string]int) (*BackendAccuracy, error) {
+	opts, err := predictOpts{Model: model}.normalize()
+	if err != nil {
+		return nil, err
+	}
+	return scoreLabeled(r.Context(), model, "cpu", opts, labeled, labels)
+}
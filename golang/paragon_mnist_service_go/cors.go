@@ -1,17 +1,40 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 )
 
-// permissive CORS like your FastAPI setup; tighten in prod
+// CORS_ORIGINS is a comma-separated allow list; the default "*" is the
+// permissive FastAPI-style setup, tighten in prod
+var corsOrigins = parseOrigins(getEnv("CORS_ORIGINS", "*"))
+
+func parseOrigins(s string) map[string]bool {
+	m := map[string]bool{}
+	for _, o := range strings.Split(s, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			m[o] = true
+		}
+	}
+	return m
+}
+
 func withCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		h := w.Header()
-		h.Set("Access-Control-Allow-Origin", "*")
-		h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		if corsOrigins["*"] {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else if o := r.Header.Get("Origin"); corsOrigins[o] {
+			h.Set("Access-Control-Allow-Origin", o)
+			h.Add("Vary", "Origin")
+		}
+		h.Set("Access-Control-Allow-Methods", "GET, POST, DELETE, OPTIONS")
+		h.Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		h.Set("Access-Control-Expose-Headers", "X-Request-ID")
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
 			return
@@ -21,12 +44,65 @@ func withCORS(next http.Handler) http.Handler {
 }
 
 type httpError struct {
-	code int
-	msg  string
+	code   int
+	msg    string
+	stage  string // "decode" | "forward" when raised on the prediction path
+	field  string // offending request field, see ValidationError
+	reason string
 }
 
-func newHTTPError(code int, msg string) *httpError { return &httpError{code, msg} }
+func newHTTPError(code int, msg string) *httpError { return &httpError{code: code, msg: msg} }
 func (e *httpError) Error() string                 { return e.msg }
+
+const (
+	stageDecode  = "decode"  // missing/unreadable image: permanent, skip it
+	stageForward = "forward" // model/GPU failure: possibly transient, retry
+)
+
+func newStageError(stage string, code int, msg string) *httpError {
+	return &httpError{code: code, msg: msg, stage: stage}
+}
+
+// ctxError maps an ended request context onto a forward-stage error.
+func ctxError(err error) *httpError {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return newStageError(stageForward, http.StatusGatewayTimeout, "request timed out after "+requestTimeout.String())
+	}
+	return newStageError(stageForward, http.StatusServiceUnavailable, "request canceled")
+}
+
+// REQUEST_TIMEOUT bounds each request (Go duration, default 60s; 0 disables).
+// pprof is exempt since profiles are captured over a requested duration, and
+// event streams since they report progress for as long as the work runs.
+var requestTimeout = getEnvDuration("REQUEST_TIMEOUT", 60*time.Second)
+
+func withTimeout(next http.Handler) http.Handler {
+	if requestTimeout == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/debug/pprof") || wantsSSE(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), requestTimeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// ItemError is a per-item failure in multi-image responses.
+type ItemError struct {
+	Stage string `json:"stage"`
+	Error string `json:"error"`
+}
+
+func itemError(err error) *ItemError {
+	if he, ok := err.(*httpError); ok && he.stage != "" {
+		return &ItemError{Stage: he.stage, Error: he.msg}
+	}
+	return &ItemError{Stage: stageForward, Error: err.Error()}
+}
 func httpStatus(err error) int {
 	if he, ok := err.(*httpError); ok {
 		return he.code
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// dataset is an MNIST-format (IDX, 28x28, 10 classes) dataset the service can
+// download for autopopulation, /predict/idx, /adhd and training.
+type dataset struct {
+	name    string
+	baseURL string   // serves train-/t10k- IDX files under their usual names
+	dir     string   // local cache of the IDX files
+	classes []string // class names by label; nil when the label is the name
+}
+
+var datasets = map[string]dataset{
+	"mnist": {
+		name:    "mnist",
+		baseURL: "https://storage.googleapis.com/cvdf-datasets/mnist/",
+		dir:     "./mnist_idx",
+	},
+	"fashion": {
+		name:    "fashion",
+		baseURL: "http://fashion-mnist.s3-website.eu-central-1.amazonaws.com/",
+		dir:     "./fashion_idx",
+		classes: []string{"T-shirt/top", "Trouser", "Pullover", "Dress", "Coat", "Sandal", "Shirt", "Sneaker", "Bag", "Ankle boot"},
+	},
+	// Kuzushiji-MNIST: ten cursive hiragana, one per row of the syllabary
+	"kmnist": {
+		name:    "kmnist",
+		baseURL: "http://codh.rois.ac.jp/kmnist/dataset/kmnist/",
+		dir:     "./kmnist_idx",
+		classes: []string{"お (o)", "き (ki)", "す (su)", "つ (tsu)", "な (na)", "は (ha)", "ま (ma)", "や (ya)", "れ (re)", "を (wo)"},
+	},
+}
+
+// DATASET=mnist|fashion|kmnist selects the dataset; the served model has to have
+// been trained on the same one.
+var activeDataset = pickDataset(getEnv("DATASET", "mnist"))
+
+func pickDataset(name string) dataset {
+	if ds, ok := datasets[strings.ToLower(strings.TrimSpace(name))]; ok {
+		return ds
+	}
+	warnf("unknown DATASET %q, using mnist", name)
+	return datasets["mnist"]
+}
+
+// className returns the dataset's name for class c, or "" for digit datasets.
+func className(c int) string {
+	if c < 0 || c >= len(activeDataset.classes) {
+		return ""
+	}
+	return activeDataset.classes[c]
+}
+
+// handleDatasetDownload fetches the IDX files of DATASET as a job, so the
+// first /train or ?n= parity run doesn't stall on the download.
+// ?split=train|test limits it to one split (default both).
+func handleDatasetDownload(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	var files []string
+	switch strings.TrimSpace(q.Get("split")) {
+	case "", "all":
+		files = []string{trainImgsGZ, trainLabsGZ, testImgsGZ, testLabsGZ}
+	case "train":
+		files = []string{trainImgsGZ, trainLabsGZ}
+	case "test":
+		files = []string{testImgsGZ, testLabsGZ}
+	default:
+		http.Error(w, "bad ?split= (want train, test or all)", http.StatusBadRequest)
+		return
+	}
+	callback := strings.TrimSpace(q.Get("callback_url"))
+	if callback != "" {
+		if err := checkCallbackURL(callback); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	j, ctx := newJob("dataset", callback, nil, nil)
+	j.start(ctx, func(ctx context.Context) (any, error) {
+		if err := ensureDir(activeDataset.dir); err != nil {
+			return nil, err
+		}
+		paths := make([]string, 0, len(files))
+		for i, gz := range files {
+			j.setProgress(float64(i)/float64(len(files)), "fetching "+gz)
+			raw, err := ensureIDXFile(ctx, gz)
+			if err != nil {
+				return nil, err
+			}
+			paths = append(paths, raw)
+		}
+		infof("📦 %s idx files ready in %s", activeDataset.name, activeDataset.dir)
+		return map[string]any{"dataset": activeDataset.name, "dir": activeDataset.dir, "files": paths}, nil
+	})
+	writeJobAccepted(w, j)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"testing"
+)
+
+func TestEmbeddedDefaultModel(t *testing.T) {
+	if activeDataset.name != "mnist" {
+		t.Skip("embedded model is for mnist")
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := createDefaultModelJSON(path); err != nil {
+		t.Fatal(err)
+	}
+	snap, err := loadModelSnapshot(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := validateTopology(snap.shapes, snap.acts); err != nil {
+		t.Fatal(err)
+	}
+	cpu, _, _, err := handlesFromSnapshotN(snap, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a centered vertical stroke is a 1 to any trained MNIST model
+	img, err := decodeImageToInput(bytes.NewReader(testDigit(t, 13)), cpu.Input().W, cpu.Input().H)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := forwardProbs(cpu, img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Pred != 1 {
+		t.Fatalf("predicted %d for a stroke, want 1 (probs %v)", res.Pred, res.Probs)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strings"
+)
+
+type PredictDiffRequest struct {
+	Images []string `json:"images"`
+}
+
+type DiffRow struct {
+	Image       string      `json:"image"`
+	Before      *ProbResult `json:"before,omitempty"`
+	After       *ProbResult `json:"after,omitempty"`
+	Changed     bool        `json:"changed"`
+	Delta       []float64   `json:"delta,omitempty"` // after - before, per class
+	MaxAbsDelta float64     `json:"max_abs_delta"`
+	Error       *ItemError  `json:"error,omitempty"`
+}
+
+// handlePredictDiff re-predicts images on the current model and on the model
+// served before the last reload/reset, reporting what changed. Both sides run
+// on CPU so the comparison isn't muddied by backend differences.
+func handlePredictDiff(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	modelMu.RLock()
+	before, after := prevCPU, hCPU
+	modelMu.RUnlock()
+	if before == nil {
+		http.Error(w, "no previous model in memory (reload or replace the model first)", http.StatusConflict)
+		return
+	}
+	var req PredictDiffRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if len(req.Images) == 0 {
+		req.Images, _ = listImages()
+	}
+
+	rows := make([]DiffRow, 0, len(req.Images))
+	changed := 0
+	for _, name := range req.Images {
+		name = strings.TrimSpace(name)
+		row := DiffRow{Image: name}
+		path, err := imagePath(name)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageDecode, Error: err.Error()}
+			rows = append(rows, row)
+			continue
+		}
+		// the two models may expect different input sizes
+		imgB, err := loadImageToInput(path, before.Input().W, before.Input().H)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageDecode, Error: "bad image: " + err.Error()}
+			rows = append(rows, row)
+			continue
+		}
+		imgA, err := loadImageToInput(path, after.Input().W, after.Input().H)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageDecode, Error: "bad image: " + err.Error()}
+			rows = append(rows, row)
+			continue
+		}
+		b, err := forwardProbs(before, imgB)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageForward, Error: "previous model: " + err.Error()}
+			rows = append(rows, row)
+			continue
+		}
+		a, err := forwardProbs(after, imgA)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageForward, Error: "current model: " + err.Error()}
+			rows = append(rows, row)
+			continue
+		}
+		row.Before, row.After = b, a
+		row.Changed = a.Pred != b.Pred
+		row.Delta = make([]float64, len(a.Probs))
+		for i := range a.Probs {
+			row.Delta[i] = round6(a.Probs[i] - b.Probs[i])
+			row.MaxAbsDelta = math.Max(row.MaxAbsDelta, math.Abs(row.Delta[i]))
+		}
+		if row.Changed {
+			changed++
+		}
+		rows = append(rows, row)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]any{
+		"changed": changed,
+		"total":   len(rows),
+		"results": rows,
+	})
+}
@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type EvalRow struct {
+	Image string     `json:"image"`
+	Label int        `json:"label"`
+	Pred  int        `json:"pred"`
+	Match bool       `json:"match"`
+	Error *ItemError `json:"error,omitempty"`
+}
+
+type EvalReport struct {
+	Backend     string    `json:"backend"`
+	LabelSource string    `json:"label_source"` // "filename" | "labels_csv"
+	Correct     int       `json:"correct"`
+	Total       int       `json:"total"`
+	Accuracy    float64   `json:"accuracy"`
+	Skipped     []string  `json:"skipped,omitempty"` // images with no derivable label
+	LatencySec  float64   `json:"latency_sec"`
+	Results     []EvalRow `json:"results"`
+}
+
+// labelFromName parses the leading digits of a filename ("7.png", "7_00042.png").
+func labelFromName(name string) (int, bool) {
+	base := filepath.Base(name)
+	i := 0
+	for i < len(base) && base[i] >= '0' && base[i] <= '9' {
+		i++
+	}
+	if i == 0 {
+		return 0, false
+	}
+	n, err := strconv.Atoi(base[:i])
+	if err != nil || n < 0 || n > 9 {
+		return 0, false
+	}
+	return n, true
+}
+
+// readLabelsCSV parses "filename,label" rows; a non-numeric first row is treated as a header.
+func readLabelsCSV(r io.Reader) (map[string]int, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	labels := map[string]int{}
+	for line := 1; ; line++ {
+		rec, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(rec) == 1 && strings.TrimSpace(rec[0]) == "" {
+			continue
+		}
+		if len(rec) < 2 {
+			return nil, fmt.Errorf("line %d: want filename,label", line)
+		}
+		name := strings.TrimSpace(rec[0])
+		lbl, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+		if err != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("line %d: bad label %q", line, rec[1])
+		}
+		if lbl < 0 || lbl > 9 {
+			return nil, fmt.Errorf("line %d: label %d out of range", line, lbl)
+		}
+		if _, err := imagePath(name); err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, fmt.Sprintf("line %d: %v", line, err))
+		}
+		labels[name] = lbl
+	}
+	if len(labels) == 0 {
+		return nil, errors.New("labels file is empty")
+	}
+	return labels, nil
+}
+
+// evalLabels resolves the label source for a request: an uploaded labels.csv
+// (multipart field "labels" or a text/csv body), a ?labels= file in
+// IMAGES_DIR, or none.
+func evalLabels(r *http.Request) (map[string]int, error) {
+	if r.Method == http.MethodPost {
+		ct := r.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(ct, "multipart/form-data"):
+			f, _, err := r.FormFile("labels")
+			if err != nil {
+				return nil, newHTTPError(http.StatusBadRequest, "missing labels file: "+err.Error())
+			}
+			defer f.Close()
+			return readLabelsCSV(f)
+		case strings.HasPrefix(ct, "text/csv"), strings.HasPrefix(ct, "text/plain"):
+			return readLabelsCSV(r.Body)
+		}
+	}
+	if p := strings.TrimSpace(r.URL.Query().Get("labels")); p != "" {
+		// only files beside the images; anything else must be uploaded
+		path, err := imagePath(p)
+		if err != nil {
+			return nil, newFieldError("labels", "invalid", "?labels= must be a file name in IMAGES_DIR")
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, newHTTPError(http.StatusBadRequest, "labels file: "+err.Error())
+		}
+		defer f.Close()
+		return readLabelsCSV(f)
+	}
+	return nil, nil
+}
+
+// handleEvaluate scores the labeled images, or with ?async=true starts a job
+// that does.
+func handleEvaluate(w http.ResponseWriter, r *http.Request) {
+	async, callback, err := asyncParams(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	ev := prepareEvaluate(w, r)
+	if ev == nil {
+		return
+	}
+	if async {
+		j, ctx := newJob("evaluate", callback, nil, nil)
+		j.start(ctx, func(ctx context.Context) (any, error) {
+			rep, err := ev.run(ctx, func(done, total int) {
+				j.setProgress(float64(done)/float64(total), fmt.Sprintf("%d/%d images", done, total))
+			})
+			if err != nil {
+				return nil, err
+			}
+			return rep, nil
+		})
+		writeJobAccepted(w, j)
+		return
+	}
+	rep, err := ev.run(r.Context(), nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// evalRun is an evaluation resolved from a request, ready to run.
+type evalRun struct {
+	rep    EvalReport
+	h      *ParagonHandle
+	imgs   []string
+	labels map[string]int // nil: labels come from the file names
+}
+
+// runEvaluate scores the labeled images for r; on failure it has already
+// written the error response and returns nil.
+func runEvaluate(w http.ResponseWriter, r *http.Request) *EvalReport {
+	ev := prepareEvaluate(w, r)
+	if ev == nil {
+		return nil
+	}
+	rep, err := ev.run(r.Context(), nil)
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+	return rep
+}
+
+// prepareEvaluate resolves the backend, labels and images for r; on failure
+// it has already written the error response and returns nil.
+func prepareEvaluate(w http.ResponseWriter, r *http.Request) *evalRun {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil
+	}
+	backend, h, err := pickBackend("", r.URL.Query().Get("backend"))
+	if err != nil {
+		writeError(w, err)
+		return nil
+	}
+
+	labels, err := evalLabels(r)
+	if err != nil {
+		http.Error(w, "bad labels: "+err.Error(), httpStatus(err))
+		return nil
+	}
+
+	ev := &evalRun{rep: EvalReport{Backend: backend, LabelSource: "filename"}, h: h, labels: labels}
+	if labels != nil {
+		ev.rep.LabelSource = "labels_csv"
+		var missing []string
+		for name := range labels {
+			if ok, _ := fileExists(filepath.Join(imagesDir, name)); !ok {
+				missing = append(missing, name)
+			}
+			ev.imgs = append(ev.imgs, name)
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			writeJSON(w, http.StatusBadRequest, map[string]any{"error": "labeled images not found", "missing": missing})
+			return nil
+		}
+	} else {
+		ev.imgs, _ = listImages()
+	}
+	sort.Strings(ev.imgs)
+	debugf("evaluate backend=%s labels=%s images=%d", backend, ev.rep.LabelSource, len(ev.imgs))
+	return ev
+}
+
+// run scores the images, calling progress, when set, after each one.
+func (ev *evalRun) run(ctx context.Context, progress func(done, total int)) (*EvalReport, error) {
+	rep, h := ev.rep, ev.h
+	start := time.Now()
+	for i, name := range ev.imgs {
+		if err := ctx.Err(); err != nil {
+			return nil, ctxError(err)
+		}
+		if progress != nil && i > 0 {
+			progress(i, len(ev.imgs))
+		}
+		lbl, ok := ev.labels[name]
+		if ev.labels == nil {
+			lbl, ok = labelFromName(name)
+		}
+		if !ok {
+			rep.Skipped = append(rep.Skipped, name)
+			continue
+		}
+		row := EvalRow{Image: name, Label: lbl, Pred: -1}
+		img, err := loadImageToInput(filepath.Join(imagesDir, name), h.Input().W, h.Input().H)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageDecode, Error: "bad image: " + err.Error()}
+			rep.Results = append(rep.Results, row)
+			continue
+		}
+		out, err := forwardProbs(h, img)
+		if err != nil {
+			row.Error = &ItemError{Stage: stageForward, Error: err.Error()}
+			rep.Results = append(rep.Results, row)
+			continue
+		}
+		row.Pred = out.Pred
+		row.Match = out.Pred == lbl
+		if row.Match {
+			rep.Correct++
+		}
+		rep.Total++
+		rep.Results = append(rep.Results, row)
+	}
+	if rep.Total > 0 {
+		rep.Accuracy = round6(float64(rep.Correct) / float64(rep.Total))
+	}
+	rep.LatencySec = round6(time.Since(start).Seconds())
+	return &rep, nil
+}
+
+// ClassMetrics is one row of the per-class breakdown in /evaluate/confusion.
+type ClassMetrics struct {
+	Class     int     `json:"class"`
+	Support   int     `json:"support"` // images labeled with this class
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+}
+
+// confusionMatrix counts matrix[label][pred] over the rows that produced a
+// prediction; failed rows are left out.
+func confusionMatrix(rows []EvalRow) ([][]int, []ClassMetrics) {
+	m := make([][]int, numClasses)
+	for i := range m {
+		m[i] = make([]int, numClasses)
+	}
+	for _, row := range rows {
+		if row.Error != nil || row.Pred < 0 || row.Pred >= numClasses {
+			continue
+		}
+		m[row.Label][row.Pred]++
+	}
+	per := make([]ClassMetrics, numClasses)
+	for c := 0; c < numClasses; c++ {
+		var predicted, actual int
+		for k := 0; k < numClasses; k++ {
+			predicted += m[k][c]
+			actual += m[c][k]
+		}
+		cm := ClassMetrics{Class: c, Support: actual}
+		if predicted > 0 {
+			cm.Precision = round6(float64(m[c][c]) / float64(predicted))
+		}
+		if actual > 0 {
+			cm.Recall = round6(float64(m[c][c]) / float64(actual))
+		}
+		if cm.Precision+cm.Recall > 0 {
+			cm.F1 = round6(2 * cm.Precision * cm.Recall / (cm.Precision + cm.Recall))
+		}
+		per[c] = cm
+	}
+	return m, per
+}
+
+// handleConfusion takes the same label sources as /evaluate and returns a
+// 10x10 matrix (rows = label, cols = prediction) with per-class metrics.
+func handleConfusion(w http.ResponseWriter, r *http.Request) {
+	rep := runEvaluate(w, r)
+	if rep == nil {
+		return
+	}
+	m, per := confusionMatrix(rep.Results)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"backend":      rep.Backend,
+		"label_source": rep.LabelSource,
+		"total":        rep.Total,
+		"accuracy":     rep.Accuracy,
+		"matrix":       m,
+		"per_class":    per,
+		"skipped":      rep.Skipped,
+		"latency_sec":  rep.LatencySec,
+	})
+}
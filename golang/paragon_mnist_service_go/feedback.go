@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FEEDBACK_DIR collects POST /feedback reports: each input is stored as
+// "<label>_<id>.png", the naming /evaluate reads labels from, so the
+// directory can be used as IMAGES_DIR for scoring or fine-tuning, and every
+// report is appended to feedback.jsonl there. Empty disables feedback.
+var feedbackDir = getEnv("FEEDBACK_DIR", "./feedback")
+
+const feedbackLog = "feedback.jsonl"
+
+// feedbackMu serializes appends to the feedback log.
+var feedbackMu sync.Mutex
+
+type FeedbackRequest struct {
+	Image      string     `json:"image"`     // file in IMAGES_DIR
+	ImageB64   string     `json:"image_b64"` // base64 PNG/JPEG/BMP/WebP or data URL, used instead of image
+	Pred       *int       `json:"pred"`      // what the service predicted
+	Correct    *bool      `json:"correct"`   // optional when label is given
+	Label      *int       `json:"label"`     // the right answer; required unless correct is true
+	Model      string     `json:"model,omitempty"`
+	Backend    string     `json:"backend,omitempty"`
+	Preprocess Preprocess `json:"preprocess"` // what the prediction used, applied before storing
+}
+
+// FeedbackEntry is one line of feedback.jsonl.
+type FeedbackEntry struct {
+	ID        string    `json:"id"`
+	Image     string    `json:"image"` // stored file in FEEDBACK_DIR
+	Source    string    `json:"source,omitempty"`
+	Pred      int       `json:"pred"`
+	Label     int       `json:"label"`
+	Correct   bool      `json:"correct"`
+	Model     string    `json:"model"`
+	Backend   string    `json:"backend,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// resolve fills in label and correct from each other and checks they agree.
+func (req *FeedbackRequest) resolve() (pred, label int, correct bool, err error) {
+	inRange := func(p *int) bool { return p != nil && *p >= 0 && *p < numClasses }
+	switch {
+	case !inRange(req.Pred):
+		return 0, 0, false, newFieldError("pred", "invalid", "pred must be an integer in [0,9]")
+	case req.Label != nil && !inRange(req.Label):
+		return 0, 0, false, newFieldError("label", "invalid", "label must be an integer in [0,9]")
+	case req.Label == nil && (req.Correct == nil || !*req.Correct):
+		return 0, 0, false, newFieldError("label", "required", "label is required unless correct is true")
+	}
+	pred, label = *req.Pred, *req.Pred
+	if req.Label != nil {
+		label = *req.Label
+	}
+	correct = label == pred
+	if req.Correct != nil && *req.Correct != correct {
+		return 0, 0, false, newFieldError("correct", "invalid", fmt.Sprintf("correct=%v contradicts pred %d and label %d", *req.Correct, pred, label))
+	}
+	return pred, label, correct, nil
+}
+
+// handleFeedback records whether a prediction was right, with its input.
+func handleFeedback(w http.ResponseWriter, r *http.Request) {
+	if feedbackDir == "" {
+		http.Error(w, "feedback disabled (set FEEDBACK_DIR)", http.StatusForbidden)
+		return
+	}
+	var req FeedbackRequest
+	r.Body = http.MaxBytesReader(w, r.Body, 2*maxUploadBytes) // base64 overhead
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	pred, label, correct, err := req.resolve()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.Preprocess.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Preprocess.Std > 0 {
+		http.Error(w, "mean/std normalization can't be stored as an image", http.StatusBadRequest)
+		return
+	}
+	model := strings.TrimSpace(req.Model)
+	if model == "" {
+		model = "default"
+	}
+	cpu, _, _, err := modelHandles(model)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	hasName, hasB64 := strings.TrimSpace(req.Image) != "", strings.TrimSpace(req.ImageB64) != ""
+	if hasName == hasB64 {
+		writeError(w, newFieldError("image", "invalid", "send one of image or image_b64"))
+		return
+	}
+	var data []byte
+	source := strings.TrimSpace(req.Image)
+	if hasB64 {
+		data, err = decodeImageB64(req.ImageB64)
+	} else if path, perr := imagePath(source); perr != nil {
+		err = perr
+	} else if data, err = os.ReadFile(path); errors.Is(err, fs.ErrNotExist) {
+		http.Error(w, "image not found: "+source, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	in := cpu.Input()
+	img, err := decodeImageToInput(bytes.NewReader(data), in.W, in.H)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	img = req.Preprocess.apply(img)
+
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	e := FeedbackEntry{
+		ID:        hex.EncodeToString(id),
+		Source:    source,
+		Pred:      pred,
+		Label:     label,
+		Correct:   correct,
+		Model:     model,
+		Backend:   strings.ToLower(strings.TrimSpace(req.Backend)),
+		CreatedAt: time.Now().UTC(),
+	}
+	e.Image = fmt.Sprintf("%d_%s.png", label, e.ID)
+	if err := writePNG(filepath.Join(feedbackDir, e.Image), img); err != nil {
+		http.Error(w, "store image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := appendFeedback(e); err != nil {
+		http.Error(w, "store feedback: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if correct {
+		debugf("feedback %s: %s predicted %d correctly", e.ID, model, pred)
+	} else {
+		infof("📝 feedback %s: %s predicted %d, label %d", e.ID, model, pred, label)
+	}
+	writeJSON(w, http.StatusCreated, e)
+}
+
+func appendFeedback(e FeedbackEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+	f, err := os.OpenFile(filepath.Join(feedbackDir, feedbackLog), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// readFeedback returns the logged reports, oldest first, skipping lines that
+// don't parse.
+func readFeedback() ([]FeedbackEntry, error) {
+	feedbackMu.Lock()
+	defer feedbackMu.Unlock()
+	f, err := os.Open(filepath.Join(feedbackDir, feedbackLog))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var out []FeedbackEntry
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		var e FeedbackEntry
+		if err := json.Unmarshal(sc.Bytes(), &e); err != nil {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out, sc.Err()
+}
+
+// FeedbackCount is a number of reports and how many said the prediction was right.
+type FeedbackCount struct {
+	Total    int     `json:"total"`
+	Correct  int     `json:"correct"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+func (c *FeedbackCount) add(correct bool) {
+	c.Total++
+	if correct {
+		c.Correct++
+	}
+	c.Accuracy = round6(float64(c.Correct) / float64(c.Total))
+}
+
+// handleFeedbackStats aggregates the feedback log, optionally for one
+// ?model=: overall and per-model accuracy as users saw it, and a confusion
+// matrix (rows = label, cols = prediction) with per-class metrics.
+func handleFeedbackStats(w http.ResponseWriter, r *http.Request) {
+	if feedbackDir == "" {
+		http.Error(w, "feedback disabled (set FEEDBACK_DIR)", http.StatusForbidden)
+		return
+	}
+	entries, err := readFeedback()
+	if err != nil {
+		http.Error(w, "read feedback: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	var (
+		all     FeedbackCount
+		byModel = map[string]*FeedbackCount{}
+		rows    []EvalRow
+		last    *time.Time
+	)
+	for _, e := range entries {
+		if model != "" && e.Model != model {
+			continue
+		}
+		all.add(e.Correct)
+		if byModel[e.Model] == nil {
+			byModel[e.Model] = &FeedbackCount{}
+		}
+		byModel[e.Model].add(e.Correct)
+		rows = append(rows, EvalRow{Image: e.Image, Label: e.Label, Pred: e.Pred, Match: e.Correct})
+		last = &e.CreatedAt
+	}
+	m, per := confusionMatrix(rows)
+	res := map[string]any{
+		"dir":       feedbackDir,
+		"feedback":  all,
+		"by_model":  byModel,
+		"matrix":    m,
+		"per_class": per,
+	}
+	if last != nil {
+		res["last_at"] = *last
+	}
+	writeJSON(w, http.StatusOK, res)
+}
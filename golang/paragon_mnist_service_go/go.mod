@@ -1,8 +1,23 @@
-module main
+module paragon_mnist_service_go
 
 go 1.24.3
 
 require (
-	github.com/openfluke/paragon/v3 v3.1.4 // indirect
-	github.com/openfluke/webgpu v0.0.1 // indirect
+	github.com/BurntSushi/toml v1.6.0
+	github.com/andybalholm/brotli v1.2.5
+	github.com/coder/websocket v1.8.13
+	github.com/openfluke/paragon/v3 v3.1.4
+	github.com/openfluke/webgpu v0.0.1
+	golang.org/x/image v0.36.0
+	golang.org/x/sync v0.19.0
+	google.golang.org/grpc v1.78.0
+	google.golang.org/protobuf v1.36.11
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+	golang.org/x/text v0.34.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20251029180050-ab9386a59fda // indirect
 )
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/openfluke/webgpu/wgpu"
+)
+
+// WGPU_BACKEND (vulkan, metal, dx12 or gl; empty = wgpu's choice) is
+// validated and exported before the models load, so a value from CONFIG_FILE
+// reaches wgpu builds that read it. Neither it nor an adapter can be chosen
+// per handle or changed at runtime: Paragon creates a single WebGPU instance
+// per process, with no descriptor, and takes whatever adapter a
+// high-performance request resolves to. The wgpu-native libraries bundled
+// with openfluke/webgpu v0.0.1 don't read WGPU_BACKEND at all. Choosing a GPU
+// on a multi-GPU machine needs an adapter option in Paragon first; until then
+// GET /gpu/adapters reports which adapter is in use.
+var wgpuBackends = []string{"vulkan", "metal", "dx12", "gl"}
+
+// gpuBackend is the backend GPU handles were last initialized on, e.g.
+// "Vulkan"; empty when serving CPU only.
+var gpuBackend atomic.Value // string
+
+// setWGPUBackend validates and exports WGPU_BACKEND at startup.
+func setWGPUBackend(b string) error {
+	b = strings.ToLower(strings.TrimSpace(b))
+	if b != "" && !slices.Contains(wgpuBackends, b) {
+		return newFieldError("wgpu_backend", "invalid", fmt.Sprintf("wgpu_backend must be one of %s", strings.Join(wgpuBackends, ", ")))
+	}
+	wgpuMu.Lock()
+	defer wgpuMu.Unlock()
+	if b == "" {
+		return os.Unsetenv("WGPU_BACKEND")
+	}
+	return os.Setenv("WGPU_BACKEND", b)
+}
+
+func wgpuBackendSetting() string { return os.Getenv("WGPU_BACKEND") }
+
+// refreshGPUBackend records the backend of the adapter wgpu now hands out,
+// or clears it when ok is false.
+func refreshGPUBackend(ok bool) {
+	name := ""
+	if ok {
+		for _, a := range listGPUAdapters() {
+			if a.Default {
+				name = a.Backend
+				break
+			}
+		}
+	}
+	gpuBackend.Store(name)
+}
+
+func activeGPUBackend() string {
+	s, _ := gpuBackend.Load().(string)
+	return s
+}
+
+// GPUAdapter describes one WebGPU adapter on this machine.
+type GPUAdapter struct {
+	Index        int    `json:"index"`
+	Name         string `json:"name"`
+	Vendor       string `json:"vendor,omitempty"`
+	VendorID     uint32 `json:"vendor_id"`
+	DeviceID     uint32 `json:"device_id"`
+	Architecture string `json:"architecture,omitempty"`
+	Driver       string `json:"driver,omitempty"`
+	Backend      string `json:"backend"` // e.g. "Vulkan", "Metal", "D3D12"
+	Type         string `json:"type"`    // e.g. "discrete-gpu", "integrated-gpu", "cpu"
+	Default      bool   `json:"default"` // what a high-performance request without a choice gets
+}
+
+// wgpuMu serializes adapter enumeration; a wgpu instance is cheap but
+// creating several at once on some drivers is not.
+var wgpuMu sync.Mutex
+
+// listGPUAdapters enumerates every adapter wgpu can see on any backend and
+// marks the one a default high-performance request resolves to.
+func listGPUAdapters() []GPUAdapter {
+	wgpuMu.Lock()
+	defer wgpuMu.Unlock()
+	inst := wgpu.CreateInstance(nil)
+	defer inst.Release()
+
+	var def *wgpu.AdapterInfo
+	if a, err := inst.RequestAdapter(&wgpu.RequestAdapterOptions{PowerPreference: wgpu.PowerPreferenceHighPerformance}); err == nil && a != nil {
+		info := a.GetInfo()
+		def = &info
+		a.Release()
+	}
+	out := []GPUAdapter{}
+	for i, a := range inst.EnumerateAdapters(nil) {
+		info := a.GetInfo()
+		a.Release()
+		out = append(out, GPUAdapter{
+			Index:        i,
+			Name:         info.Name,
+			Vendor:       info.VendorName,
+			VendorID:     info.VendorId,
+			DeviceID:     info.DeviceId,
+			Architecture: info.Architecture,
+			Driver:       info.DriverDescription,
+			Backend:      info.BackendType.String(),
+			Type:         info.AdapterType.String(),
+			Default: def != nil && info.Name == def.Name && info.DeviceId == def.DeviceId &&
+				info.VendorId == def.VendorId && info.BackendType == def.BackendType,
+		})
+	}
+	return out
+}
+
+// handleGPUAdapters lists the WebGPU adapters. Paragon initializes its GPU
+// networks on the default adapter itself and can't be pointed at another, so
+// this is informational: it tells which device "gpu" requests run on and what
+// else is installed.
+func handleGPUAdapters(w http.ResponseWriter, _ *http.Request) {
+	adapters := listGPUAdapters()
+	res := map[string]any{
+		"adapters":      adapters,
+		"gpu_available": gpuAvailable(),
+		"gpu_backend":   activeGPUBackend(),
+		"wgpu_backend":  wgpuBackendSetting(),
+	}
+	for _, a := range adapters {
+		if a.Default {
+			res["default"] = a.Index
+			break
+		}
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// GPU_WATCHDOG_INTERVAL is how often a canary forward checks every GPU handle
+// against its CPU twin (Go duration, default 1m; 0 disables). A GPU that
+// errors, hangs past GPU_WATCHDOG_TIMEOUT or drifts more than GPU_WATCHDOG_TOL
+// (max |cpu-gpu| over the probabilities) is taken out of service, so "auto"
+// requests move to the CPU, and re-initialized on the following ticks until
+// it passes again.
+var (
+	gpuWatchInterval = getEnvDuration("GPU_WATCHDOG_INTERVAL", time.Minute)
+	gpuWatchTimeout  = getEnvDuration("GPU_WATCHDOG_TIMEOUT", 10*time.Second)
+	gpuWatchTol      = getEnvFloat("GPU_WATCHDOG_TOL", 1e-3)
+)
+
+var errCanaryTimeout = errors.New("canary forward timed out")
+
+// gpuWatch is the watchdog state; down and busy are only touched by the
+// watchdog goroutine.
+var gpuWatch struct {
+	checks, failures, recoveries atomic.Int64
+	mu                           sync.Mutex // guards last*
+	lastCheck                    time.Time
+	lastError                    string
+
+	down map[*ParagonHandle]bool // taken out of service by the watchdog
+	busy map[*ParagonHandle]bool // a timed-out canary still holds a copy
+}
+
+// GPUWatchStats is exposed under /metrics.
+type GPUWatchStats struct {
+	IntervalSec float64 `json:"interval_sec"`
+	Checks      int64   `json:"checks"`
+	Failures    int64   `json:"failures"`
+	Recoveries  int64   `json:"recoveries"`
+	LastCheck   string  `json:"last_check,omitempty"`
+	LastError   string  `json:"last_error,omitempty"`
+}
+
+func gpuWatchStats() GPUWatchStats {
+	gpuWatch.mu.Lock()
+	defer gpuWatch.mu.Unlock()
+	s := GPUWatchStats{
+		IntervalSec: gpuWatchInterval.Seconds(),
+		Checks:      gpuWatch.checks.Load(),
+		Failures:    gpuWatch.failures.Load(),
+		Recoveries:  gpuWatch.recoveries.Load(),
+		LastError:   gpuWatch.lastError,
+	}
+	if !gpuWatch.lastCheck.IsZero() {
+		s.LastCheck = gpuWatch.lastCheck.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+func startGPUWatchdog() {
+	if gpuWatchInterval <= 0 {
+		return
+	}
+	gpuWatch.down = map[*ParagonHandle]bool{}
+	gpuWatch.busy = map[*ParagonHandle]bool{}
+	infof("🐕 GPU watchdog every %s (tol %g)", gpuWatchInterval, gpuWatchTol)
+	go func() {
+		for range time.Tick(gpuWatchInterval) {
+			for drained := false; !drained; {
+				select {
+				case h := <-gpuWatchRelease:
+					delete(gpuWatch.busy, h)
+				default:
+					drained = true
+				}
+			}
+			cpu, gpu, ok := currentHandles()
+			watchGPU("", cpu, gpu, ok)
+			for _, m := range registeredModels() {
+				watchGPU(m.Name, m.cpu, m.gpu, m.GPUOK)
+			}
+		}
+	}()
+}
+
+// watchGPU checks one model's GPU handle, taking it down on failure and
+// trying to bring back one the watchdog took down earlier. GPUs that never
+// came up are left to /admin/gpu/reinit.
+func watchGPU(name string, cpu, gpu *ParagonHandle, ok bool) {
+	if gpu == nil || gpuWatch.busy[gpu] || (!ok && !gpuWatch.down[gpu]) {
+		return
+	}
+	label := name
+	if label == "" {
+		label = "default"
+	}
+	if ok {
+		err := gpuCanary(cpu, gpu)
+		gpuWatch.checks.Add(1)
+		gpuWatch.mu.Lock()
+		gpuWatch.lastCheck = time.Now()
+		if err != nil {
+			gpuWatch.lastError = label + ": " + err.Error()
+		}
+		gpuWatch.mu.Unlock()
+		if err == nil {
+			return
+		}
+		gpuWatch.failures.Add(1)
+		warnf("⚠️  GPU watchdog: model %q failed its canary, serving CPU only: %v", label, err)
+		setGPUOK(name, gpu, false)
+		gpuWatch.down[gpu] = true
+		if errors.Is(err, errCanaryTimeout) {
+			return // the wedged copy can't be drained for a rebuild yet
+		}
+	}
+
+	adminMu.Lock()
+	err := gpu.reinitGPU()
+	adminMu.Unlock()
+	if err == nil {
+		err = gpuCanary(cpu, gpu)
+	}
+	if err != nil {
+		debugf("GPU watchdog: model %q still down: %v", label, err)
+		return
+	}
+	delete(gpuWatch.down, gpu)
+	setGPUOK(name, gpu, true)
+	gpuWatch.recoveries.Add(1)
+	infof("✅ GPU watchdog: model %q recovered after re-init", label)
+}
+
+// gpuCanary runs a fixed input through both handles and fails on a GPU
+// error, a hang, or a result that drifts from the CPU one.
+func gpuCanary(cpu, gpu *ParagonHandle) error {
+	img := canaryInput(cpu.Input())
+	want, err := forwardProbs(cpu, img)
+	if err != nil {
+		return nil // nothing to compare against; not the GPU's fault
+	}
+
+	type result struct {
+		out *ProbResult
+		err error
+	}
+	done := make(chan result, 1)
+	gpuWatch.busy[gpu] = true
+	go func() {
+		out, err := forwardProbs(gpu, img)
+		done <- result{out, err}
+	}()
+	var got result
+	select {
+	case got = <-done:
+		delete(gpuWatch.busy, gpu)
+	case <-time.After(gpuWatchTimeout):
+		// stays busy until the forward returns, so no rebuild waits on it
+		go func() { <-done; gpuWatchRelease <- gpu }()
+		return errCanaryTimeout
+	}
+	if got.err != nil {
+		return got.err
+	}
+	for _, p := range got.out.Probs {
+		if math.IsNaN(p) || math.IsInf(p, 0) {
+			return errors.New("non-finite GPU output")
+		}
+	}
+	_, maxd, _ := diffStats(want.Probs, got.out.Probs)
+	if got.out.Pred != want.Pred || maxd > gpuWatchTol {
+		return fmt.Errorf("diverges from CPU (pred %d vs %d, max |diff| %.3g)", got.out.Pred, want.Pred, maxd)
+	}
+	return nil
+}
+
+// gpuWatchRelease hands back handles whose timed-out canary finished.
+var gpuWatchRelease = make(chan *ParagonHandle, 16)
+
+// canaryInput is a fixed, non-trivial pattern shaped like the model input.
+func canaryInput(in inputShape) [][]float64 {
+	img := make([][]float64, in.H)
+	for y := range img {
+		img[y] = make([]float64, in.W)
+		for x := range img[y] {
+			img[y][x] = float64((x*7+y*13)%17) / 16
+		}
+	}
+	return img
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	pb "paragon_mnist_service_go/proto"
+)
+
+// GRPC_ADDR (e.g. 0.0.0.0:9003) serves proto/paragon_inference.proto over
+// gRPC from this process, sharing the handles, caches and pools with HTTP.
+// It uses TLS when the HTTP side has TLS set up, AUTH_API_KEYS as
+// "authorization: Bearer <key>" or x-api-key metadata, and the RATE_LIMIT_*
+// buckets of the HTTP prediction routes, keyed by peer IP.
+var grpcAddr = getEnv("GRPC_ADDR", "")
+
+// grpcStatusFrom maps the HTTP-flavored errors of the shared prediction path.
+func grpcStatusFrom(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	code := codes.Internal
+	switch {
+	case errors.Is(err, context.Canceled):
+		code = codes.Canceled
+	case errors.Is(err, context.DeadlineExceeded):
+		code = codes.DeadlineExceeded
+	default:
+		switch httpStatus(err) {
+		case http.StatusBadRequest, http.StatusUnprocessableEntity:
+			code = codes.InvalidArgument
+		case http.StatusNotFound:
+			code = codes.NotFound
+		case http.StatusForbidden:
+			code = codes.PermissionDenied
+		case http.StatusTooManyRequests, http.StatusRequestEntityTooLarge:
+			code = codes.ResourceExhausted
+		case http.StatusServiceUnavailable:
+			code = codes.Unavailable
+		case http.StatusGatewayTimeout:
+			code = codes.DeadlineExceeded
+		}
+	}
+	return status.Error(code, err.Error())
+}
+
+func startGRPC() {
+	if grpcAddr == "" {
+		return
+	}
+	var opts []grpc.ServerOption
+	switch {
+	case certFile != "" && keyFile != "":
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			errorf("gRPC TLS: %v", err)
+			return
+		}
+		opts = append(opts, grpc.Creds(creds))
+	case selfSigned:
+		cert, err := selfSignedCert()
+		if err != nil {
+			errorf("gRPC TLS: %v", err)
+			return
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{Certificates: []tls.Certificate{cert}})))
+	}
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		errorf("gRPC listen %s: %v", grpcAddr, err)
+		return
+	}
+	srv := newGRPCServer(opts...)
+	go func() {
+		errorf("gRPC server stopped: %v", srv.Serve(lis))
+	}()
+	infof("🛰️  gRPC on %s (%s)", grpcAddr, pb.Inference_ServiceDesc.ServiceName)
+}
+
+func newGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(grpcAuth, grpcRateLimit, grpcErrors))
+	srv := grpc.NewServer(opts...)
+	pb.RegisterInferenceServer(srv, inferenceServer{})
+	return srv
+}
+
+// grpcAuth applies AUTH_API_KEYS to every call.
+func grpcAuth(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	if len(apiKeys) > 0 {
+		md, _ := metadata.FromIncomingContext(ctx)
+		key := ""
+		if v := md.Get("authorization"); len(v) > 0 {
+			key, _ = strings.CutPrefix(v[0], "Bearer ")
+		} else if v := md.Get("x-api-key"); len(v) > 0 {
+			key = v[0]
+		}
+		if !validAPIKey(strings.TrimSpace(key)) {
+			return nil, status.Error(codes.Unauthenticated, "missing or invalid API key")
+		}
+	}
+	return next(ctx, req)
+}
+
+// grpcRateLimit spends a token for every call that runs the model.
+func grpcRateLimit(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	if limiter.enabled() && info.FullMethod != pb.Inference_ModelInfo_FullMethodName {
+		client := ""
+		if p, ok := peer.FromContext(ctx); ok {
+			client = p.Addr.String()
+			if host, _, err := net.SplitHostPort(client); err == nil {
+				client = host
+			}
+		}
+		if ok, wait := limiter.allow(client); !ok {
+			return nil, status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry in %ds", retryAfter(wait))
+		}
+	}
+	return next(ctx, req)
+}
+
+// grpcErrors turns the errors of the shared code into gRPC statuses.
+func grpcErrors(ctx context.Context, req any, _ *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	resp, err := next(ctx, req)
+	if err != nil {
+		return nil, grpcStatusFrom(err)
+	}
+	return resp, nil
+}
+
+type inferenceServer struct {
+	pb.UnimplementedInferenceServer
+}
+
+func runGRPCPredict(ctx context.Context, q *pb.PredictRequest) (map[string]any, error) {
+	opts, err := predictOpts{TopK: int(q.GetTopk()), Model: strings.TrimSpace(q.GetModel())}.normalize()
+	if err != nil {
+		return nil, err
+	}
+	image := strings.TrimSpace(q.GetImage())
+	switch {
+	case len(q.GetPng()) > 0 && image != "":
+		return nil, status.Error(codes.InvalidArgument, "send either image or png, not both")
+	case len(q.GetPng()) > 0:
+		if len(q.GetPng()) > maxUploadBytes {
+			return nil, status.Error(codes.ResourceExhausted, "png exceeds 2 MB")
+		}
+		return predictData(ctx, q.GetPng(), "png", q.GetBackend(), opts)
+	case image != "":
+		return predictCore(ctx, image, q.GetBackend(), opts)
+	}
+	return nil, status.Error(codes.InvalidArgument, "missing image or png")
+}
+
+func predictResponse(res map[string]any) *pb.PredictResponse {
+	out := &pb.PredictResponse{}
+	if v, ok := res["prediction"].(int); ok {
+		out.Prediction = int32(v)
+	}
+	out.Probabilities, _ = res["probabilities"].([]float64)
+	if v, ok := res["top_k"].([]ClassProb); ok {
+		for _, cp := range v {
+			out.TopK = append(out.TopK, &pb.ClassProb{Class: int32(cp.Class), Prob: cp.Prob})
+		}
+	}
+	out.LatencySec, _ = res["latency_sec"].(float64)
+	out.Backend, _ = res["backend"].(string)
+	out.Image, _ = res["image"].(string)
+	return out
+}
+
+func (inferenceServer) Predict(ctx context.Context, q *pb.PredictRequest) (*pb.PredictResponse, error) {
+	res, err := runGRPCPredict(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return predictResponse(res), nil
+}
+
+func (inferenceServer) PredictBatch(ctx context.Context, q *pb.PredictBatchRequest) (*pb.PredictBatchResponse, error) {
+	items := q.GetItems()
+	if len(items) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "items must not be empty")
+	}
+	if len(items) > batchMax {
+		return nil, status.Error(codes.InvalidArgument, fmt.Sprintf("batch of %d exceeds max %d", len(items), batchMax))
+	}
+	out := &pb.PredictBatchResponse{}
+	for _, it := range items {
+		res, err := runGRPCPredict(ctx, it)
+		if err != nil {
+			out.Failed++
+			out.Results = append(out.Results, &pb.PredictResponse{Image: it.GetImage(), Error: err.Error()})
+			continue
+		}
+		out.Results = append(out.Results, predictResponse(res))
+	}
+	return out, nil
+}
+
+func (inferenceServer) Parity(ctx context.Context, q *pb.ParityRequest) (*pb.ParityResponse, error) {
+	src := paritySource{Images: q.GetImages(), N: int(q.GetN()), Split: q.GetSplit()}
+	if src.N < 0 {
+		return nil, status.Error(codes.InvalidArgument, "n must be >= 0")
+	}
+	if q.GetSeed() != 0 {
+		seed := q.GetSeed()
+		src.Seed = &seed
+	}
+	tol := parityTol{Abs: 1e-4}
+	if q.GetTol() > 0 {
+		tol.Abs = q.GetTol()
+	}
+	if q.GetRtol() > 0 {
+		tol.Rel = q.GetRtol()
+	}
+	rep, err := runParity(ctx, strings.TrimSpace(q.GetModel()), src, tol, nil)
+	if err != nil {
+		return nil, err
+	}
+	out := &pb.ParityResponse{
+		GpuAvailable: rep.GPUAvailable,
+		Tolerance:    rep.Tolerance,
+		Mismatches:   int32(rep.Mismatches),
+		Total:        int32(rep.Total),
+		RelTolerance: rep.RelTolerance,
+		Source:       rep.Source,
+		Seed:         derefOr(rep.Seed, 0),
+	}
+	for _, row := range rep.Results {
+		r := &pb.ParityRow{Image: row.Image, Error: row.Error}
+		if row.Label != nil {
+			l := int32(*row.Label) // explicit presence: label 0 is meaningful
+			r.Label = &l
+		}
+		if row.CPU != nil {
+			r.CpuPred = int32(row.CPU.Pred)
+		}
+		if row.GPU != nil {
+			r.GpuPred = int32(row.GPU.Pred)
+		}
+		r.Mae = derefOr(row.MAE, 0)
+		r.MaxAbsDiff = derefOr(row.MaxAbsDiff, 0)
+		r.MaxRelDiff = derefOr(row.MaxRelDiff, 0)
+		r.Match = derefOr(row.Match, false)
+		r.WithinTol = derefOr(row.WithinTol, false)
+		out.Rows = append(out.Rows, r)
+	}
+	return out, nil
+}
+
+func (inferenceServer) ModelInfo(_ context.Context, q *pb.ModelInfoRequest) (*pb.ModelInfoResponse, error) {
+	cpu, _, _, err := modelHandles(q.GetModel())
+	if err != nil {
+		return nil, err
+	}
+	info := cpu.Info()
+	out := &pb.ModelInfoResponse{NumericType: info.NumericType, Params: info.Params, EstVramMb: info.EstVRAMMB}
+	for _, l := range info.Layers {
+		out.Layers = append(out.Layers, &pb.Layer{Width: int32(l.Width), Height: int32(l.Height), Activation: l.Activation, Trainable: l.Trainable})
+	}
+	return out, nil
+}
+
+func derefOr[T any](p *T, def T) T {
+	if p == nil {
+		return def
+	}
+	return *p
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	pb "paragon_mnist_service_go/proto"
+)
+
+func dialTestGRPC(t *testing.T) pb.InferenceClient {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newGRPCServer()
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient(lis.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewInferenceClient(conn)
+}
+
+func TestGRPCPredict(t *testing.T) {
+	useTestModel(t)
+	client := dialTestGRPC(t)
+	ctx := context.Background()
+
+	info, err := client.ModelInfo(ctx, &pb.ModelInfoRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(info.Layers) != 3 || info.Layers[0].Width != 28 || info.Layers[2].Width != 10 {
+		t.Fatalf("ModelInfo layers = %v", info.Layers)
+	}
+
+	res, err := client.Predict(ctx, &pb.PredictRequest{Png: testDigit(t, 10), Backend: "cpu", Topk: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Probabilities) != 10 || len(res.TopK) != 3 || res.Backend != "cpu" {
+		t.Fatalf("Predict = %v", res)
+	}
+	if res.Prediction != res.TopK[0].Class {
+		t.Fatalf("prediction %d, top class %d", res.Prediction, res.TopK[0].Class)
+	}
+
+	_, err = client.Predict(ctx, &pb.PredictRequest{})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("empty Predict: got %v, want InvalidArgument", err)
+	}
+	_, err = client.Predict(ctx, &pb.PredictRequest{Image: "../../etc/passwd"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("Predict outside IMAGES_DIR: got %v, want InvalidArgument", err)
+	}
+}
+
+func TestGRPCPredictBatch(t *testing.T) {
+	useTestModel(t)
+	client := dialTestGRPC(t)
+
+	res, err := client.PredictBatch(context.Background(), &pb.PredictBatchRequest{Items: []*pb.PredictRequest{
+		{Png: testDigit(t, 8), Backend: "cpu"},
+		{Png: []byte("not an image"), Backend: "cpu"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Results) != 2 || res.Failed != 1 || res.Results[0].Error != "" || res.Results[1].Error == "" {
+		t.Fatalf("PredictBatch = %v", res)
+	}
+}
+
+func TestGRPCRateLimit(t *testing.T) {
+	useTestModel(t)
+	useTestLimiter(t, 0.001, 1)
+	client := dialTestGRPC(t)
+	ctx := context.Background()
+
+	if _, err := client.Predict(ctx, &pb.PredictRequest{Png: testDigit(t, 10), Backend: "cpu"}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := client.Predict(ctx, &pb.PredictRequest{Png: testDigit(t, 10), Backend: "cpu"})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("second Predict: got %v, want ResourceExhausted", err)
+	}
+	if _, err := client.ModelInfo(ctx, &pb.ModelInfoRequest{}); err != nil {
+		t.Fatalf("ModelInfo is not limited: %v", err)
+	}
+}
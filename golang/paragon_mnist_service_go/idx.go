@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	idxMagicImages = 2051
+	idxMagicLabels = 2049
+)
+
+// IDX_MMAP=false forces streaming reads even where mmap is available.
+var idxUseMmap = getEnvBool("IDX_MMAP", true)
+
+// idxFile gives O(1) access to the items of an IDX file, either through a
+// read-only memory map or, when that is unavailable, positioned reads.
+type idxFile struct {
+	f        *os.File
+	data     []byte // mmap'd file, nil when streaming
+	count    int
+	rows     int // 1 for label files
+	cols     int
+	itemSize int
+	offset   int64 // header length
+}
+
+func openIDX(path string, magic uint32) (*idxFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	var head [16]byte
+	n := 8
+	if magic == idxMagicImages {
+		n = 16
+	}
+	if _, err := io.ReadFull(f, head[:n]); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if got := binary.BigEndian.Uint32(head[0:4]); got != magic {
+		f.Close()
+		return nil, fmt.Errorf("bad magic %d (want %d)", got, magic)
+	}
+	x := &idxFile{f: f, count: int(binary.BigEndian.Uint32(head[4:8])), rows: 1, cols: 1, offset: int64(n)}
+	if magic == idxMagicImages {
+		x.rows = int(binary.BigEndian.Uint32(head[8:12]))
+		x.cols = int(binary.BigEndian.Uint32(head[12:16]))
+	}
+	x.itemSize = x.rows * x.cols
+
+	if idxUseMmap {
+		if data, err := mmapFile(f); err == nil {
+			x.data = data
+		} else {
+			debugf("mmap %s unavailable, streaming: %v", path, err)
+		}
+	}
+	if x.data != nil && int64(len(x.data)) < x.offset+int64(x.count)*int64(x.itemSize) {
+		x.Close()
+		return nil, fmt.Errorf("%s truncated", path)
+	}
+	return x, nil
+}
+
+func (x *idxFile) Len() int { return x.count }
+
+func (x *idxFile) item(i int, buf []byte) error {
+	if i < 0 || i >= x.count {
+		return fmt.Errorf("index %d out of range [0,%d)", i, x.count)
+	}
+	off := x.offset + int64(i)*int64(x.itemSize)
+	if x.data != nil {
+		copy(buf, x.data[off:off+int64(x.itemSize)])
+		return nil
+	}
+	_, err := x.f.ReadAt(buf, off)
+	return err
+}
+
+// Image returns item i scaled to [0,1].
+func (x *idxFile) Image(i int) ([][]float64, error) {
+	buf := make([]byte, x.itemSize)
+	if err := x.item(i, buf); err != nil {
+		return nil, err
+	}
+	img := make([][]float64, x.rows)
+	for r := 0; r < x.rows; r++ {
+		row := make([]float64, x.cols)
+		for c := 0; c < x.cols; c++ {
+			row[c] = float64(buf[r*x.cols+c]) / 255.0
+		}
+		img[r] = row
+	}
+	return img, nil
+}
+
+func (x *idxFile) Label(i int) (int, error) {
+	var b [1]byte
+	if err := x.item(i, b[:]); err != nil {
+		return 0, err
+	}
+	return int(b[0]), nil
+}
+
+func (x *idxFile) Close() error {
+	if x.data != nil {
+		_ = munmapFile(x.data)
+		x.data = nil
+	}
+	return x.f.Close()
+}
+
+var (
+	idxMu    sync.Mutex
+	idxCache = map[string]*idxFile{}
+)
+
+// cachedIDX keeps IDX files open for the life of the process so per-index
+// lookups don't reopen (and remap) the file every request.
+func cachedIDX(path string, magic uint32) (*idxFile, error) {
+	idxMu.Lock()
+	defer idxMu.Unlock()
+	if x, ok := idxCache[path]; ok {
+		return x, nil
+	}
+	x, err := openIDX(path, magic)
+	if err != nil {
+		return nil, err
+	}
+	idxCache[path] = x
+	return x, nil
+}
+
+// IDXStats describes an IDX file kept open by cachedIDX.
+type IDXStats struct {
+	Path   string `json:"path"`
+	Items  int    `json:"items"`
+	Bytes  int64  `json:"bytes"`  // header + items
+	Mapped bool   `json:"mapped"` // memory-mapped; otherwise read item by item
+}
+
+func idxStats() []IDXStats {
+	idxMu.Lock()
+	defer idxMu.Unlock()
+	out := make([]IDXStats, 0, len(idxCache))
+	for path, x := range idxCache {
+		out = append(out, IDXStats{Path: path, Items: x.count, Bytes: x.offset + int64(x.count*x.itemSize), Mapped: x.data != nil})
+	}
+	sort.Slice(out, func(a, b int) bool { return out[a].Path < out[b].Path })
+	return out
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+
+	_ "golang.org/x/image/bmp"
+	_ "golang.org/x/image/webp"
+)
+
+// Inputs may be PNG, JPEG, BMP or WebP; the format is sniffed from the
+// content, not the file name or Content-Type.
+
+// IMAGE_MAX_PIXELS bounds width*height of an input (default 4 megapixels).
+// Headers are checked before decoding, since a decoder allocates the full
+// canvas up front.
+var maxImagePixels = max(getEnvInt("IMAGE_MAX_PIXELS", 4<<20), 1)
+
+// imageFormat names the format of data from its magic bytes, or "".
+func imageFormat(data []byte) string {
+	switch {
+	case bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")):
+		return "png"
+	case bytes.HasPrefix(data, []byte("\xff\xd8\xff")):
+		return "jpeg"
+	case bytes.HasPrefix(data, []byte("BM")):
+		return "bmp"
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "webp"
+	}
+	return ""
+}
+
+// decodeImage decodes any supported format after checking its dimensions.
+func decodeImage(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if errors.Is(err, image.ErrFormat) {
+		return nil, errors.New("unrecognized image format (want PNG, JPEG, BMP or WebP)")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width > maxImagePixels/cfg.Height {
+		return nil, fmt.Errorf("image is %dx%d, over the %d pixel limit", cfg.Width, cfg.Height, maxImagePixels)
+	}
+	im, _, err := image.Decode(bytes.NewReader(data))
+	return im, err
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IMAGE_UPLOAD_ENABLED=true turns on POST /images, which writes into IMAGES_DIR.
+var imageUploadOn = getEnvBool("IMAGE_UPLOAD_ENABLED", false)
+
+type ImageUploadRequest struct {
+	ImageB64 string `json:"image_b64"` // base64 PNG/JPEG/BMP/WebP or data URL
+	Label    *int   `json:"label"`     // optional ground truth, 0-9
+
+	Preprocess Preprocess `json:"preprocess"` // applied before storing, e.g. invert
+}
+
+// handleImageUpload stores an image under IMAGES_DIR, normalized to the
+// model's input size as 8-bit grayscale. Labeled images are named
+// "<label>_<id>.png" so /evaluate picks the label up from the file name.
+func handleImageUpload(w http.ResponseWriter, r *http.Request) {
+	if !imageUploadOn {
+		http.Error(w, "image upload disabled (set IMAGE_UPLOAD_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	var (
+		data  []byte
+		label = strings.TrimSpace(r.URL.Query().Get("label"))
+		pre   Preprocess
+		err   error
+	)
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		var req ImageUploadRequest
+		r.Body = http.MaxBytesReader(w, r.Body, 2*maxUploadBytes) // base64 overhead
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, err)
+			return
+		}
+		if data, err = decodeImageB64(req.ImageB64); err != nil {
+			writeError(w, err)
+			return
+		}
+		if req.Label != nil {
+			label = strconv.Itoa(*req.Label)
+		}
+		pre = req.Preprocess
+	} else {
+		if data, _, err = readUploadImage(w, r); err != nil {
+			writeError(w, err)
+			return
+		}
+		if label == "" {
+			label = strings.TrimSpace(r.FormValue("label"))
+		}
+		if pre, err = preprocessFromQuery(r.URL.Query()); err != nil {
+			writeError(w, err)
+			return
+		}
+	}
+	if err := pre.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if pre.Std > 0 {
+		http.Error(w, "mean/std normalization can't be stored as an image", http.StatusBadRequest)
+		return
+	}
+
+	prefix, lbl := "upload", -1
+	if label != "" {
+		n, err := strconv.Atoi(label)
+		if err != nil || n < 0 || n >= numClasses {
+			http.Error(w, "label must be an integer in [0,9]", http.StatusBadRequest)
+			return
+		}
+		prefix, lbl = strconv.Itoa(n), n
+	}
+
+	cpu, _, _ := currentHandles()
+	in := cpu.Input()
+	img, err := decodeImageToInput(bytes.NewReader(data), in.W, in.H)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	img = pre.apply(img)
+
+	id := make([]byte, 6)
+	_, _ = rand.Read(id)
+	name := fmt.Sprintf("%s_%s.png", prefix, hex.EncodeToString(id))
+	if err := writePNG(filepath.Join(imagesDir, name), img); err != nil {
+		http.Error(w, "store image: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	infof("🖼️  stored %s (%dx%d)", name, in.W, in.H)
+
+	res := map[string]any{
+		"image":  name,
+		"url":    "/static/images/" + name,
+		"width":  in.W,
+		"height": in.H,
+	}
+	if lbl >= 0 {
+		res["label"] = lbl
+	}
+	writeJSON(w, http.StatusCreated, res)
+}
+
+// ImageMeta is one entry of /images/list?meta=true.
+type ImageMeta struct {
+	Image      string    `json:"image"`
+	URL        string    `json:"url"`
+	Size       int64     `json:"size"`
+	ModTime    time.Time `json:"mod_time"`
+	Width      int       `json:"width,omitempty"`
+	Height     int       `json:"height,omitempty"`
+	Label      *int      `json:"label,omitempty"`      // labels.json, else the file name ("7_ab12.png")
+	Prediction *int      `json:"prediction,omitempty"` // current model, only when in the result cache
+	Error      string    `json:"error,omitempty"`
+}
+
+// handleImagesList returns the image names, or with ?meta=true one ImageMeta
+// per image so clients don't need a request per file. Predictions are only
+// looked up in the result cache (RESULT_CACHE_SIZE), never computed here;
+// ?backend= picks which backend's results to report (default gpu, cpu when
+// there is no GPU).
+//
+// ?match= (a glob such as "7_*.png") and ?label= filter the list, and
+// ?offset=/?limit= page through it; next_offset is set while more remain.
+// Without a limit every match is returned, as before.
+func handleImagesList(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	offset, err := queryInt(q, "offset", 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	limit, err := queryInt(q, "limit", 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	imgs, err := filterImages(q.Get("match"), q.Get("label"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	total := len(imgs)
+	imgs = imgs[min(offset, total):]
+	if limit > 0 && limit < len(imgs) {
+		imgs = imgs[:limit]
+	}
+	res := map[string]any{"total": total, "offset": offset}
+	if limit > 0 {
+		res["limit"] = limit
+	}
+	if next := offset + len(imgs); next < total {
+		res["next_offset"] = next
+	}
+
+	if meta, _ := strconv.ParseBool(q.Get("meta")); !meta {
+		res["images"] = imgs
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	backend, h, err := pickBackend("", q.Get("backend"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	labels := readLabelsSidecar()
+	out := make([]ImageMeta, 0, len(imgs))
+	for _, name := range imgs {
+		out = append(out, imageMeta(labels, name, backend, h))
+	}
+	res["backend"], res["images"] = backend, out
+	writeJSON(w, http.StatusOK, res)
+}
+
+// queryInt reads a non-negative integer query parameter.
+func queryInt(q url.Values, name string, def int) (int, error) {
+	v := strings.TrimSpace(q.Get(name))
+	if v == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, newHTTPError(http.StatusBadRequest, "bad ?"+name+"=")
+	}
+	return n, nil
+}
+
+// filterImages lists IMAGES_DIR, keeping names that match the glob pattern
+// and carry the given label (either filter may be empty).
+func filterImages(pattern, label string) ([]string, error) {
+	pattern, label = strings.TrimSpace(pattern), strings.TrimSpace(label)
+	want := -1
+	if label != "" {
+		n, err := strconv.Atoi(label)
+		if err != nil || n < 0 || n >= numClasses {
+			return nil, newHTTPError(http.StatusBadRequest, "bad ?label=")
+		}
+		want = n
+	}
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return nil, newHTTPError(http.StatusBadRequest, "bad ?match= pattern")
+	}
+	imgs, _ := listImages()
+	labels := readLabelsSidecar()
+	out := imgs[:0]
+	for _, name := range imgs {
+		if pattern != "" {
+			if ok, _ := filepath.Match(pattern, name); !ok {
+				continue
+			}
+		}
+		if want >= 0 {
+			if lbl, ok := imageLabel(labels, name); !ok || lbl != want {
+				continue
+			}
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
+
+func imageMeta(labels map[string]int, name, backend string, h *ParagonHandle) ImageMeta {
+	m := ImageMeta{Image: name, URL: "/static/images/" + name}
+	if lbl, ok := imageLabel(labels, name); ok {
+		m.Label = &lbl
+	}
+	data, err := os.ReadFile(filepath.Join(imagesDir, name))
+	if err != nil {
+		m.Error = err.Error()
+		return m
+	}
+	m.Size = int64(len(data))
+	if fi, err := os.Stat(filepath.Join(imagesDir, name)); err == nil {
+		m.ModTime = fi.ModTime().UTC()
+	}
+	if cfg, _, err := image.DecodeConfig(bytes.NewReader(data)); err == nil {
+		m.Width, m.Height = cfg.Width, cfg.Height
+	} else {
+		m.Error = "bad image: " + err.Error()
+	}
+	if out, ok := resultCache.get(resultKey(inputKey(data, h.Input()), backend, h, Preprocess{})); ok {
+		pred := out.Pred
+		m.Prediction = &pred
+	}
+	return m
+}
+
+// STATIC_MAX_AGE is how long clients may reuse a /static/images/ file
+// without revalidating (Go duration, default 1h, 0 = always revalidate).
+var staticMaxAge = getEnvDuration("STATIC_MAX_AGE", time.Hour)
+
+// staticImages serves IMAGES_DIR with an ETag and Cache-Control on every
+// file. http.FileServer adds Last-Modified and answers If-None-Match and
+// If-Modified-Since with 304.
+func staticImages() http.Handler {
+	fs := http.FileServer(http.Dir(imagesDir))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := filepath.Join(imagesDir, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+		if st, err := os.Stat(name); err == nil && st.Mode().IsRegular() {
+			// size and mtime change whenever an upload replaces the file
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, st.Size(), st.ModTime().UnixNano()))
+			if staticMaxAge > 0 {
+				w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(staticMaxAge.Seconds())))
+			} else {
+				w.Header().Set("Cache-Control", "no-cache")
+			}
+		}
+		fs.ServeHTTP(w, r)
+	})
+}
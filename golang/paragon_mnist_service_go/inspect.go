@@ -0,0 +1,85 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// handleInspect returns one layer's activations for an image:
+// /inspect?image=3.png&layer=1[&backend=cpu][&model=name]. layer defaults to
+// the output layer; negative values count from the end.
+func handleInspect(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	image := strings.TrimSpace(q.Get("image"))
+	if image == "" {
+		http.Error(w, "missing ?image=", http.StatusBadRequest)
+		return
+	}
+	backend := strings.ToLower(strings.TrimSpace(q.Get("backend")))
+	if backend == "" {
+		backend = "cpu"
+	}
+	h, err := pickModelHandle(strings.TrimSpace(q.Get("model")), backend)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	layers := h.NumLayers()
+	layer := layers - 1
+	if v := strings.TrimSpace(q.Get("layer")); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			http.Error(w, "bad ?layer=", http.StatusBadRequest)
+			return
+		}
+		if n < 0 {
+			n += layers
+		}
+		layer = n
+	}
+
+	path, err := imagePath(image)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if ok, _ := fileExists(path); !ok {
+		http.Error(w, "image not found: "+image, http.StatusNotFound)
+		return
+	}
+	img, err := loadImageToInput(path, h.Input().W, h.Input().H)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	vals, act, err := h.InferLayer(img, layer)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	lo, hi, sum, count := math.Inf(1), math.Inf(-1), 0.0, 0
+	for _, row := range vals {
+		for _, v := range row {
+			lo, hi, sum = math.Min(lo, v), math.Max(hi, v), sum+v
+			count++
+		}
+	}
+	stats := map[string]any{}
+	if count > 0 {
+		stats = map[string]any{"min": lo, "max": hi, "mean": round6(sum / float64(count))}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"backend":     backend,
+		"image":       image,
+		"layer":       layer,
+		"layers":      layers,
+		"width":       len(vals[0]),
+		"height":      len(vals),
+		"activation":  act,
+		"activations": vals,
+		"stats":       stats,
+	})
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Long-running work (training, ?async=true evaluation and parity, dataset
+// downloads) runs as a job listed under /jobs. Finished jobs are kept for
+// JOB_RETENTION (Go duration, default 1h, 0 = until evicted), and at most
+// JOB_MAX_FINISHED of them, oldest evicted first.
+var (
+	jobRetention   = getEnvDuration("JOB_RETENTION", time.Hour)
+	jobMaxFinished = max(getEnvInt("JOB_MAX_FINISHED", 100), 1)
+)
+
+const (
+	jobRunning  = "running"
+	jobDone     = "done"
+	jobFailed   = "failed"
+	jobCanceled = "canceled"
+)
+
+type Job struct {
+	ID         string           `json:"id"`
+	Kind       string           `json:"kind"`     // "train" | "evaluate" | "parity" | "dataset"
+	Status     string           `json:"status"`   // "running" | "done" | "failed" | "canceled"
+	Progress   float64          `json:"progress"` // 0..1
+	Message    string           `json:"message,omitempty"`
+	Detail     any              `json:"detail,omitempty"` // live kind-specific state while running, e.g. the TrainJob
+	Result     any              `json:"result,omitempty"` // once finished, when the job has one
+	Error      string           `json:"error,omitempty"`
+	Canceling  bool             `json:"canceling,omitempty"`
+	Callback   *WebhookDelivery `json:"callback,omitempty"`
+	CreatedAt  time.Time        `json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at,omitempty"`
+
+	cancel   context.CancelFunc
+	callback string
+	state    any                   // kind-specific, e.g. the *TrainJob
+	live     func() (any, float64) // Detail and Progress, for jobs that track their own state
+}
+
+var (
+	jobsMu sync.Mutex // guards jobs, jobSeq and every Job's exported fields
+	jobs   = map[string]*Job{}
+	jobSeq int
+)
+
+// newJob registers a running job of kind and the context that DELETE
+// /jobs/{id} cancels. callback, when set, is notified when the job ends;
+// live, when set, reports the Detail and Progress of jobs that track their
+// own state.
+func newJob(kind, callback string, state any, live func() (any, float64)) (*Job, context.Context) {
+	ctx, cancel := context.WithCancel(context.Background())
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	pruneJobs(time.Now())
+	if jobSeq == 0 && checkpointDir != "" {
+		jobSeq = lastCheckpointJob() // checkpoints are named after their job
+	}
+	jobSeq++
+	j := &Job{
+		ID:        strconv.Itoa(jobSeq),
+		Kind:      kind,
+		Status:    jobRunning,
+		CreatedAt: time.Now().UTC(),
+		cancel:    cancel,
+		callback:  callback,
+		state:     state,
+		live:      live,
+	}
+	if callback != "" {
+		j.Callback = &WebhookDelivery{URL: callback, Status: "pending"}
+	}
+	jobs[j.ID] = j
+	return j, ctx
+}
+
+// start runs fn in the background and records its result. An error after
+// the job was canceled marks it canceled rather than failed.
+func (j *Job) start(ctx context.Context, fn func(ctx context.Context) (any, error)) {
+	go func() {
+		res, err := fn(ctx)
+		j.finish(ctx, res, err)
+	}()
+}
+
+func (j *Job) finish(ctx context.Context, res any, err error) {
+	jobsMu.Lock()
+	now := time.Now().UTC()
+	j.FinishedAt, j.Canceling, j.Result = &now, false, res
+	switch {
+	case err != nil && ctx.Err() != nil:
+		j.Status, j.Error = jobCanceled, "canceled"
+	case err != nil:
+		j.Status, j.Error = jobFailed, err.Error()
+	default:
+		j.Status, j.Progress, j.Message = jobDone, 1, ""
+	}
+	j.cancel()
+	jobsMu.Unlock()
+	debugf("%s job %s %s", j.Kind, j.ID, j.Status)
+
+	if j.callback != "" {
+		v := j.view()
+		v.Callback = nil
+		sendWebhook(j.callback, j.Kind+"."+v.Status, v, func(d WebhookDelivery) {
+			jobsMu.Lock()
+			j.Callback = &d
+			jobsMu.Unlock()
+		})
+	}
+}
+
+// setProgress reports how far the job is, 0..1, with an optional message.
+func (j *Job) setProgress(p float64, msg string) {
+	jobsMu.Lock()
+	j.Progress, j.Message = round6(min(max(p, 0), 1)), msg
+	jobsMu.Unlock()
+}
+
+// view is a copy of j safe to serialize.
+func (j *Job) view() Job {
+	jobsMu.Lock()
+	v := *j
+	jobsMu.Unlock()
+	if v.live != nil && v.Status == jobRunning {
+		// outside jobsMu: live takes the job's own lock
+		v.Detail, v.Progress = v.live()
+		v.Progress = round6(v.Progress)
+	}
+	return v
+}
+
+// lookupJob returns the job id, optionally only of kind.
+func lookupJob(id, kind string) *Job {
+	jobsMu.Lock()
+	defer jobsMu.Unlock()
+	if j := jobs[id]; j != nil && (kind == "" || j.Kind == kind) {
+		return j
+	}
+	return nil
+}
+
+// pruneJobs drops finished jobs past JOB_RETENTION, then the oldest beyond
+// JOB_MAX_FINISHED. jobsMu must be held.
+func pruneJobs(now time.Time) {
+	var finished []*Job
+	for id, j := range jobs {
+		if j.FinishedAt == nil {
+			continue
+		}
+		if jobRetention > 0 && now.Sub(*j.FinishedAt) > jobRetention {
+			delete(jobs, id)
+			continue
+		}
+		finished = append(finished, j)
+	}
+	if len(finished) <= jobMaxFinished {
+		return
+	}
+	sort.Slice(finished, func(a, b int) bool { return finished[a].FinishedAt.Before(*finished[b].FinishedAt) })
+	for _, j := range finished[:len(finished)-jobMaxFinished] {
+		delete(jobs, j.ID)
+	}
+}
+
+// asyncParams reads ?async=true and ?callback_url= for endpoints that can
+// run as a job.
+func asyncParams(q url.Values) (bool, string, error) {
+	async := strings.EqualFold(strings.TrimSpace(q.Get("async")), "true")
+	callback := strings.TrimSpace(q.Get("callback_url"))
+	if callback == "" {
+		return async, "", nil
+	}
+	if !async {
+		return false, "", newHTTPError(http.StatusBadRequest, "?callback_url= needs ?async=true")
+	}
+	return true, callback, checkCallbackURL(callback)
+}
+
+// writeJobAccepted answers a request that started j.
+func writeJobAccepted(w http.ResponseWriter, j *Job) {
+	writeJSON(w, http.StatusAccepted, map[string]any{"id": j.ID, "kind": j.Kind, "status_url": apiPrefix + "/jobs/" + j.ID})
+}
+
+// handleJobs lists jobs, newest first, optionally filtered by ?kind= and
+// ?status=. Results are left out; GET /jobs/{id} has them.
+func handleJobs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	kind, status := strings.TrimSpace(q.Get("kind")), strings.TrimSpace(q.Get("status"))
+	jobsMu.Lock()
+	pruneJobs(time.Now())
+	all := make([]*Job, 0, len(jobs))
+	for _, j := range jobs {
+		all = append(all, j)
+	}
+	jobsMu.Unlock()
+
+	out := []Job{}
+	for _, j := range all {
+		v := j.view()
+		if (kind != "" && v.Kind != kind) || (status != "" && v.Status != status) {
+			continue
+		}
+		v.Result, v.Detail = nil, nil
+		out = append(out, v)
+	}
+	sort.Slice(out, func(a, b int) bool {
+		x, _ := strconv.Atoi(out[a].ID)
+		y, _ := strconv.Atoi(out[b].ID)
+		return x > y
+	})
+	writeJSON(w, http.StatusOK, map[string]any{"jobs": out})
+}
+
+func handleJob(w http.ResponseWriter, r *http.Request) {
+	j := lookupJob(r.PathValue("id"), "")
+	if j == nil {
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, j.view())
+}
+
+// handleJobDelete cancels a running job, answering 202 while it winds down,
+// or forgets a finished one.
+func handleJobDelete(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	jobsMu.Lock()
+	j := jobs[id]
+	if j == nil {
+		jobsMu.Unlock()
+		http.Error(w, "unknown job", http.StatusNotFound)
+		return
+	}
+	if j.Status != jobRunning {
+		delete(jobs, id)
+		jobsMu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	j.Canceling = true
+	j.cancel()
+	jobsMu.Unlock()
+	infof("🛑 canceling %s job %s", j.Kind, j.ID)
+	writeJSON(w, http.StatusAccepted, j.view())
+}
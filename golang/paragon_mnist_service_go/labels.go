@@ -0,0 +1,199 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ground truth for stored images comes from IMAGES_DIR/labels.json
+// ({"photo.png": 7, ...}) or, failing that, the file name convention
+// "<label>_*.png" used by POST /images and the autopopulated samples.
+const labelsSidecar = "labels.json"
+
+var labelsMu sync.Mutex // serializes sidecar writes
+
+func readLabelsSidecar() map[string]int {
+	b, err := os.ReadFile(filepath.Join(imagesDir, labelsSidecar))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			warnf("read %s: %v", labelsSidecar, err)
+		}
+		return nil
+	}
+	var labels map[string]int
+	if err := json.Unmarshal(b, &labels); err != nil {
+		warnf("%s ignored: %v", labelsSidecar, err)
+		return nil
+	}
+	return labels
+}
+
+// imageLabel returns the ground truth of name, preferring the sidecar.
+func imageLabel(labels map[string]int, name string) (int, bool) {
+	if lbl, ok := labels[name]; ok && lbl >= 0 && lbl < numClasses {
+		return lbl, true
+	}
+	return labelFromName(name)
+}
+
+type LabelRequest struct {
+	Label *int `json:"label"` // null removes the sidecar entry
+}
+
+// handleImageLabel sets or clears the sidecar label of a stored image:
+// POST /images/{name}/label {"label": 7}.
+func handleImageLabel(w http.ResponseWriter, r *http.Request) {
+	if !imageUploadOn {
+		http.Error(w, "image upload disabled (set IMAGE_UPLOAD_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	name := r.PathValue("name")
+	path, err := imagePath(name)
+	if err != nil || !imageExts[filepath.Ext(stringsLower(name))] {
+		http.Error(w, "bad image name", http.StatusBadRequest)
+		return
+	}
+	if ok, _ := fileExists(path); !ok {
+		http.Error(w, "image not found: "+name, http.StatusNotFound)
+		return
+	}
+	var req LabelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if req.Label != nil && (*req.Label < 0 || *req.Label >= numClasses) {
+		writeError(w, newFieldError("label", "invalid", "label must be an integer in [0,9]"))
+		return
+	}
+
+	labelsMu.Lock()
+	defer labelsMu.Unlock()
+	labels := readLabelsSidecar()
+	if labels == nil {
+		labels = map[string]int{}
+	}
+	if req.Label == nil {
+		delete(labels, name)
+	} else {
+		labels[name] = *req.Label
+	}
+	if err := writeLabelsSidecar(labels); err != nil {
+		http.Error(w, "save labels: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res := map[string]any{"image": name}
+	if lbl, ok := imageLabel(labels, name); ok {
+		res["label"] = lbl
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+// writeLabelsSidecar replaces labels.json atomically.
+func writeLabelsSidecar(labels map[string]int) error {
+	b, err := json.MarshalIndent(labels, "", "  ")
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(imagesDir, labelsSidecar)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// BackendAccuracy is one backend's score in /accuracy.
+type BackendAccuracy struct {
+	Correct  int     `json:"correct"`
+	Total    int     `json:"total"` // labeled images that produced a prediction
+	Failed   int     `json:"failed"`
+	Accuracy float64 `json:"accuracy"`
+	Error    string  `json:"error,omitempty"` // backend unavailable
+}
+
+// handleAccuracy scores every labeled image on each backend of the current
+// model. Predictions go through the normal path, so repeated calls are
+// served from the input and result caches where enabled.
+func handleAccuracy(w http.ResponseWriter, r *http.Request) {
+	model := r.URL.Query().Get("model")
+	if _, _, _, err := modelHandles(model); err != nil {
+		writeError(w, err)
+		return
+	}
+	opts, err := predictOpts{Model: model}.normalize()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	imgs, labeled, labels := labeledImages()
+	start := time.Now()
+	backends := map[string]*BackendAccuracy{}
+	for _, backend := range []string{"cpu", "gpu"} {
+		if _, err := pickModelHandle(model, backend); err != nil {
+			backends[backend] = &BackendAccuracy{Error: err.Error()}
+			continue
+		}
+		acc, err := scoreLabeled(r.Context(), model, backend, opts, labeled, labels)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		backends[backend] = acc
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"model":       model,
+		"labeled":     len(labeled),
+		"unlabeled":   imgs - len(labeled),
+		"backends":    backends,
+		"latency_sec": round6(time.Since(start).Seconds()),
+	})
+}
+
+// labeledImages returns how many images IMAGES_DIR holds and the sorted names
+// of those with a ground-truth label.
+func labeledImages() (int, []string, map[string]int) {
+	imgs, _ := listImages()
+	labels := readLabelsSidecar()
+	var labeled []string
+	for _, name := range imgs {
+		if _, ok := imageLabel(labels, name); ok {
+			labeled = append(labeled, name)
+		}
+	}
+	sort.Strings(labeled)
+	return len(imgs), labeled, labels
+}
+
+// scoreLabeled predicts every labeled image on one backend of model; it only
+// fails when ctx ends.
+func scoreLabeled(ctx context.Context, model, backend string, opts predictOpts, labeled []string, labels map[string]int) (*BackendAccuracy, error) {
+	acc := &BackendAccuracy{}
+	for _, name := range labeled {
+		if err := ctx.Err(); err != nil {
+			return nil, ctxError(err)
+		}
+		lbl, _ := imageLabel(labels, name)
+		res, err := predictCore(ctx, name, backend, opts)
+		if err != nil {
+			acc.Failed++
+			continue
+		}
+		acc.Total++
+		if res["prediction"] == lbl {
+			acc.Correct++
+		}
+	}
+	if acc.Total > 0 {
+		acc.Accuracy = round6(float64(acc.Correct) / float64(acc.Total))
+	}
+	return acc, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// POST /learn adapts the served model to single labeled images, e.g. a
+// user's own handwriting during a demo. Every sample goes into a replay
+// buffer of the last LEARN_REPLAY_SIZE; every LEARN_REPLAY_EVERY (Go
+// duration, default 10s, 0 = off) a background pass trains on the samples
+// queued since the previous one plus older ones, up to LEARN_REPLAY_BATCH,
+// so earlier corrections aren't forgotten.
+var (
+	learnReplaySize  = max(getEnvInt("LEARN_REPLAY_SIZE", 256), 1)
+	learnReplayBatch = max(getEnvInt("LEARN_REPLAY_BATCH", 16), 1)
+	learnReplayEvery = getEnvDuration("LEARN_REPLAY_EVERY", 10*time.Second)
+)
+
+type LearnRequest struct {
+	Image        string     `json:"image"`     // file in IMAGES_DIR
+	ImageB64     string     `json:"image_b64"` // base64 PNG/JPEG/BMP/WebP or data URL, used instead of image
+	Label        *int       `json:"label"`
+	Mode         string     `json:"mode"`          // "step" (default): train now | "replay": queue for the background pass
+	LearningRate float64    `json:"learning_rate"` // default 0.01
+	Steps        int        `json:"steps"`         // step: gradient steps on the sample, default 1
+	Preprocess   Preprocess `json:"preprocess"`    // applied before training, as in /predict
+}
+
+var learnDefaults = LearnRequest{Mode: "step", LearningRate: 0.01, Steps: 1}
+
+const maxLearnSteps = 50
+
+func (req *LearnRequest) normalize() error {
+	req.Mode = strings.ToLower(strings.TrimSpace(req.Mode))
+	switch {
+	case req.Label == nil:
+		return newFieldError("label", "required", "label is required")
+	case *req.Label < 0 || *req.Label >= numClasses:
+		return newFieldError("label", "invalid", "label must be an integer in [0,9]")
+	case req.Mode != "step" && req.Mode != "replay":
+		return newFieldError("mode", "invalid", "mode must be step or replay")
+	case req.Mode == "replay" && learnReplayEvery <= 0:
+		return newHTTPError(http.StatusConflict, "replay disabled (set LEARN_REPLAY_EVERY)")
+	case !(req.LearningRate > 0) || req.LearningRate > 1:
+		return newFieldError("learning_rate", "invalid", "learning_rate must be in (0,1]")
+	case req.Steps < 1 || req.Steps > maxLearnSteps:
+		return newFieldError("steps", "invalid", fmt.Sprintf("steps must be in [1,%d]", maxLearnSteps))
+	}
+	return req.Preprocess.validate()
+}
+
+// learnSample is a decoded H×W input and its label.
+type learnSample struct {
+	img   [][]float64
+	label int
+}
+
+// replay is the ring of recent samples; the newest pending ones haven't been
+// trained on yet.
+var replay struct {
+	mu      sync.Mutex
+	buf     []learnSample
+	next    int // slot the next sample goes into once buf is full
+	pending int
+	passes  int
+	last    time.Time
+	lr      float64 // learning rate of the newest queued sample
+}
+
+// ReplayStats is returned by POST /learn.
+type ReplayStats struct {
+	Size     int    `json:"size"`
+	Capacity int    `json:"capacity"`
+	Pending  int    `json:"pending"`
+	Passes   int    `json:"passes"`
+	LastPass string `json:"last_pass,omitempty"`
+}
+
+func replayStats() ReplayStats {
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	s := ReplayStats{Size: len(replay.buf), Capacity: learnReplaySize, Pending: replay.pending, Passes: replay.passes}
+	if !replay.last.IsZero() {
+		s.LastPass = replay.last.UTC().Format(time.RFC3339)
+	}
+	return s
+}
+
+func addReplay(s learnSample, pending bool, lr float64) {
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	if len(replay.buf) < learnReplaySize {
+		replay.buf = append(replay.buf, s)
+	} else {
+		replay.buf[replay.next] = s
+		replay.next = (replay.next + 1) % learnReplaySize
+	}
+	if pending {
+		replay.pending = min(replay.pending+1, len(replay.buf))
+		replay.lr = lr
+	}
+}
+
+// replayBatch takes the pending samples, newest first, topped up with random
+// older ones to at most LEARN_REPLAY_BATCH; nil when nothing is pending.
+func replayBatch() ([]learnSample, float64) {
+	replay.mu.Lock()
+	defer replay.mu.Unlock()
+	n := len(replay.buf)
+	if replay.pending == 0 || n == 0 {
+		return nil, 0
+	}
+	newest := (replay.next - 1 + n) % n
+	if n < learnReplaySize {
+		newest = n - 1
+	}
+	batch := make([]learnSample, 0, min(learnReplayBatch, n))
+	for i := 0; i < replay.pending && len(batch) < learnReplayBatch; i++ {
+		batch = append(batch, replay.buf[(newest-i+n)%n])
+	}
+	older := n - replay.pending
+	for _, i := range rand.Perm(older) {
+		if len(batch) == cap(batch) {
+			break
+		}
+		batch = append(batch, replay.buf[(newest-replay.pending-i+n)%n])
+	}
+	replay.pending = 0
+	return batch, replay.lr
+}
+
+// learnMu serializes /learn steps and replay passes, each of which trains a
+// copy of the served model and swaps it in.
+var learnMu sync.Mutex
+
+// learnOn trains a copy of base on samples for steps passes and serves it,
+// unless the model was replaced meanwhile. It returns the new CPU handle.
+// Samples decoded for a model with another input size are skipped.
+func learnOn(base *ParagonHandle, samples []learnSample, lr float64, steps int) (*ParagonHandle, error) {
+	in := base.Input()
+	samples = slices.DeleteFunc(slices.Clone(samples), func(s learnSample) bool {
+		return len(s.img) != in.H || len(s.img[0]) != in.W
+	})
+	if len(samples) == 0 {
+		return nil, newHTTPError(http.StatusConflict, "no samples match the model's input size")
+	}
+	snap, err := base.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	nn, err := networkFromSnapshot(snap)
+	if err != nil {
+		return nil, err
+	}
+	out := snap.shapes[len(snap.shapes)-1]
+	inputs := make([][][]float64, len(samples))
+	targets := make([][][]float64, len(samples))
+	for i, s := range samples {
+		inputs[i] = s.img
+		if in.Flat {
+			inputs[i] = flatten(s.img)
+		}
+		targets[i] = oneHot(s.label, out.Width, out.Height)
+	}
+	for range steps {
+		nn.train(inputs, targets, lr, trainDefaults.Clip)
+	}
+	if snap.state, err = nn.MarshalJSONModel(); err != nil {
+		return nil, err
+	}
+	if err := swapTrained(base, snap); err != nil {
+		return nil, newHTTPError(http.StatusConflict, err.Error())
+	}
+	cpu, _, _ := currentHandles()
+	return cpu, nil
+}
+
+// learnable reports why the served model can't learn online right now.
+func learnable(h *ParagonHandle) error {
+	trainMu.Lock()
+	busy := trainBusy
+	trainMu.Unlock()
+	if busy {
+		// its result would be discarded once the model changes under it
+		return newHTTPError(http.StatusConflict, "a training job is running")
+	}
+	layers := h.Info().Layers
+	if out := layers[len(layers)-1]; out.Width*out.Height != numClasses {
+		return newHTTPError(http.StatusBadRequest, fmt.Sprintf("output layer has %d units, want %d", out.Width*out.Height, numClasses))
+	}
+	return nil
+}
+
+// handleLearn takes one labeled image: mode "step" trains on it right away
+// and answers with the prediction before and after, "replay" queues it for
+// the next background pass.
+func handleLearn(w http.ResponseWriter, r *http.Request) {
+	if !trainingOn {
+		http.Error(w, "training disabled (set TRAINING_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	req := learnDefaults
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := req.normalize(); err != nil {
+		writeError(w, err)
+		return
+	}
+	hasName, hasB64 := strings.TrimSpace(req.Image) != "", strings.TrimSpace(req.ImageB64) != ""
+	if hasName == hasB64 {
+		writeError(w, newFieldError("image", "invalid", "send one of image or image_b64"))
+		return
+	}
+	cpu, _, _ := currentHandles()
+	if err := learnable(cpu); err != nil {
+		writeError(w, err)
+		return
+	}
+	var data []byte
+	var err error
+	if hasB64 {
+		data, err = decodeImageB64(req.ImageB64)
+	} else if path, perr := imagePath(strings.TrimSpace(req.Image)); perr != nil {
+		err = perr
+	} else if data, err = os.ReadFile(path); err != nil && os.IsNotExist(err) {
+		http.Error(w, "image not found: "+strings.TrimSpace(req.Image), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	img, err := decodeImageToInput(bytes.NewReader(data), cpu.Input().W, cpu.Input().H)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	s := learnSample{img: req.Preprocess.apply(img), label: *req.Label}
+
+	if req.Mode == "replay" {
+		addReplay(s, true, req.LearningRate)
+		writeJSON(w, http.StatusAccepted, map[string]any{"queued": true, "label": s.label, "replay": replayStats()})
+		return
+	}
+
+	learnMu.Lock()
+	defer learnMu.Unlock()
+	start := time.Now()
+	before, err := forwardProbs(cpu, s.img)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	next, err := learnOn(cpu, []learnSample{s}, req.LearningRate, req.Steps)
+	if err != nil {
+		http.Error(w, "learn failed: "+err.Error(), httpStatus(err))
+		return
+	}
+	after, err := forwardProbs(next, s.img)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if learnReplayEvery > 0 {
+		addReplay(s, false, 0)
+	}
+	debugf("learn: label %d, pred %d → %d, p(label) %.3f → %.3f", s.label, before.Pred, after.Pred, before.Probs[s.label], after.Probs[s.label])
+	writeJSON(w, http.StatusOK, map[string]any{
+		"label":     s.label,
+		"mode":      req.Mode,
+		"steps":     req.Steps,
+		"before":    map[string]any{"pred": before.Pred, "prob": round6(before.Probs[s.label])},
+		"after":     map[string]any{"pred": after.Pred, "prob": round6(after.Probs[s.label])},
+		"learn_sec": round6(time.Since(start).Seconds()),
+		"replay":    replayStats(),
+	})
+}
+
+// startReplay runs the background replay passes.
+func startReplay() {
+	if !trainingOn || learnReplayEvery <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(learnReplayEvery) {
+			batch, lr := replayBatch()
+			if batch == nil {
+				continue
+			}
+			learnMu.Lock()
+			cpu, _, _ := currentHandles()
+			err := learnable(cpu)
+			if err == nil {
+				_, err = learnOn(cpu, batch, lr, 1)
+			}
+			learnMu.Unlock()
+			if err != nil {
+				warnf("learn replay of %d samples skipped: %v", len(batch), err)
+				continue
+			}
+			replay.mu.Lock()
+			replay.passes++
+			replay.last = time.Now()
+			replay.mu.Unlock()
+			debugf("learn replay: trained on %d samples", len(batch))
+		}
+	}()
+}
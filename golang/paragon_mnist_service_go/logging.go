@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// LOG_LEVEL=debug|info|warn|error (default info)
+// LOG_FORMAT=text|json (default text)
+var logger = newLogger(getEnv("LOG_LEVEL", "info"), getEnv("LOG_FORMAT", "text"))
+
+func parseLogLevel(s string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	}
+	return slog.LevelInfo
+}
+
+func newLogger(level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: parseLogLevel(level)}
+	if strings.EqualFold(strings.TrimSpace(format), "json") {
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, opts))
+}
+
+func logf(l slog.Level, format string, args ...any) {
+	if !logger.Enabled(context.Background(), l) {
+		return
+	}
+	logger.Log(context.Background(), l, fmt.Sprintf(format, args...))
+}
+
+func debugf(format string, args ...any) { logf(slog.LevelDebug, format, args...) }
+func infof(format string, args ...any)  { logf(slog.LevelInfo, format, args...) }
+func warnf(format string, args ...any)  { logf(slog.LevelWarn, format, args...) }
+func errorf(format string, args ...any) { logf(slog.LevelError, format, args...) }
+
+// fatalf logs at error level regardless of LOG_LEVEL and exits.
+func fatalf(format string, args ...any) {
+	logger.Error(fmt.Sprintf(format, args...), "fatal", true)
+	os.Exit(1)
+}
+
+// requestInfo collects fields for the access log line; handlers on the
+// prediction path fill in backend and image through annotateRequest.
+type requestInfo struct {
+	id      string
+	backend string
+	image   string
+}
+
+type requestInfoKey struct{}
+
+func annotateRequest(ctx context.Context, backend, image string) {
+	if ri, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		ri.backend, ri.image = backend, image
+	}
+}
+
+// requestID returns the ID of the request in ctx, or "".
+func requestID(ctx context.Context) string {
+	if ri, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		return ri.id
+	}
+	return ""
+}
+
+// withRequestLog assigns each request an ID (reusing X-Request-ID when the
+// client sends one), echoes it in the response header and logs one line per
+// request.
+func withRequestLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get("X-Request-ID"))
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 8)
+			_, _ = rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		ri := &requestInfo{id: id, backend: r.URL.Query().Get("backend"), image: r.URL.Query().Get("image")}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		start := time.Now()
+		next.ServeHTTP(rec, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, ri)))
+		recordRequest(r.URL.Path, ri.backend, rec.status, time.Since(start))
+
+		level := slog.LevelInfo
+		switch {
+		case rec.status >= 500:
+			level = slog.LevelError
+		case probePaths[r.URL.Path]:
+			level = slog.LevelDebug // probes would drown everything else
+		}
+		attrs := []any{
+			"request_id", id,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"latency_ms", round6(float64(time.Since(start).Microseconds()) / 1000),
+		}
+		if ri.backend != "" {
+			attrs = append(attrs, "backend", ri.backend)
+		}
+		if ri.image != "" {
+			attrs = append(attrs, "image", ri.image)
+		}
+		logger.Log(r.Context(), level, "request", attrs...)
+	})
+}
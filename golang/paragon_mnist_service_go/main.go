@@ -1,37 +1,113 @@
 package main
 
 import (
-	"encoding/json"
-	"log"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
-	"path/filepath"
-	"sort"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type PredictRequest struct {
-	Image   string `json:"image"`
-	Backend string `json:"backend"` // "gpu" | "cpu"
+	Image    string `json:"image"`
+	ImageB64 string `json:"image_b64"` // base64 PNG/JPEG/BMP/WebP, used instead of image
+	Backend  string `json:"backend"`   // "auto" (default) | "gpu" | "cpu" | "cpu-int8" | "ensemble"
+	RawProbs bool   `json:"raw_probs"` // include uncalibrated probabilities
+	TopK     int    `json:"topk"`      // size of top_k, default 1
+	Model    string `json:"model"`     // registry name (MODELS_DIR), default model if empty
+
+	Preprocess Preprocess `json:"preprocess"`
+}
+
+type BatchPredictRequest struct {
+	Images   []string      `json:"images"`
+	Tensors  [][][]float64 `json:"tensors"` // inline HxW inputs in [0,1], scored after images
+	Backend  string        `json:"backend"`
+	RawProbs bool          `json:"raw_probs"`
+	TopK     int           `json:"topk"`
+
+	Preprocess Preprocess `json:"preprocess"`
 }
 
 type ProbResult struct {
 	Pred       int       `json:"pred"`
 	Probs      []float64 `json:"probs"`
+	RawProbs   []float64 `json:"raw_probs,omitempty"` // pre-calibration, when calibrated
 	LatencySec float64   `json:"latency_sec"`
+	Warnings   []string  `json:"warnings,omitempty"`
+}
+
+// predictOpts are per-request knobs shared by the prediction endpoints.
+type predictOpts struct {
+	RawProbs bool
+	TopK     int
+	Model    string // registry name, "" for the default model
+	Pre      Preprocess
+}
+
+const numClasses = 10
+
+// normalize defaults TopK to 1 and caps it at the number of classes.
+func (o predictOpts) normalize() (predictOpts, error) {
+	switch {
+	case o.TopK == 0:
+		o.TopK = 1
+	case o.TopK < 0:
+		return o, newFieldError("topk", "invalid", "topk must be >= 1")
+	case o.TopK > numClasses:
+		o.TopK = numClasses
+	}
+	return o, o.Pre.validate()
+}
+
+// optsFromQuery reads ?raw_probs=, ?topk=, ?model= and the preprocessing params.
+func optsFromQuery(q url.Values) (predictOpts, error) {
+	var o predictOpts
+	var err error
+	if o.Pre, err = preprocessFromQuery(q); err != nil {
+		return o, err
+	}
+	o.RawProbs, _ = strconv.ParseBool(q.Get("raw_probs"))
+	o.Model = strings.TrimSpace(q.Get("model"))
+	if v := strings.TrimSpace(q.Get("topk")); v != "" {
+		k, err := strconv.Atoi(v)
+		if err != nil {
+			return o, newHTTPError(http.StatusBadRequest, "bad ?topk=")
+		}
+		o.TopK = k
+	}
+	return o.normalize()
 }
 
 type ParityRow struct {
-	Image string      `json:"image"`
-	CPU   *ProbResult `json:"cpu,omitempty"`
-	GPU   *ProbResult `json:"gpu,omitempty"`
-	Match *bool       `json:"match,omitempty"`
-	Error string      `json:"error,omitempty"`
+	Image      string      `json:"image"`           // file name, or "<split>#<index>" for IDX samples
+	Label      *int        `json:"label,omitempty"` // IDX samples only
+	CPU        *ProbResult `json:"cpu,omitempty"`
+	GPU        *ProbResult `json:"gpu,omitempty"`
+	Match      *bool       `json:"match,omitempty"` // argmax agreement
+	MAE        *float64    `json:"mae,omitempty"`
+	MaxAbsDiff *float64    `json:"max_abs_diff,omitempty"`
+	MaxRelDiff *float64    `json:"max_rel_diff,omitempty"` // |cpu-gpu| / |gpu|, largest over classes
+	WithinTol  *bool       `json:"within_tol,omitempty"`
+	Error      string      `json:"error,omitempty"`
 }
 
 type ParityReport struct {
+	Model        string      `json:"model,omitempty"`
+	Source       string      `json:"source"`         // "images" | "test" | "train"
+	Seed         *uint64     `json:"seed,omitempty"` // sampling seed, to repeat an IDX run
 	GPUAvailable bool        `json:"gpu_available"`
-	Mismatches   int         `json:"mismatches"`
+	Tolerance    float64     `json:"tolerance"`     // absolute
+	RelTolerance float64     `json:"rel_tolerance"` // relative to the GPU value
+	Mismatches   int         `json:"mismatches"`    // argmax differs or outside tolerance
 	Total        int         `json:"total"`
 	Results      []ParityRow `json:"results"`
 }
@@ -40,53 +116,179 @@ type ParityReport struct {
 var (
 	imagesDir = getEnv("IMAGES_DIR", "./images")
 	modelJSON = getEnv("MODEL_JSON", "./mnist_paragon_model.json")
+	calibJSON = getEnv("CALIBRATION_JSON", "./calibration.json")
 	hCPU      *ParagonHandle
 	hGPU      *ParagonHandle
 	gpuOK     bool
+	prevCPU   *ParagonHandle // CPU handle of the model served before the last swap
+
+	healthOnly  = getEnvBool("HEALTH_ONLY", false)
+	occlusionOn = getEnvBool("OCCLUSION_ENABLED", false)
+	trainingOn  = getEnvBool("TRAINING_ENABLED", false)
+	batchMax    = getEnvInt("PREDICT_BATCH_MAX", 256)
+
+	ensembleConflict = ensemblePolicy(getEnv("ENSEMBLE_CONFLICT", conflictAverage))
+)
+
+// occlusion patch/stride bounds; a stride below 2 is too many forwards per request
+const (
+	minOcclusionPatch  = 2
+	maxOcclusionPatch  = 14
+	minOcclusionStride = 2
 )
 
 func main() {
+	addr := getEnv("ADDR", "0.0.0.0:8003")
+
+	// HEALTH_ONLY=true: bind the port and answer probes without loading
+	// models or downloading datasets (smoke tests, liveness sidecars)
+	if healthOnly {
+		http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, http.StatusOK, map[string]any{"ok": true, "mode": "health-only"})
+		})
+		http.HandleFunc("/livez", handleLivez)
+		http.HandleFunc("/readyz", func(w http.ResponseWriter, _ *http.Request) {
+			writeJSON(w, http.StatusServiceUnavailable, map[string]any{"ready": false, "mode": "health-only"})
+		})
+		http.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "service is running in health-only mode (HEALTH_ONLY=true); models not loaded", http.StatusServiceUnavailable)
+		})
+		infof("🩺 Health-only mode, listening on %s://%s", scheme(), addr)
+		if err := serve(addr, withCORS(withRequestLog(withTimeout(withPprofGuard(http.DefaultServeMux))))); err != nil {
+			fatalf("listen: %v", err)
+		}
+		return
+	}
+
+	// Probes answer from the start; everything else gets 503 from
+	// withStartupGate until the API is registered below.
+	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "ready": startup.done.Load(), "gpu_available": gpuAvailable(), "gpu_backend": activeGPUBackend()})
+	})
+	http.HandleFunc("/livez", handleLivez)
+	http.HandleFunc("/readyz", handleReadyz) // 503 until models are loaded and images present
+	go func() {
+		if err := serve(addr, withCORS(withRequestLog(withAuth(withCompression(withTimeout(withBodyLimits(withPprofGuard(withStartupGate(http.DefaultServeMux))))))))); err != nil {
+			fatalf("listen: %v", err)
+		}
+	}()
+	infof("🚀 Listening on %s://%s (starting up)", scheme(), addr)
+
 	// Ensure folders + images
+	setStartupStage("images")
 	if err := ensureDir(imagesDir); err != nil {
-		log.Fatalf("make images dir: %v", err)
+		fatalf("make images dir: %v", err)
 	}
 	if err := autopopulateImages(); err != nil {
-		log.Printf("⚠️  autopopulate images failed (continuing): %v", err)
+		warnf("⚠️  autopopulate images failed (continuing): %v", err)
 	}
 
 	// Init models (CPU + optional GPU)
-	var err error
-	hCPU, hGPU, gpuOK, err = initializeModels(modelJSON)
+	setStartupStage("models")
+	if err := setWGPUBackend(getEnv("WGPU_BACKEND", "")); err != nil {
+		fatalf("WGPU_BACKEND: %v", err)
+	}
+	cpu, gpu, ok, err := initializeModels(modelJSON)
 	if err != nil {
-		log.Fatalf("initialize models: %v", err)
+		fatalf("initialize models: %v", err)
+	}
+	refreshGPUBackend(ok)
+	modelMu.Lock()
+	hCPU, hGPU, gpuOK = cpu, gpu, ok
+	modelMu.Unlock()
+	if quantizeInt8 {
+		setStartupStage("quantize")
+		_, _ = cpu.int8() // logs its own failure; cpu-int8 requests then get 422
+	}
+	if modelsDir != "" {
+		if err := loadRegistry(modelsDir); err != nil {
+			warnf("⚠️  models dir %s ignored: %v", modelsDir, err)
+		}
+	}
+	if err := loadConfiguredModels(); err != nil {
+		warnf("⚠️  MODELS ignored: %v", err)
+	}
+	startGRPC()
+	startGPUWatchdog()
+	startReplay()
+	if calibration, err = loadCalibration(calibJSON); err != nil {
+		warnf("calibration %s ignored: %v", calibJSON, err)
+	} else if calibration != nil {
+		infof("🌡️  temperature calibration T=%g from %s", calibration.Temperature, calibJSON)
 	}
 
 	// Static files for images
-	fs := http.FileServer(http.Dir(imagesDir))
-	http.Handle("/static/images/", http.StripPrefix("/static/images/", fs))
+	http.Handle("/static/images/", http.StripPrefix("/static/images/", staticImages()))
 
 	// Routes
 	http.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
 		writeJSON(w, http.StatusOK, map[string]any{
 			"message":       "MNIST service ready (Go)",
-			"gpu_available": gpuOK,
+			"gpu_available": gpuAvailable(),
 		})
 	})
-	http.HandleFunc("/health", func(w http.ResponseWriter, _ *http.Request) {
-		writeJSON(w, http.StatusOK, map[string]any{"ok": true, "gpu_available": gpuOK})
-	})
-	http.HandleFunc("/images/list", func(w http.ResponseWriter, _ *http.Request) {
-		imgs, _ := listImages()
-		writeJSON(w, http.StatusOK, map[string]any{"images": imgs})
-	})
+	// Versioned API; the unprefixed paths stay as aliases for existing clients.
+	api := newRouter(http.DefaultServeMux, apiPrefix, true)
+	api.HandleFunc("/images/list", handleImagesList)  // ?meta=true for sizes, labels, cached predictions
+	api.HandleFunc("POST /images", handleImageUpload) // IMAGE_UPLOAD_ENABLED
+	api.HandleFunc("POST /images/{name}/label", handleImageLabel)
+	api.HandleFunc("GET /accuracy", handleAccuracy)  // per-backend accuracy over labeled images
+	api.HandleFunc("POST /feedback", handleFeedback) // FEEDBACK_DIR
+	api.HandleFunc("GET /feedback/stats", handleFeedbackStats)
 
-	http.HandleFunc("/predict", handlePredict)        // GET & POST
-	http.HandleFunc("/predict-raw", handlePredictRaw) // raw logits endpoint
-	http.HandleFunc("/parity", handleParity)
+	api.HandleFunc("/predict", traced("/predict", rateLimited(handlePredict))) // GET & POST
+	api.HandleFunc("/predict-raw", rateLimited(handlePredictRaw))              // raw logits endpoint
+	api.HandleFunc("/predict-batch", traced("/predict-batch", rateLimited(handlePredictBatch)))
+	api.HandleFunc("/predict/batch", rateLimited(handlePredictBatch))
+	api.HandleFunc("/predict/tensor", rateLimited(handlePredictTensor))
+	api.HandleFunc("/ws", rateLimited(handleWS))                        // persistent WebSocket prediction channel
+	api.HandleFunc("/predict-upload", rateLimited(handlePredictUpload)) // multipart "file" or raw image/png
+	api.HandleFunc("/predict/upload", rateLimited(handlePredictUpload))
+	api.HandleFunc("/parity", traced("/parity", rateLimited(handleParity)))
+	api.HandleFunc("/predict/occlusion", rateLimited(handleOcclusion))
+	api.HandleFunc("/predict/idx", rateLimited(handlePredictIDX))   // DATASET train split by index
+	api.HandleFunc("/predict-diff", rateLimited(handlePredictDiff)) // current vs previous model
+	api.HandleFunc("/evaluate", handleEvaluate)                     // labels from filenames or labels.csv
+	api.HandleFunc("/evaluate/confusion", handleConfusion)
+	api.HandleFunc("/adhd", handleADHD)
+	api.HandleFunc("/inspect", handleInspect)
+	api.HandleFunc("/metrics", handleMetrics)
+	api.HandleFunc("GET /stats", handleStats)          // rolling latency percentiles, error counts, uptime
+	api.HandleFunc("POST /benchmark", handleBenchmark) // BENCHMARK_ENABLED
+	api.HandleFunc("/model", handleModel)
+	api.HandleFunc("/model/info", handleModelInfo)
+	api.HandleFunc("GET /model/footprint", handleModelFootprint)
+	api.HandleFunc("/reload", adminOnly(handleReload))
+	api.HandleFunc("/admin/reload", adminOnly(handleReload))
+	api.HandleFunc("POST /admin/gpu/reinit", adminOnly(handleGPUReinit)) // after WebGPU device loss
+	api.HandleFunc("GET /gpu/adapters", handleGPUAdapters)
+	api.HandleFunc("GET /admin/runtime", adminOnly(handleRuntime)) // ?gc=true collects first
+	api.HandleFunc("POST /admin/warmup", adminOnly(handleWarmup))  // recompile GPU pipelines on demand
+	api.HandleFunc("/model/reset", adminOnly(handleModelReset))
+	api.HandleFunc("/model/new", adminOnly(handleModelNew))
+	api.HandleFunc("/train", handleTrain)
+	api.HandleFunc("GET /train/status/{id}", handleTrainStatus)
+	api.HandleFunc("GET /train/checkpoints", handleCheckpoints)
+	api.HandleFunc("GET /train/defaults", handleTrainDefaults)
+	api.HandleFunc("GET /jobs", handleJobs) // async work: train, ?async=true evaluate/parity, dataset downloads
+	api.HandleFunc("GET /jobs/{id}", handleJob)
+	api.HandleFunc("DELETE /jobs/{id}", handleJobDelete) // cancel, or forget a finished job
+	api.HandleFunc("POST /datasets/download", handleDatasetDownload)
+	api.HandleFunc("POST /learn", handleLearn) // single labeled images, see LEARN_REPLAY_*
+	api.HandleFunc("/models", handleModels)
+	api.HandleFunc("GET /models/{name}/export", handleModelExport)
+	api.HandleFunc("POST /models/{name}/convert", adminOnly(handleModelConvert))
+	api.HandleFunc("GET /openapi.json", handleOpenAPI)
+	api.HandleFunc("GET /docs", handleDocs) // Swagger UI
 
-	addr := getEnv("ADDR", "0.0.0.0:8003")
-	log.Printf("🚀 Listening on http://%s", addr)
-	log.Fatal(http.ListenAndServe(addr, withCORS(http.DefaultServeMux)))
+	markStarted()
+	infof("✅ Ready on %s://%s", scheme(), addr)
+	select {} // serving continues in the goroutine above
+}
+
+// handleLivez only reports that the process is up and serving HTTP.
+func handleLivez(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true})
 }
 
 func handlePredict(w http.ResponseWriter, r *http.Request) {
@@ -94,36 +296,53 @@ func handlePredict(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		image := strings.TrimSpace(r.URL.Query().Get("image"))
 		backend := strings.TrimSpace(r.URL.Query().Get("backend"))
-		if backend == "" {
-			backend = "gpu"
-		}
 		if image == "" {
 			http.Error(w, "missing ?image=", http.StatusBadRequest)
 			return
 		}
-		res, err := predictCore(image, backend)
+		opts, err := optsFromQuery(r.URL.Query())
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		res, err := predictCore(r.Context(), image, backend, opts)
 		if err != nil {
-			http.Error(w, err.Error(), httpStatus(err))
+			writeError(w, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, res)
 
 	case http.MethodPost:
 		var req PredictRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid JSON", http.StatusBadRequest)
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, err)
 			return
 		}
-		if req.Backend == "" {
-			req.Backend = "gpu"
+		hasName, hasB64 := strings.TrimSpace(req.Image) != "", strings.TrimSpace(req.ImageB64) != ""
+		if !hasName && !hasB64 {
+			writeError(w, newFieldError("image", "required", "missing image or image_b64"))
+			return
 		}
-		if strings.TrimSpace(req.Image) == "" {
-			http.Error(w, "missing image", http.StatusBadRequest)
+		if hasName && hasB64 {
+			writeError(w, newFieldError("image_b64", "invalid", "send either image or image_b64, not both"))
 			return
 		}
-		res, err := predictCore(req.Image, req.Backend)
+		opts, err := predictOpts{RawProbs: req.RawProbs, TopK: req.TopK, Model: strings.TrimSpace(req.Model), Pre: req.Preprocess}.normalize()
 		if err != nil {
-			http.Error(w, err.Error(), httpStatus(err))
+			writeError(w, err)
+			return
+		}
+		var res map[string]any
+		if hasB64 {
+			var data []byte
+			if data, err = decodeImageB64(req.ImageB64); err == nil {
+				res, err = predictData(r.Context(), data, "image_b64", req.Backend, opts)
+			}
+		} else {
+			res, err = predictCore(r.Context(), req.Image, req.Backend, opts)
+		}
+		if err != nil {
+			writeError(w, err)
 			return
 		}
 		writeJSON(w, http.StatusOK, res)
@@ -133,152 +352,994 @@ func handlePredict(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+func handlePredictBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req BatchPredictRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	n := len(req.Images) + len(req.Tensors)
+	if n == 0 {
+		http.Error(w, "images and tensors must not both be empty", http.StatusBadRequest)
+		return
+	}
+	if n > batchMax {
+		http.Error(w, fmt.Sprintf("batch of %d exceeds max %d", n, batchMax), http.StatusBadRequest)
+		return
+	}
+	opts, err := predictOpts{RawProbs: req.RawProbs, TopK: req.TopK, Pre: req.Preprocess}.normalize()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	// items report the backend that ran them; "auto" is resolved once here
+	backend := strings.ToLower(strings.TrimSpace(req.Backend))
+	if backend == "" || backend == defaultBackend {
+		backend, _, _ = pickBackend("", backend)
+	}
+
+	start := time.Now()
+	// items mirror predictCore's output; failures carry a staged error instead
+	items := make([]map[string]any, n)
+	failed := 0
+	for i, name := range req.Images {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			items[i] = map[string]any{"index": i, "image": name, "error": &ItemError{Stage: stageDecode, Error: "missing image"}}
+			failed++
+			continue
+		}
+		res, err := predictCore(r.Context(), name, req.Backend, opts)
+		if err != nil {
+			items[i] = map[string]any{"index": i, "image": name, "error": itemError(err)}
+			failed++
+			continue
+		}
+		res["index"] = i
+		items[i] = res
+	}
+	for j, t := range req.Tensors {
+		i, label := len(req.Images)+j, fmt.Sprintf("tensor[%d]", j)
+		res, err := predictTensor(t, label, req.Backend, opts)
+		if err != nil {
+			items[i] = map[string]any{"index": i, "image": label, "error": itemError(err)}
+			failed++
+			continue
+		}
+		res["index"] = i
+		items[i] = res
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"backend":           backend,
+		"count":             len(items),
+		"failed":            failed,
+		"total_latency_sec": round6(time.Since(start).Seconds()),
+		"results":           items,
+	})
+}
+
 func handlePredictRaw(w http.ResponseWriter, r *http.Request) {
 	image := strings.TrimSpace(r.URL.Query().Get("image"))
 	backend := strings.TrimSpace(r.URL.Query().Get("backend"))
-	if backend == "" {
-		backend = "gpu"
-	}
 	if image == "" {
 		http.Error(w, "missing ?image=", http.StatusBadRequest)
 		return
 	}
-	path := filepath.Join(imagesDir, image)
+	path, err := imagePath(image)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
 	exists, _ := fileExists(path)
 	if !exists {
 		http.Error(w, "image not found: "+image, http.StatusNotFound)
 		return
 	}
-	img, err := loadPNG28x28(path)
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	backend, h, err := pickBackend(model, backend)
 	if err != nil {
-		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		writeError(w, err)
 		return
 	}
-
-	var h *ParagonHandle
-	if strings.ToLower(backend) == "gpu" {
-		if !gpuOK || hGPU == nil {
-			http.Error(w, "GPU backend not available", http.StatusServiceUnavailable)
-			return
-		}
-		h = hGPU
-	} else {
-		h = hCPU
+	img, err := loadImageToInput(path, h.Input().W, h.Input().H)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	// ✅ Forward has no return; ExtractOutput returns only []float64
-	h.Forward(img)
-	logits := h.ExtractOutput()
+	// ✅ Forward has no return; Infer locks the Forward+ExtractOutput pair
+	logits := h.Infer(img)
 
 	n := len(logits)
 	start := 0
 	if n >= 10 {
 		start = n - 10
 	}
-	writeJSON(w, http.StatusOK, map[string]any{
+	res := map[string]any{
 		"backend": backend,
 		"image":   image,
 		"logits":  logits[start:],
+	}
+	if model != "" {
+		res["model"] = model
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func handlePredictIDX(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	index, err := strconv.Atoi(strings.TrimSpace(q.Get("index")))
+	if err != nil {
+		http.Error(w, "missing or bad ?index=", http.StatusBadRequest)
+		return
+	}
+	backend := strings.ToLower(strings.TrimSpace(q.Get("backend")))
+
+	imgRaw, labRaw, err := ensureTrainIDX()
+	if err != nil {
+		http.Error(w, "mnist idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	img, err := readImageIDXAt(imgRaw, index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	label, err := readLabelIDXAt(labRaw, index)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	backend, h, err := pickBackend("", backend)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if in := h.Input(); in.W != len(img[0]) || in.H != len(img) {
+		http.Error(w, fmt.Sprintf("model expects %dx%d input; IDX images are %dx%d", in.W, in.H, len(img[0]), len(img)), http.StatusBadRequest)
+		return
+	}
+	start := time.Now()
+	out, err := forwardProbs(h, img)
+	if err != nil {
+		http.Error(w, "forward failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	res := map[string]any{
+		"backend":       backend,
+		"dataset":       activeDataset.name,
+		"index":         index,
+		"label":         label,
+		"prediction":    out.Pred,
+		"match":         out.Pred == label,
+		"probabilities": out.Probs,
+		"latency_sec":   round6(time.Since(start).Seconds()),
+	}
+	if name := className(out.Pred); name != "" {
+		res["class_name"], res["label_name"] = name, className(label)
+	}
+	writeJSON(w, http.StatusOK, res)
+}
+
+func handleOcclusion(w http.ResponseWriter, r *http.Request) {
+	if !occlusionOn {
+		http.Error(w, "occlusion disabled (set OCCLUSION_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	q := r.URL.Query()
+	image := strings.TrimSpace(q.Get("image"))
+	backend := strings.ToLower(strings.TrimSpace(q.Get("backend")))
+	if image == "" {
+		http.Error(w, "missing ?image=", http.StatusBadRequest)
+		return
+	}
+	patch, stride := 4, 2
+	if v := q.Get("patch"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minOcclusionPatch || n > maxOcclusionPatch {
+			http.Error(w, fmt.Sprintf("patch must be %d..%d", minOcclusionPatch, maxOcclusionPatch), http.StatusBadRequest)
+			return
+		}
+		patch = n
+	}
+	if v := q.Get("stride"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < minOcclusionStride || n > patch {
+			http.Error(w, fmt.Sprintf("stride must be %d..patch", minOcclusionStride), http.StatusBadRequest)
+			return
+		}
+		stride = n
+	}
+
+	path, err := imagePath(image)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	exists, _ := fileExists(path)
+	if !exists {
+		http.Error(w, "image not found: "+image, http.StatusNotFound)
+		return
+	}
+	backend, h, err := pickBackend("", backend)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	img, err := loadImageToInput(path, h.Input().W, h.Input().H)
+	if err != nil {
+		http.Error(w, "bad image: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start := time.Now()
+	base, heat, err := occlusionHeatmap(h, img, patch, stride)
+	if err != nil {
+		http.Error(w, "forward failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"backend":     backend,
+		"image":       image,
+		"prediction":  base.Pred,
+		"probability": base.Probs[base.Pred],
+		"patch":       patch,
+		"stride":      stride,
+		"heatmap":     heat,
+		"latency_sec": round6(time.Since(start).Seconds()),
 	})
 }
 
-func handleParity(w http.ResponseWriter, r *http.Request) {
-	imgs, _ := listImages()
-	if len(imgs) == 0 {
-		imgs = []string{"0.png", "1.png", "2.png", "3.png", "4.png", "5.png", "6.png", "7.png", "8.png", "9.png"}
+// adminMu serializes model replacement (reload, reset, new) so two rebuilds
+// never race each other.
+var adminMu sync.Mutex
+
+// modelMu guards hCPU/hGPU/gpuOK/prevCPU/originalModel; read them through currentHandles so
+// a request always sees one consistent, fully initialized pair.
+var modelMu sync.RWMutex
+
+func currentHandles() (*ParagonHandle, *ParagonHandle, bool) {
+	modelMu.RLock()
+	defer modelMu.RUnlock()
+	return hCPU, hGPU, gpuOK
+}
+
+// pickHandle resolves a backend name to the live handle (see pickBackend).
+func pickHandle(backend string) (*ParagonHandle, error) {
+	return pickModelHandle("", backend)
+}
+
+// swapModels installs new handles, keeping the outgoing CPU handle as prevCPU
+// for /predict-diff and freeing the outgoing GPU pipeline. Requests that
+// already picked the old handles finish on them; release waits for their
+// forward to complete.
+func swapModels(cpu, gpu *ParagonHandle, ok bool) {
+	modelMu.Lock()
+	oldGPU := hGPU
+	prevCPU = hCPU
+	hCPU, hGPU, gpuOK = cpu, gpu, ok
+	modelMu.Unlock()
+	oldGPU.release()
+}
+
+func gpuAvailable() bool {
+	_, _, ok := currentHandles()
+	return ok
+}
+
+func handleMetrics(w http.ResponseWriter, _ *http.Request) {
+	cpu, gpu, _ := currentHandles()
+	writeJSON(w, http.StatusOK, map[string]any{
+		"input_cache":  inputCache.stats(),
+		"result_cache": resultCache.stats(),
+		"pools":        map[string]PoolStats{"cpu": cpu.Stats(), "gpu": gpu.Stats()},
+		"microbatch":   microBatcher.stats(),
+		"gpu_watchdog": gpuWatchStats(),
+		"shadow":       shadowStats(),
+	})
+}
+
+func handleModelInfo(w http.ResponseWriter, r *http.Request) {
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	cpu, _, _, err := modelHandles(model)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
-	// allow override: /parity?images=0.png&images=1.png
-	if qs := r.URL.Query()["images"]; len(qs) > 0 {
-		imgs = qs
+	info := cpu.Info()
+	info.Model = model
+	info.Dataset, info.Classes = activeDataset.name, activeDataset.classes
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleModelFootprint reports the parameter count, serialized size and
+// estimated memory of a model (?model=, default MODEL_JSON) across its pools.
+func handleModelFootprint(w http.ResponseWriter, r *http.Request) {
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	cpu, gpu, ok, err := modelHandles(model)
+	if err != nil {
+		writeError(w, err)
+		return
 	}
-	sort.Strings(imgs)
+	f, err := cpu.Footprint(gpu, ok)
+	if err != nil {
+		http.Error(w, "serialize model: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	f.Model = cmp.Or(model, "default")
+	writeJSON(w, http.StatusOK, f)
+}
 
-	var rows []ParityRow
-	mismatches := 0
+func handleModel(w http.ResponseWriter, _ *http.Request) {
+	cal := map[string]any{"enabled": calibration != nil}
+	if calibration != nil {
+		cal["temperature"] = calibration.Temperature
+		cal["source"] = calibration.Source
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"model_json":    modelJSON,
+		"gpu_available": gpuAvailable(),
+		"calibration":   cal,
+	})
+}
 
-	for _, name := range imgs {
-		path := filepath.Join(imagesDir, name)
-		exists, _ := fileExists(path)
-		if !exists {
-			rows = append(rows, ParityRow{Image: name, Error: "not found"})
-			continue
+type ReloadRequest struct {
+	Path string `json:"path"` // MODEL_JSON (default) or a name in MODELS_DIR
+}
+
+// handleReload loads a model from disk and swaps it in. On any failure the
+// current model stays live.
+func handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req ReloadRequest
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &req); err != nil {
+			writeError(w, err)
+			return
 		}
-		img, err := loadPNG28x28(path)
-		if err != nil {
-			rows = append(rows, ParityRow{Image: name, Error: "bad png: " + err.Error()})
-			continue
+	}
+	path, err := modelFilePath(req.Path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	start := time.Now()
+	snap, err := loadModelSnapshot(path)
+	if err != nil {
+		http.Error(w, "reload failed, keeping current model: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := validateTopology(snap.shapes, snap.acts); err != nil {
+		http.Error(w, "incompatible model, keeping current model: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cpu, gpu, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		http.Error(w, "reload failed, keeping current model: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	swapModels(cpu, gpu, ok)
+	modelMu.Lock()
+	originalModel = snap
+	modelMu.Unlock()
+	infof("🔄 reloaded model from %s (gpu=%v) in %.2fs", path, ok, time.Since(start).Seconds())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":            true,
+		"path":          path,
+		"gpu_available": ok,
+		"reload_sec":    round6(time.Since(start).Seconds()),
+	})
+}
+
+// handleGPUReinit rebuilds the GPU pipelines of a model (?model=, default
+// MODEL_JSON) in place, so a lost WebGPU device doesn't need a restart. They
+// are rebuilt on the adapter Paragon chose at startup; see WGPU_BACKEND.
+func handleGPUReinit(w http.ResponseWriter, r *http.Request) {
+	name, label := strings.TrimSpace(r.URL.Query().Get("model")), "default"
+	if name == "default" {
+		name = ""
+	} else if name != "" {
+		label = name
+	}
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	_, gpu, _, err := modelHandles(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	start := time.Now()
+	err = gpu.reinitGPU()
+	setGPUOK(name, gpu, err == nil)
+	if err != nil {
+		warnf("⚠️  GPU reinit failed, serving CPU only: %v", err)
+		http.Error(w, "GPU reinit failed, serving CPU only: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	refreshGPUBackend(true)
+	infof("🔁 reinitialized GPU for model %q on %s in %.2fs", label, cmp.Or(activeGPUBackend(), "the default backend"), time.Since(start).Seconds())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":            true,
+		"model":         label,
+		"gpu_available": true,
+		"gpu_backend":   activeGPUBackend(),
+		"wgpu_backend":  wgpuBackendSetting(),
+		"reinit_sec":    round6(time.Since(start).Seconds()),
+	})
+}
+
+// setGPUOK records whether gpu, the GPU handle of model name, is usable. A
+// handle swapped out while it was being rebuilt is left alone.
+func setGPUOK(name string, gpu *ParagonHandle, ok bool) {
+	if name == "" {
+		modelMu.Lock()
+		if hGPU == gpu {
+			gpuOK = ok
 		}
+		modelMu.Unlock()
+		return
+	}
+	registryMu.Lock()
+	if m := registry[name]; m != nil && m.gpu == gpu {
+		m.GPUOK = ok
+	}
+	registryMu.Unlock()
+}
+
+// handleModelReset rebuilds both handles from the model state captured at
+// startup, discarding any in-memory training.
+func handleModelReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !trainingOn {
+		http.Error(w, "training disabled (set TRAINING_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	modelMu.RLock()
+	snap := originalModel
+	modelMu.RUnlock()
+	if snap == nil {
+		http.Error(w, "no original model state", http.StatusConflict)
+		return
+	}
+	cpu, gpu, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		http.Error(w, "reset failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	swapModels(cpu, gpu, ok)
+	infof("↩️  model reset to startup state (gpu=%v)", ok)
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "gpu_available": ok})
+}
+
+type NewModelRequest struct {
+	Shapes []struct {
+		Width  int `json:"width"`
+		Height int `json:"height"`
+	} `json:"shapes"`
+	Activations []string `json:"activations"`
+	NumericType string   `json:"numeric_type,omitempty"` // float32 (default), float64, int8, ...
+	Path        string   `json:"path"`                   // MODEL_JSON (default) or a name in MODELS_DIR
+}
 
-		// CPU
-		cpuStart := time.Now()
-		cpuOut, err := forwardProbs(hCPU, img)
+// handleModelNew builds a randomly initialized network of the requested
+// topology, saves it and swaps it into service.
+func handleModelNew(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !trainingOn {
+		http.Error(w, "training disabled (set TRAINING_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	var req NewModelRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	shapes := make([]struct{ Width, Height int }, len(req.Shapes))
+	for i, s := range req.Shapes {
+		shapes[i] = struct{ Width, Height int }{s.Width, s.Height}
+	}
+	acts := make([]string, len(req.Activations))
+	for i, a := range req.Activations {
+		acts[i] = strings.ToLower(strings.TrimSpace(a))
+	}
+	if err := validateTopology(shapes, acts); err != nil {
+		http.Error(w, "bad topology: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	nn, snap, err := newModelSnapshot(strings.ToLower(strings.TrimSpace(req.NumericType)), shapes, acts)
+	if errors.Is(err, errNumericType) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, "build failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	path, err := modelFilePath(req.Path)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if err := nn.SaveJSON(path); err != nil {
+		http.Error(w, "save failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	cpu, gpu, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		http.Error(w, "load failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	swapModels(cpu, gpu, ok)
+	infof("🆕 new model %v saved to %s (gpu=%v)", shapes, path, ok)
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":            true,
+		"path":          path,
+		"shapes":        req.Shapes,
+		"activations":   acts,
+		"numeric_type":  snap.numType,
+		"gpu_available": ok,
+	})
+}
+
+func handleParity(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	// allow override: /parity?images=0.png&images=1.png, or sample the IDX
+	// files: /parity?n=500&split=test[&seed=42]
+	src := paritySource{Images: q["images"], Split: strings.TrimSpace(q.Get("split"))}
+	var err error
+	if src.N, err = queryInt(q, "n", 0); err != nil {
+		writeError(w, err)
+		return
+	}
+	if v := strings.TrimSpace(q.Get("seed")); v != "" {
+		seed, err := strconv.ParseUint(v, 10, 64)
 		if err != nil {
-			rows = append(rows, ParityRow{Image: name, Error: "cpu forward: " + err.Error()})
-			continue
+			http.Error(w, "bad ?seed=", http.StatusBadRequest)
+			return
 		}
-		cpuOut.LatencySec = round6(time.Since(cpuStart).Seconds())
+		src.Seed = &seed
+	}
+	if err := src.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
 
-		// GPU (optional)
-		if !gpuOK || hGPU == nil {
-			rows = append(rows, ParityRow{Image: name, CPU: cpuOut, GPU: nil, Match: nil})
-			continue
+	// ?tol= (alias ?atol=) is the absolute tolerance, ?rtol= the relative one;
+	// each probability must satisfy |cpu-gpu| <= atol + rtol*|gpu|
+	tol := parityTol{Abs: 1e-4}
+	for _, p := range []struct {
+		name string
+		dst  *float64
+	}{{"tol", &tol.Abs}, {"atol", &tol.Abs}, {"rtol", &tol.Rel}} {
+		if v := r.URL.Query().Get(p.name); v != "" {
+			t, err := strconv.ParseFloat(v, 64)
+			if err != nil || t < 0 || math.IsNaN(t) {
+				http.Error(w, "bad ?"+p.name+"=", http.StatusBadRequest)
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	// ?format=csv|markdown renders the finished report instead of JSON
+	format := strings.ToLower(strings.TrimSpace(q.Get("format")))
+	pf, exported := parityFormats[format]
+	if format != "" && format != "json" && !exported {
+		http.Error(w, "bad ?format= (want json, csv or markdown)", http.StatusBadRequest)
+		return
+	}
+
+	model := strings.TrimSpace(r.URL.Query().Get("model"))
+	async, callback, err := asyncParams(q)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if async {
+		if exported {
+			http.Error(w, "?format= can't be combined with ?async=true", http.StatusBadRequest)
+			return
+		}
+		j, ctx := newJob("parity", callback, nil, nil)
+		j.start(ctx, func(ctx context.Context) (any, error) {
+			rep, err := runParity(ctx, model, src, tol, func(i, total int, _ ParityRow) {
+				j.setProgress(float64(i+1)/float64(total), fmt.Sprintf("%d/%d rows", i+1, total))
+			})
+			if err != nil {
+				return nil, err
+			}
+			return rep, nil
+		})
+		writeJobAccepted(w, j)
+		return
+	}
+	if wantsSSE(r) && !exported {
+		streamParity(w, r, model, src, tol)
+		return
+	}
+	rep, err := runParity(r.Context(), model, src, tol, nil)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if exported {
+		w.Header().Set("Content-Type", pf.contentType)
+		if format == "csv" {
+			w.Header().Set("Content-Disposition", `attachment; filename="parity.csv"`)
+		}
+		w.WriteHeader(http.StatusOK)
+		if err := pf.write(w, rep); err != nil {
+			warnf("parity %s export: %v", format, err)
+		}
+		return
+	}
+	writeJSON(w, http.StatusOK, rep)
+}
+
+// parityTol bounds the per-class probability difference in /parity, in the
+// style of numpy's allclose.
+type parityTol struct {
+	Abs, Rel float64
+}
+
+// within reports whether every |a-b| <= Abs + Rel*|b|, along with the largest
+// relative difference over the nonzero entries of b.
+func (t parityTol) within(a, b []float64) (bool, float64) {
+	ok := true
+	var maxRel float64
+	for i := 0; i < min(len(a), len(b)); i++ {
+		d := math.Abs(a[i] - b[i])
+		if d > t.Abs+t.Rel*math.Abs(b[i]) {
+			ok = false
+		}
+		if b[i] != 0 { // exact zeros are left to the absolute bound
+			maxRel = max(maxRel, d/math.Abs(b[i]))
 		}
-		gpuStart := time.Now()
-		gpuOut, err := forwardProbs(hGPU, img)
+	}
+	return ok, maxRel
+}
+
+// runParity compares CPU and GPU outputs for imgs (all images when empty).
+// onRow, when set, sees each row as soon as it is computed.
+func runParity(ctx context.Context, model string, src paritySource, tol parityTol, onRow func(i, total int, row ParityRow)) (*ParityReport, error) {
+	hc, hg, ok, err := modelHandles(model)
+	if err != nil {
+		return nil, err
+	}
+	samples, err := src.samples()
+	if err != nil {
+		return nil, err
+	}
+	workers := min(parityWorkers, len(samples))
+	if workers > 1 {
+		pc, pg, pok, err := parityHandles(hc, ok, workers)
 		if err != nil {
-			rows = append(rows, ParityRow{Image: name, CPU: cpuOut, Error: "gpu forward: " + err.Error()})
-			continue
+			return nil, err
 		}
-		gpuOut.LatencySec = round6(time.Since(gpuStart).Seconds())
+		defer pc.release()
+		defer pg.release()
+		hc, hg, ok = pc, pg, pok
+	}
 
-		m := cpuOut.Pred == gpuOut.Pred
-		if !m {
+	// rows are computed by up to `workers` goroutines but reported (and
+	// streamed through onRow) strictly in sample order
+	rows := make([]ParityRow, len(samples))
+	done := make([]chan struct{}, len(samples))
+	for i := range done {
+		done[i] = make(chan struct{})
+	}
+	go func() {
+		sem := make(chan struct{}, max(workers, 1))
+		for i, smp := range samples {
+			sem <- struct{}{}
+			go func() {
+				defer func() { <-sem; close(done[i]) }()
+				rows[i] = parityRow(ctx, hc, hg, ok, smp, tol)
+			}()
+		}
+	}()
+
+	mismatches := 0
+	for i := range rows {
+		<-done[i]
+		if row := rows[i]; row.Match != nil && (!*row.Match || !*row.WithinTol) {
 			mismatches++
 		}
-		rows = append(rows, ParityRow{Image: name, CPU: cpuOut, GPU: gpuOut, Match: &m})
+		if onRow != nil {
+			onRow(i, len(rows), rows[i])
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, ctxError(err)
 	}
 
-	writeJSON(w, http.StatusOK, ParityReport{
-		GPUAvailable: gpuOK,
+	return &ParityReport{
+		Model:        model,
+		Source:       src.source(),
+		Seed:         src.Seed,
+		GPUAvailable: ok,
+		Tolerance:    tol.Abs,
+		RelTolerance: tol.Rel,
 		Mismatches:   mismatches,
 		Total:        len(rows),
 		Results:      rows,
-	})
+	}, nil
+}
+
+// parityRow runs one sample through the CPU and (when available) GPU handles.
+func parityRow(ctx context.Context, hc, hg *ParagonHandle, ok bool, smp paritySample, tol parityTol) ParityRow {
+	name := smp.name
+	_, ds := startSpan(ctx, "decode")
+	img, err := smp.load(hc.Input())
+	ds.SetError(err)
+	ds.End()
+	if err != nil {
+		return ParityRow{Image: name, Label: smp.label, Error: err.Error()}
+	}
+
+	// CPU
+	cpuStart := time.Now()
+	cpuOut, err := forwardProbsCtx(ctx, hc, img)
+	if err != nil {
+		return ParityRow{Image: name, Label: smp.label, Error: "cpu forward: " + err.Error()}
+	}
+	cpuOut.LatencySec = round6(time.Since(cpuStart).Seconds())
+
+	// GPU (optional)
+	if !ok || hg == nil {
+		return ParityRow{Image: name, Label: smp.label, CPU: cpuOut, GPU: nil, Match: nil}
+	}
+	gpuStart := time.Now()
+	gpuOut, err := forwardProbsCtx(ctx, hg, img)
+	if err != nil {
+		return ParityRow{Image: name, Label: smp.label, CPU: cpuOut, Error: "gpu forward: " + err.Error()}
+	}
+	gpuOut.LatencySec = round6(time.Since(gpuStart).Seconds())
+
+	m := cpuOut.Pred == gpuOut.Pred
+	mae, maxd, _ := diffStats(cpuOut.Probs, gpuOut.Probs)
+	within, maxRel := tol.within(cpuOut.Probs, gpuOut.Probs)
+	return ParityRow{Image: name, Label: smp.label, CPU: cpuOut, GPU: gpuOut, Match: &m, MAE: &mae, MaxAbsDiff: &maxd, MaxRelDiff: &maxRel, WithinTol: &within}
 }
 
-func predictCore(imageName, backend string) (map[string]any, error) {
-	path := filepath.Join(imagesDir, imageName)
+func predictCore(ctx context.Context, imageName, backend string, opts predictOpts) (map[string]any, error) {
+	path, err := imagePath(imageName)
+	if err != nil {
+		return nil, err
+	}
 	exists, _ := fileExists(path)
 	if !exists {
-		return nil, newHTTPError(http.StatusNotFound, "image not found: "+imageName)
+		return nil, newStageError(stageDecode, http.StatusNotFound, "image not found: "+imageName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, newStageError(stageDecode, http.StatusBadRequest, "bad image: "+err.Error())
 	}
-	img, err := loadPNG28x28(path)
+	res, err := predictData(ctx, data, imageName, backend, opts)
 	if err != nil {
-		return nil, newHTTPError(http.StatusBadRequest, "bad image: "+err.Error())
+		return nil, err
 	}
+	res["source_image_url"] = "/static/images/" + imageName
+	return res, nil
+}
 
+// predictData predicts from encoded image bytes; image is only echoed back in the response.
+func predictData(ctx context.Context, data []byte, image, backend string, opts predictOpts) (map[string]any, error) {
 	backend = strings.ToLower(strings.TrimSpace(backend))
-	target := hCPU
-	if backend == "gpu" {
-		if !gpuOK || hGPU == nil {
-			return nil, newHTTPError(http.StatusServiceUnavailable, "GPU backend not available")
+	annotateRequest(ctx, backend, image)
+	if backend == "ensemble" {
+		cpu, _, _, err := modelHandles(opts.Model)
+		if err != nil {
+			return nil, err
 		}
-		target = hGPU
+		img, err := decodeImageToInput(bytes.NewReader(data), cpu.Input().W, cpu.Input().H)
+		if err != nil {
+			return nil, newStageError(stageDecode, http.StatusBadRequest, "bad image: "+err.Error())
+		}
+		return ensembleCore(image, opts.Pre.apply(img), opts)
 	}
+	requested := backend
+	backend, target, err := pickBackend(opts.Model, backend)
+	if err != nil {
+		return nil, err
+	}
+	annotateRequest(ctx, backend, image)
 
+	debugf("predict image=%s backend=%s model=%s", image, backend, opts.Model)
 	start := time.Now()
-	out, err := forwardProbs(target, img)
+	out, _, err := predictBytes(ctx, data, backend, target, opts.Pre)
+	if err != nil && backend == "gpu" && requested != "gpu" && gpuFailed(err) { // auto
+		warnf("⚠️  GPU forward failed on %s, falling back to CPU: %v", image, err)
+		backend, target, _ = pickBackend(opts.Model, "cpu")
+		annotateRequest(ctx, backend, image)
+		out, _, err = predictBytes(ctx, data, backend, target, opts.Pre)
+	}
 	if err != nil {
-		return nil, newHTTPError(http.StatusInternalServerError, "forward failed: "+err.Error())
+		return nil, err
 	}
 	out.LatencySec = round6(time.Since(start).Seconds())
+	return autoResponse(requested, probResponse(backend, image, out, opts)), nil
+}
 
-	return map[string]any{
-		"backend":          backend,
-		"image":            imageName,
-		"prediction":       out.Pred,
-		"probabilities":    out.Probs,
-		"latency_sec":      out.LatencySec,
-		"source_image_url": "/static/images/" + imageName,
-	}, nil
+// predictTensor scores an already-decoded input, e.g. an inline tensor from
+// /predict-batch; it must match the model's input size.
+func predictTensor(img [][]float64, label, backend string, opts predictOpts) (map[string]any, error) {
+	backend = strings.ToLower(strings.TrimSpace(backend))
+	if backend == "ensemble" {
+		cpu, _, _, err := modelHandles(opts.Model)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkTensor(img, cpu.Input()); err != nil {
+			return nil, err
+		}
+		return ensembleCore(label, opts.Pre.apply(img), opts)
+	}
+	requested := backend
+	backend, target, err := pickBackend(opts.Model, backend)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkTensor(img, target.Input()); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	img = opts.Pre.apply(img)
+	forward := func(h *ParagonHandle) (*ProbResult, error) {
+		out, err := forwardProbs(h, img)
+		if _, ok := err.(*httpError); err != nil && !ok {
+			err = newStageError(stageForward, http.StatusInternalServerError, "forward failed: "+err.Error())
+		}
+		return out, err
+	}
+	out, err := forward(target)
+	if err != nil && backend == "gpu" && requested != "gpu" && gpuFailed(err) { // auto
+		warnf("⚠️  GPU forward failed on %s, falling back to CPU: %v", label, err)
+		backend, target, _ = pickBackend(opts.Model, "cpu")
+		out, err = forward(target)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if backend == "gpu" {
+		shadowGPU(context.Background(), target, img, out)
+	}
+	out.LatencySec = round6(time.Since(start).Seconds())
+	return autoResponse(requested, probResponse(backend, label, out, opts)), nil
+}
+
+// autoResponse notes in res that the backend was picked automatically;
+// res["backend"] is always the one that ran.
+func autoResponse(requested string, res map[string]any) map[string]any {
+	if requested == "" || requested == defaultBackend {
+		res["requested_backend"] = defaultBackend
+	}
+	return res
+}
+
+func checkTensor(img [][]float64, in inputShape) error {
+	if len(img) != in.H {
+		return newStageError(stageDecode, http.StatusBadRequest, fmt.Sprintf("tensor has %d rows, model expects %dx%d", len(img), in.W, in.H))
+	}
+	for y, row := range img {
+		if len(row) != in.W {
+			return newStageError(stageDecode, http.StatusBadRequest, fmt.Sprintf("tensor row %d has %d values, model expects %d", y, len(row), in.W))
+		}
+		for _, v := range row {
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return newStageError(stageDecode, http.StatusBadRequest, fmt.Sprintf("tensor row %d has a non-finite value", y))
+			}
+		}
+	}
+	return nil
+}
+
+func probResponse(backend, image string, out *ProbResult, opts predictOpts) map[string]any {
+	res := map[string]any{
+		"backend":       backend,
+		"image":         image,
+		"prediction":    out.Pred,
+		"probabilities": out.Probs,
+		"top_k":         topK(out.Probs, max(opts.TopK, 1)),
+		"latency_sec":   out.LatencySec,
+		"calibrated":    out.RawProbs != nil,
+	}
+	if opts.Model != "" {
+		res["model"] = opts.Model
+	}
+	if name := className(out.Pred); name != "" {
+		res["class_name"] = name
+	}
+	if opts.Pre.enabled() {
+		res["preprocess"] = opts.Pre
+	}
+	if opts.RawProbs {
+		res["raw_probabilities"] = rawOrProbs(out)
+	}
+	if len(out.Warnings) > 0 {
+		res["warnings"] = out.Warnings
+	}
+	return res
+}
+
+// rawOrProbs returns the uncalibrated probabilities of a result.
+func rawOrProbs(p *ProbResult) []float64 {
+	if p.RawProbs != nil {
+		return p.RawProbs
+	}
+	return p.Probs
+}
+
+// ensembleCore averages CPU and GPU outputs; ENSEMBLE_CONFLICT decides what
+// happens when their argmax disagrees.
+func ensembleCore(imageName string, img [][]float64, opts predictOpts) (map[string]any, error) {
+	cpu, gpu, ok, err := modelHandles(opts.Model)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || gpu == nil {
+		return nil, newStageError(stageForward, http.StatusServiceUnavailable, "GPU backend not available")
+	}
+	start := time.Now()
+	res, err := ensembleProbs(cpu, gpu, img, ensembleConflict)
+	if err != nil {
+		return nil, newStageError(stageForward, http.StatusInternalServerError, "forward failed: "+err.Error())
+	}
+	if res.Conflict {
+		warnf("ensemble conflict on %s: cpu=%d gpu=%d policy=%s", imageName, res.CPU.Pred, res.GPU.Pred, res.Applied)
+	}
+	out := map[string]any{
+		"backend":         "ensemble",
+		"image":           imageName,
+		"prediction":      res.Pred,
+		"probabilities":   res.Probs,
+		"top_k":           topK(res.Probs, max(opts.TopK, 1)),
+		"latency_sec":     round6(time.Since(start).Seconds()),
+		"conflict":        res.Conflict,
+		"ensemble_policy": res.Applied,
+		"calibrated":      calibration != nil,
+	}
+	if opts.RawProbs {
+		raw := make([]float64, len(res.Probs))
+		for i := range raw {
+			raw[i] = (rawOrProbs(res.CPU)[i] + rawOrProbs(res.GPU)[i]) / 2
+		}
+		out["raw_probabilities"] = raw
+	}
+	if res.Conflict && res.Applied == conflictFlag {
+		out["cpu"] = res.CPU
+		out["gpu"] = res.GPU
+	}
+	return out, nil
+}
+
+func ensemblePolicy(s string) string {
+	switch p := strings.ToLower(strings.TrimSpace(s)); p {
+	case conflictAverage, conflictCPU, conflictFlag:
+		return p
+	}
+	warnf("unknown ENSEMBLE_CONFLICT=%q, using %s", s, conflictAverage)
+	return conflictAverage
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// MICROBATCH_WINDOW (Go duration, default 0 = off) holds forwards for up to
+// that long so concurrent requests on the same handle go through one
+// checked-out network back to back, up to MICROBATCH_MAX (default 16) at a
+// time. Paragon has no batched Forward yet, so the gain is fewer pool
+// handoffs and better GPU queue occupancy; InferBatch is where a batched
+// kernel would slot in.
+var microBatcher = newMicroBatcher(
+	getEnvDuration("MICROBATCH_WINDOW", 0),
+	max(getEnvInt("MICROBATCH_MAX", 16), 1),
+)
+
+type batchJob struct {
+	h   *ParagonHandle
+	img [][]float64
+	out chan []float64 // buffered; the caller may have gone away
+}
+
+type batcher struct {
+	window  time.Duration
+	maxSize int
+	jobs    chan *batchJob
+
+	batches, items atomic.Int64
+}
+
+func newMicroBatcher(window time.Duration, maxSize int) *batcher {
+	if window <= 0 {
+		return nil
+	}
+	b := &batcher{window: window, maxSize: maxSize, jobs: make(chan *batchJob, maxSize*4)}
+	go b.loop()
+	infof("🧺 micro-batching forwards: window=%s max=%d", window, maxSize)
+	return b
+}
+
+func (b *batcher) infer(ctx context.Context, h *ParagonHandle, img [][]float64) ([]float64, error) {
+	job := &batchJob{h: h, img: img, out: make(chan []float64, 1)}
+	select {
+	case b.jobs <- job:
+	case <-ctx.Done():
+		return nil, ctxError(ctx.Err())
+	}
+	select {
+	case out := <-job.out:
+		return out, nil
+	case <-ctx.Done():
+		return nil, ctxError(ctx.Err())
+	}
+}
+
+// loop gathers jobs for one window (or until maxSize) after the first
+// arrives, then runs each handle's group on its own goroutine.
+func (b *batcher) loop() {
+	for first := range b.jobs {
+		pending := []*batchJob{first}
+		timer := time.NewTimer(b.window)
+	collect:
+		for len(pending) < b.maxSize {
+			select {
+			case j := <-b.jobs:
+				pending = append(pending, j)
+			case <-timer.C:
+				break collect
+			}
+		}
+		timer.Stop()
+
+		groups := map[*ParagonHandle][]*batchJob{}
+		for _, j := range pending {
+			groups[j.h] = append(groups[j.h], j)
+		}
+		for h, js := range groups {
+			b.batches.Add(1)
+			b.items.Add(int64(len(js)))
+			go func(h *ParagonHandle, js []*batchJob) {
+				imgs := make([][][]float64, len(js))
+				for i, j := range js {
+					imgs[i] = j.img
+				}
+				for i, out := range h.InferBatch(imgs) {
+					js[i].out <- out
+				}
+			}(h, js)
+		}
+	}
+}
+
+func (b *batcher) stats() map[string]any {
+	if b == nil {
+		return map[string]any{"enabled": false}
+	}
+	n, items := b.batches.Load(), b.items.Load()
+	avg := 0.0
+	if n > 0 {
+		avg = round6(float64(items) / float64(n))
+	}
+	return map[string]any{
+		"enabled":   true,
+		"window_ms": b.window.Milliseconds(),
+		"max":       b.maxSize,
+		"batches":   n,
+		"items":     items,
+		"avg_size":  avg,
+	}
+}
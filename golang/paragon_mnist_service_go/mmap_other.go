@@ -0,0 +1,14 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"os"
+)
+
+func mmapFile(*os.File) ([]byte, error) {
+	return nil, errors.New("mmap not supported on this platform")
+}
+
+func munmapFile([]byte) error { return nil }
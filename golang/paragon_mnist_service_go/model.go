@@ -1,18 +1,79 @@
 package main
 
 import (
+	"context"
+	"embed"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
-	"time"
+	"os"
+	"runtime"
+	"sort"
+	"sync/atomic"
 
 	"github.com/openfluke/paragon/v3"
 )
 
+// ParagonHandle is a pool of identical copies of one network. Forward
+// mutates neuron state that ExtractOutput reads back, so each copy serves one
+// caller at a time; callers check a copy out and return it when done.
 type ParagonHandle struct {
-	nn *paragon.Network[float32]
+	pool chan network
+	size int
+	in   inputShape
+	id   uint64       // unique per handle, so caches can tell model versions apart
+	q    *quantScales // set on int8 copies: inputs are scaled and outputs dequantized
+
+	quant int8Copy // the int8 copy of this handle, see QUANTIZE_INT8
+
+	inUse, checkouts, waits atomic.Int64
+}
+
+// inputShape is the image a model consumes: W×H pixels, flattened into a
+// single row when the input layer is a (W*H, 1) vector.
+type inputShape struct {
+	W, H int
+	Flat bool
+}
+
+// defaultInput is the MNIST image size used for generated models.
+var defaultInput = inputShape{W: 28, H: 28}
+
+// inputShapeFor maps a model's first layer to the image it expects. A flat
+// (N,1) layer is read as a square image when N is a perfect square, which
+// covers the classic (784,1) MNIST vector.
+func inputShapeFor(layer struct{ Width, Height int }) (inputShape, error) {
+	if layer.Width <= 0 || layer.Height <= 0 {
+		return inputShape{}, fmt.Errorf("bad input layer %dx%d", layer.Width, layer.Height)
+	}
+	if layer.Height == 1 && layer.Width > 1 {
+		side := int(math.Sqrt(float64(layer.Width)) + 0.5)
+		if side*side != layer.Width {
+			return inputShape{}, fmt.Errorf("flat input layer of %d can't be mapped to a square image", layer.Width)
+		}
+		return inputShape{W: side, H: side, Flat: true}, nil
+	}
+	return inputShape{W: layer.Width, H: layer.Height}, nil
+}
+
+// Input reports the image dimensions h expects.
+func (h *ParagonHandle) Input() inputShape { return h.in }
+
+// modelSnapshot is a marshaled model plus the topology and numeric type
+// needed to rebuild it.
+type modelSnapshot struct {
+	shapes    []struct{ Width, Height int }
+	acts      []string
+	trainable []bool
+	state     []byte
+	numType   string
 }
 
+// originalModel is the state as last loaded from disk (startup or /reload),
+// kept for /model/reset.
+var originalModel *modelSnapshot
+
 func initializeModels(modelPath string) (*ParagonHandle, *ParagonHandle, bool, error) {
 	// Create a minimal model if missing
 	if ok, _ := fileExists(modelPath); !ok {
@@ -20,82 +81,466 @@ func initializeModels(modelPath string) (*ParagonHandle, *ParagonHandle, bool, e
 			return nil, nil, false, err
 		}
 	}
+	snap, err := loadModelSnapshot(modelPath)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	originalModel = snap
+	return handlesFromSnapshot(snap)
+}
 
-	// Load JSON (type-aware), then reconstruct float32 net and copy weights
+// loadModelSnapshot reads a model JSON (type-aware) into a snapshot of
+// whatever numeric type it was saved as.
+var errNumericType = errors.New("unsupported numeric type")
+
+func loadModelSnapshot(modelPath string) (*modelSnapshot, error) {
 	loaded, err := paragon.LoadNamedNetworkFromJSONFile(modelPath)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, err
 	}
-	tmp, ok := loaded.(*paragon.Network[float32])
-	if !ok {
-		return nil, nil, false, errors.New("model is not float32")
+	tmp, err := wrapNetwork(loaded)
+	if err != nil {
+		return nil, err
 	}
-	shapes, activs, trainable := topologyFrom(tmp)
+	return snapshotOf(tmp)
+}
 
-	// CPU handle
-	nnCPU, err := paragon.NewNetwork[float32](shapes, activs, trainable)
+func snapshotOf(nn network) (*modelSnapshot, error) {
+	shapes, acts, tr := nn.topology()
+	state, err := nn.MarshalJSONModel()
 	if err != nil {
-		return nil, nil, false, err
+		return nil, err
 	}
-	state, _ := tmp.MarshalJSONModel()
-	if err := nnCPU.UnmarshalJSONModel(state); err != nil {
+	return &modelSnapshot{shapes, acts, tr, state, nn.numericType()}, nil
+}
+
+// CPU_POOL_SIZE / GPU_POOL_SIZE set how many copies of the model each handle
+// keeps so that many forwards run in parallel (default 1 each; every GPU copy
+// holds its own pipeline and buffers).
+var (
+	cpuPoolSize = max(getEnvInt("CPU_POOL_SIZE", 1), 1)
+	gpuPoolSize = max(getEnvInt("GPU_POOL_SIZE", 1), 1)
+)
+
+// handlesFromSnapshot builds a fresh CPU handle and a GPU handle (falling back
+// to CPU-only if GPU init fails) from a marshaled model.
+func handlesFromSnapshot(s *modelSnapshot) (*ParagonHandle, *ParagonHandle, bool, error) {
+	return handlesFromSnapshotN(s, cpuPoolSize, gpuPoolSize)
+}
+
+// handlesFromSnapshotN is handlesFromSnapshot with explicit pool sizes; a
+// gpuN of 0 skips the GPU and returns a nil GPU handle.
+func handlesFromSnapshotN(s *modelSnapshot, cpuN, gpuN int) (*ParagonHandle, *ParagonHandle, bool, error) {
+	if len(s.shapes) == 0 {
+		return nil, nil, false, errors.New("model has no layers")
+	}
+	in, err := inputShapeFor(s.shapes[0])
+	if err != nil {
 		return nil, nil, false, err
 	}
 
+	// CPU handle
+	cpuNets := make([]network, cpuN)
+	for i := range cpuNets {
+		if cpuNets[i], err = networkFromSnapshot(s); err != nil {
+			return nil, nil, false, err
+		}
+	}
+
 	// GPU handle (optional)
-	nnGPU, err := paragon.NewNetwork[float32](shapes, activs, trainable)
+	if gpuN == 0 {
+		return newHandle(cpuNets, in), nil, false, nil
+	}
+	gpuNets := make([]network, gpuN)
+	gpuOK := true
+	for i := range gpuNets {
+		if gpuNets[i], err = networkFromSnapshot(s); err != nil {
+			return nil, nil, false, err
+		}
+		if !gpuOK {
+			continue
+		}
+		gpuNets[i].setGPUNative(true)
+		if err := gpuNets[i].InitializeOptimizedGPU(); err != nil {
+			// fall back to CPU-only if GPU init fails
+			warnf("GPU init failed, serving CPU only: %v", err)
+			gpuOK = false
+			gpuNets[i].setGPUNative(false)
+			continue
+		}
+		_ = warmupGPU(gpuNets[i], warmupDefault)
+	}
+	if !gpuOK {
+		for _, nn := range gpuNets {
+			if nn.gpuNative() {
+				nn.CleanupOptimizedGPU()
+				nn.setGPUNative(false)
+			}
+		}
+	}
+
+	return newHandle(cpuNets, in), newHandle(gpuNets, in), gpuOK, nil
+}
+
+func networkFromSnapshot(s *modelSnapshot) (network, error) {
+	nn, err := newNetwork(s.numType, s.shapes, s.acts, s.trainable)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, err
 	}
-	if err := nnGPU.UnmarshalJSONModel(state); err != nil {
-		return nil, nil, false, err
+	if err := nn.UnmarshalJSONModel(s.state); err != nil {
+		return nil, err
 	}
-	nnGPU.WebGPUNative = true
+	return nn, nil
+}
 
-	gpuOK := true
-	start := time.Now()
-	if err := nnGPU.InitializeOptimizedGPU(); err != nil {
-		// fall back to CPU-only if GPU init fails
-		gpuOK = false
-		nnGPU.WebGPUNative = false
-	} else {
-		_ = warmupGPU(nnGPU)
+var handleSeq atomic.Uint64
+
+// liveHandles and liveNetworks count handles, and the network copies in
+// them, that haven't been garbage collected yet; see /admin/runtime.
+var liveHandles, liveNetworks atomic.Int64
+
+func newHandle(nets []network, in inputShape) *ParagonHandle {
+	h := &ParagonHandle{pool: make(chan network, len(nets)), size: len(nets), in: in, id: handleSeq.Add(1)}
+	for _, nn := range nets {
+		h.pool <- nn
+	}
+	liveHandles.Add(1)
+	liveNetworks.Add(int64(len(nets)))
+	runtime.AddCleanup(h, func(n int64) {
+		liveHandles.Add(-1)
+		liveNetworks.Add(-n)
+	}, int64(len(nets)))
+	return h
+}
+
+// acquire checks a network out of the pool, blocking while all are busy.
+func (h *ParagonHandle) acquire() network {
+	var nn network
+	select {
+	case nn = <-h.pool:
+	default:
+		h.waits.Add(1)
+		nn = <-h.pool
 	}
-	_ = start
+	h.inUse.Add(1)
+	h.checkouts.Add(1)
+	return nn
+}
+
+func (h *ParagonHandle) put(nn network) {
+	h.inUse.Add(-1)
+	h.pool <- nn
+}
+
+// PoolStats is exposed under /metrics.
+type PoolStats struct {
+	Size      int   `json:"size"`
+	InUse     int64 `json:"in_use"`
+	Checkouts int64 `json:"checkouts"`
+	Waits     int64 `json:"waits"` // checkouts that found every copy busy
+}
 
-	return &ParagonHandle{nnCPU}, &ParagonHandle{nnGPU}, gpuOK, nil
+func (h *ParagonHandle) Stats() PoolStats {
+	return PoolStats{Size: h.size, InUse: h.inUse.Load(), Checkouts: h.checkouts.Load(), Waits: h.waits.Load()}
+}
+
+// release frees the GPU pipelines behind h, if any, once in-flight forwards finish.
+func (h *ParagonHandle) release() {
+	if h == nil {
+		return
+	}
+	nets := make([]network, h.size)
+	for i := range nets {
+		nets[i] = <-h.pool
+	}
+	for _, nn := range nets {
+		if nn.gpuNative() {
+			nn.CleanupOptimizedGPU()
+			// a request still holding h falls back to the CPU path
+			nn.setGPUNative(false)
+		}
+		h.pool <- nn
+	}
 }
 
-func warmupGPU(nn *paragon.Network[float32]) error {
-	// 28x28 zeros just to compile pipeline once
-	img := make([][]float64, 28)
-	for r := 0; r < 28; r++ {
-		row := make([]float64, 28)
-		img[r] = row
+// reinitGPU tears down and rebuilds the WebGPU pipelines of every copy in h,
+// e.g. after device loss, keeping the weights. It waits for in-flight
+// forwards; on failure every copy is left on the CPU path.
+func (h *ParagonHandle) reinitGPU() error {
+	nets := make([]network, h.size)
+	for i := range nets {
+		nets[i] = <-h.pool
+	}
+	defer func() {
+		for _, nn := range nets {
+			h.pool <- nn
+		}
+	}()
+	for _, nn := range nets {
+		if nn.gpuNative() {
+			nn.CleanupOptimizedGPU()
+			nn.setGPUNative(false)
+		}
+	}
+	for i, nn := range nets {
+		nn.setGPUNative(true)
+		if err := nn.InitializeOptimizedGPU(); err != nil {
+			nn.setGPUNative(false)
+			for _, done := range nets[:i] {
+				done.CleanupOptimizedGPU()
+				done.setGPUNative(false)
+			}
+			return err
+		}
+		_ = warmupGPU(nn, warmupDefault)
 	}
-	nn.Forward(img)
-	_ = nn.ExtractOutput()
 	return nil
 }
 
-func (h *ParagonHandle) Forward(img [][]float64) {
-	h.nn.Forward(img)
+// snapshot captures topology and weights so a copy can be trained off to the
+// side without blocking inference on h.
+func (h *ParagonHandle) snapshot() (*modelSnapshot, error) {
+	nn := h.acquire()
+	defer h.put(nn)
+	return snapshotOf(nn)
+}
+
+// ADHD runs Paragon's accuracy-deviation evaluation on h and returns a copy
+// of the resulting report.
+func (h *ParagonHandle) ADHD(expected, actual []float64) paragon.ADHDPerformance {
+	nn := h.acquire()
+	defer h.put(nn)
+	nn.EvaluateModel(expected, actual)
+	perf := nn.performance()
+	if perf == nil {
+		return paragon.ADHDPerformance{}
+	}
+	return *perf
+}
+
+func (h *ParagonHandle) NumLayers() int {
+	nn := h.acquire()
+	defer h.put(nn)
+	return nn.numLayers()
+}
+
+// InferLayer runs a forward pass and returns the neuron values of layer as
+// Height rows of Width. On the GPU path Paragon may not copy hidden layers
+// back to the host, so those values can be stale; compare against cpu.
+func (h *ParagonHandle) InferLayer(img [][]float64, layer int) ([][]float64, string, error) {
+	if h.in.Flat {
+		img = flatten(img)
+	}
+	nn := h.acquire()
+	defer h.put(nn)
+	if n := nn.numLayers(); layer < 0 || layer >= n {
+		return nil, "", fmt.Errorf("layer %d out of range [0,%d)", layer, n)
+	}
+	nn.Forward(h.q.input(img))
+	vals, act := nn.layerValues(layer)
+	return h.q.layer(vals, layer), act, nil
+}
+
+// Export serializes the network's current weights. All copies in the pool
+// share the same weights, so any one will do.
+func (h *ParagonHandle) Export() ([]byte, error) {
+	nn := h.acquire()
+	defer h.put(nn)
+	return nn.MarshalJSONModel()
+}
+
+// flatten turns an HxW image into the single row a (W*H,1) input layer takes.
+func flatten(img [][]float64) [][]float64 {
+	row := make([]float64, 0, len(img)*len(img[0]))
+	for _, r := range img {
+		row = append(row, r...)
+	}
+	return [][]float64{row}
+}
+
+// InferBatch runs imgs back to back on a single checked-out copy.
+func (h *ParagonHandle) InferBatch(imgs [][][]float64) [][]float64 {
+	nn := h.acquire()
+	defer h.put(nn)
+	outs := make([][]float64, len(imgs))
+	for i, img := range imgs {
+		if h.in.Flat {
+			img = flatten(img)
+		}
+		nn.Forward(h.q.input(img))
+		outs[i] = h.q.output(nn.ExtractOutput())
+	}
+	return outs
 }
-func (h *ParagonHandle) ExtractOutput() []float64 {
-	return h.nn.ExtractOutput()
+
+// Infer runs Forward+ExtractOutput on a checked-out copy, so the pair never
+// interleaves with another caller's. img is H×W as returned by the loaders;
+// it is flattened for vector inputs.
+func (h *ParagonHandle) Infer(img [][]float64) []float64 {
+	if h.in.Flat {
+		img = flatten(img)
+	}
+	nn := h.acquire()
+	defer h.put(nn)
+	nn.Forward(h.q.input(img))
+	return h.q.output(nn.ExtractOutput())
 }
 
 func forwardProbs(h *ParagonHandle, img [][]float64) (*ProbResult, error) {
-	h.Forward(img)
-	out := h.ExtractOutput() // already post-activation
+	return forwardProbsCtx(context.Background(), h, img)
+}
+
+// inferCtx stops waiting on h.Infer once ctx ends. Paragon can't abort a
+// forward, so it runs to completion in the background and keeps h locked
+// until then; the caller is just no longer tied to it.
+func inferCtx(ctx context.Context, h *ParagonHandle, img [][]float64) ([]float64, error) {
+	if microBatcher != nil {
+		return microBatcher.infer(ctx, h, img)
+	}
+	if ctx.Done() == nil {
+		return h.Infer(img), nil
+	}
+	done := make(chan []float64, 1)
+	go func() { done <- h.Infer(img) }()
+	select {
+	case out := <-done:
+		return out, nil
+	case <-ctx.Done():
+		return nil, ctxError(ctx.Err())
+	}
+}
+
+// forwardProbsCtx is forwardProbs with "forward" and "softmax" spans under ctx.
+func forwardProbsCtx(ctx context.Context, h *ParagonHandle, img [][]float64) (*ProbResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, ctxError(err)
+	}
+	_, fs := startSpan(ctx, "forward")
+	out, err := inferCtx(ctx, h, img) // already post-activation
+	if err != nil {
+		fs.SetError(err)
+		fs.End()
+		return nil, err
+	}
 	if len(out) < 10 {
-		return nil, fmt.Errorf("output too small: %d", len(out))
+		err := fmt.Errorf("output too small: %d", len(out))
+		fs.SetError(err)
+		fs.End()
+		return nil, err
 	}
+	fs.End()
+	_, ss := startSpan(ctx, "softmax")
+	defer ss.End()
 	probs := out[len(out)-10:] // last layer is softmax → these ARE probabilities
+	if calibration != nil {
+		cal := calibration.apply(probs)
+		return &ProbResult{Pred: argmax(cal), Probs: cal, RawProbs: probs}, nil
+	}
 	pred := argmax(probs)
 	return &ProbResult{Pred: pred, Probs: probs}, nil
 }
 
+// Calibration is a temperature fit offline (calibration.json: {"temperature": T}).
+type Calibration struct {
+	Temperature float64 `json:"temperature"`
+	Source      string  `json:"source"`
+}
+
+// calibration is nil when no calibration file is loaded.
+var calibration *Calibration
+
+func loadCalibration(path string) (*Calibration, error) {
+	if ok, _ := fileExists(path); !ok {
+		return nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var c Calibration
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	if c.Temperature <= 0 || math.IsNaN(c.Temperature) || math.IsInf(c.Temperature, 0) {
+		return nil, fmt.Errorf("temperature must be > 0, got %v", c.Temperature)
+	}
+	c.Source = path
+	return &c, nil
+}
+
+// apply rescales softmax probabilities by 1/T. log(p) recovers the logits up
+// to a constant, which softmax ignores.
+func (c *Calibration) apply(probs []float64) []float64 {
+	logits := make([]float64, len(probs))
+	for i, p := range probs {
+		logits[i] = math.Log(math.Max(p, 1e-12)) / c.Temperature
+	}
+	return softmax(logits)
+}
+
+// ensemble conflict policies (ENSEMBLE_CONFLICT)
+const (
+	conflictAverage = "average" // average the two distributions anyway
+	conflictCPU     = "cpu"     // trust the CPU result
+	conflictFlag    = "flag"    // average, but flag and return both results
+)
+
+type EnsembleResult struct {
+	ProbResult
+	Conflict bool
+	Applied  string // policy that decided the result; "average" when no conflict
+	CPU, GPU *ProbResult
+}
+
+// ensembleProbs runs img on both handles and averages the probabilities,
+// resolving argmax disagreements according to policy.
+func ensembleProbs(cpu, gpu *ParagonHandle, img [][]float64, policy string) (*EnsembleResult, error) {
+	c, err := forwardProbs(cpu, img)
+	if err != nil {
+		return nil, fmt.Errorf("cpu: %w", err)
+	}
+	g, err := forwardProbs(gpu, img)
+	if err != nil {
+		return nil, fmt.Errorf("gpu: %w", err)
+	}
+	avg := make([]float64, len(c.Probs))
+	for i := range avg {
+		avg[i] = (c.Probs[i] + g.Probs[i]) / 2
+	}
+	res := &EnsembleResult{
+		ProbResult: ProbResult{Pred: argmax(avg), Probs: avg},
+		Conflict:   c.Pred != g.Pred,
+		Applied:    conflictAverage,
+		CPU:        c,
+		GPU:        g,
+	}
+	if res.Conflict {
+		res.Applied = policy
+		if policy == conflictCPU {
+			res.ProbResult = ProbResult{Pred: c.Pred, Probs: c.Probs}
+		}
+	}
+	return res, nil
+}
+
+// diffStats is the bench tool's CPU-vs-GPU comparison: mean and max absolute
+// difference over the overlapping prefix of a and b.
+func diffStats(a, b []float64) (mae, maxd float64, n int) {
+	n = min(len(a), len(b))
+	if n == 0 {
+		return 0, 0, 0
+	}
+	var sum, maxAbs float64
+	for i := 0; i < n; i++ {
+		d := math.Abs(a[i] - b[i])
+		sum += d
+		if d > maxAbs {
+			maxAbs = d
+		}
+	}
+	return sum / float64(n), maxAbs, n
+}
+
 func softmax(x []float64) []float64 {
 	maxv := x[0]
 	for _, v := range x[1:] {
@@ -116,6 +561,25 @@ func softmax(x []float64) []float64 {
 	return exp
 }
 
+type ClassProb struct {
+	Class int     `json:"class"`
+	Name  string  `json:"name,omitempty"` // dataset class name, e.g. "Sneaker"
+	Prob  float64 `json:"prob"`
+}
+
+// topK returns the k most probable classes, highest first (ties by class index).
+func topK(probs []float64, k int) []ClassProb {
+	out := make([]ClassProb, len(probs))
+	for i, p := range probs {
+		out[i] = ClassProb{Class: i, Name: className(i), Prob: p}
+	}
+	sort.SliceStable(out, func(a, b int) bool { return out[a].Prob > out[b].Prob })
+	if k > len(out) {
+		k = len(out)
+	}
+	return out[:k]
+}
+
 func argmax(v []float64) int {
 	best, idx := v[0], 0
 	for i := 1; i < len(v); i++ {
@@ -126,33 +590,157 @@ func argmax(v []float64) int {
 	return idx
 }
 
-// Best-effort topology extraction; keeps the same layer shapes/activations/trainable
-func topologyFrom(tmp *paragon.Network[float32]) ([]struct{ Width, Height int }, []string, []bool) {
-	n := len(tmp.Layers)
-	shapes := make([]struct{ Width, Height int }, n)
-	acts := make([]string, n)
-	tr := make([]bool, n)
+// ModelInfo describes the served network for GET /model/info.
+type ModelInfo struct {
+	Model       string      `json:"model,omitempty"`
+	Dataset     string      `json:"dataset"`
+	Classes     []string    `json:"classes,omitempty"`
+	NumericType string      `json:"numeric_type"`
+	Layers      []LayerInfo `json:"layers"`
+	Params      int64       `json:"params"`      // weights + biases
+	EstVRAMMB   float64     `json:"est_vram_mb"` // params × numeric type size, same estimate as bench_paragon.go
+	Input       []int       `json:"input"`       // [width, height] the service decodes to
+}
 
-	for i, L := range tmp.Layers {
-		shapes[i] = struct{ Width, Height int }{L.Width, L.Height}
-		act := "linear"
-		if L.Height > 0 && L.Width > 0 && L.Neurons != nil && len(L.Neurons) > 0 && len(L.Neurons[0]) > 0 && L.Neurons[0][0] != nil {
-			act = L.Neurons[0][0].Activation
+type LayerInfo struct {
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Activation string `json:"activation"`
+	Trainable  bool   `json:"trainable"`
+}
+
+func (h *ParagonHandle) Info() ModelInfo {
+	nn := h.acquire()
+	defer h.put(nn)
+	shapes, acts, tr := nn.topology()
+	info := ModelInfo{NumericType: nn.numericType(), Input: []int{h.in.W, h.in.H}, Params: nn.params()}
+	for i, sh := range shapes {
+		info.Layers = append(info.Layers, LayerInfo{Width: sh.Width, Height: sh.Height, Activation: acts[i], Trainable: tr[i]})
+	}
+	info.EstVRAMMB = estimateMB(info.Params, nn.bytesPerParam())
+	return info
+}
+
+// estimateMB is the size of params values of bytes each, as bench_paragon.go's
+// estimateVramMB counts it: weights and biases, nothing for activations.
+func estimateMB(params int64, bytes int) float64 {
+	return round6(float64(params) * float64(bytes) / (1024 * 1024))
+}
+
+// ModelFootprint is how much memory a model takes, returned by
+// /model/footprint. Paragon doesn't report the WebGPU buffers it allocates,
+// so GPU figures are estimates.
+type ModelFootprint struct {
+	Model           string        `json:"model"`
+	NumericType     string        `json:"numeric_type"`
+	Params          int64         `json:"params"`
+	BytesPerParam   int           `json:"bytes_per_param"`
+	WeightsMB       float64       `json:"weights_mb"`       // one copy
+	SerializedBytes int           `json:"serialized_bytes"` // JSON as GET /models/{name}/export returns it
+	CPU             PoolFootprint `json:"cpu"`
+	GPU             PoolFootprint `json:"gpu"`
+}
+
+// PoolFootprint is the memory of a handle's pooled copies.
+type PoolFootprint struct {
+	Available bool    `json:"available"`
+	Copies    int     `json:"copies"`
+	EstMB     float64 `json:"est_mb"` // copies × weights_mb
+}
+
+// Footprint measures the model behind h, with gpu its GPU twin (nil or not
+// ok when serving CPU only).
+func (h *ParagonHandle) Footprint(gpu *ParagonHandle, ok bool) (ModelFootprint, error) {
+	nn := h.acquire()
+	state, err := nn.MarshalJSONModel()
+	f := ModelFootprint{NumericType: nn.numericType(), Params: nn.params(), BytesPerParam: nn.bytesPerParam(), SerializedBytes: len(state)}
+	h.put(nn)
+	if err != nil {
+		return f, err
+	}
+	f.WeightsMB = estimateMB(f.Params, f.BytesPerParam)
+	f.CPU = PoolFootprint{Available: true, Copies: h.size, EstMB: round6(f.WeightsMB * float64(h.size))}
+	if gpu != nil && ok {
+		f.GPU = PoolFootprint{Available: true, Copies: gpu.size, EstMB: round6(f.WeightsMB * float64(gpu.size))}
+	}
+	return f, nil
+}
+
+var knownActivations = map[string]bool{
+	"linear": true, "relu": true, "leaky_relu": true, "elu": true,
+	"sigmoid": true, "tanh": true, "softmax": true,
+}
+
+// validateTopology checks a requested topology can be served: an input layer
+// the PNG loader can target, sane layer sizes and known activations, and at
+// least 10 outputs for the class probabilities.
+func validateTopology(shapes []struct{ Width, Height int }, acts []string) error {
+	if len(shapes) < 2 {
+		return errors.New("need at least an input and an output layer")
+	}
+	if len(acts) != len(shapes) {
+		return fmt.Errorf("got %d activations for %d layers", len(acts), len(shapes))
+	}
+	if _, err := inputShapeFor(shapes[0]); err != nil {
+		return err
+	}
+	for i, s := range shapes {
+		if s.Width <= 0 || s.Height <= 0 || s.Width*s.Height > 1<<16 {
+			return fmt.Errorf("layer %d: bad shape %dx%d", i, s.Width, s.Height)
+		}
+		if !knownActivations[acts[i]] {
+			return fmt.Errorf("layer %d: unknown activation %q", i, acts[i])
 		}
-		acts[i], tr[i] = act, true
 	}
-	return shapes, acts, tr
+	if out := shapes[len(shapes)-1]; out.Width*out.Height < 10 {
+		return fmt.Errorf("output layer has %d units, need at least 10", out.Width*out.Height)
+	}
+	return nil
+}
+
+// newModelSnapshot builds a freshly initialized network of numType ("" is
+// float32) for a topology.
+func newModelSnapshot(numType string, shapes []struct{ Width, Height int }, acts []string) (network, *modelSnapshot, error) {
+	train := make([]bool, len(shapes))
+	for i := range train {
+		train[i] = true
+	}
+	nn, err := newNetwork(numType, shapes, acts, train)
+	if err != nil {
+		return nil, nil, err
+	}
+	state, err := nn.MarshalJSONModel()
+	if err != nil {
+		return nil, nil, err
+	}
+	return nn, &modelSnapshot{shapes, acts, train, state, nn.numericType()}, nil
 }
 
+// defaultModels holds a trained model per dataset, gzipped Paragon JSON, for
+// when MODEL_JSON is missing; see defaultmodels/README.md.
+//
+//go:embed defaultmodels
+var defaultModels embed.FS
+
+// createDefaultModelJSON writes the embedded model of DATASET to path, or an
+// untrained network when there is none. Its predictions are noise, so that
+// case says so loudly rather than passing for a real model.
 func createDefaultModelJSON(path string) error {
-	// shapes [(28,28), (256,1), (10,1)] with activations ["linear","relu","softmax"]
+	if f, err := defaultModels.Open("defaultmodels/" + activeDataset.name + ".json.gz"); err == nil {
+		defer f.Close()
+		infof("📦 %s missing, writing the embedded trained %s model", path, activeDataset.name)
+		return writeGzipped(path, f)
+	}
+	warnf("⚠️  %s missing and no embedded %s model; generating an UNTRAINED network, predictions are random until it is trained (POST /train) or replaced", path, activeDataset.name)
+
+	// shapes [(W,H), (256,1), (10,1)] with activations ["linear","relu","softmax"]
 	shapes := []struct{ Width, Height int }{
-		{28, 28}, {256, 1}, {10, 1},
+		{defaultInput.W, defaultInput.H}, {256, 1}, {10, 1},
 	}
 	acts := []string{"linear", "relu", "softmax"}
 	train := []bool{true, true, true}
 
-	nn, err := paragon.NewNetwork[float32](shapes, acts, train)
+	nn, err := newNetwork("float32", shapes, acts, train)
 	if err != nil {
 		return err
 	}
@@ -160,3 +748,60 @@ func createDefaultModelJSON(path string) error {
 }
 
 func round6(x float64) float64 { return math.Round(x*1e6) / 1e6 }
+
+// occlusionHeatmap slides a zeroed patch×patch square across img (stepping by
+// stride) and records how much each occlusion drops the probability of the
+// unoccluded top class. Each pixel gets the mean drop of the patches covering it.
+func occlusionHeatmap(h *ParagonHandle, img [][]float64, patch, stride int) (*ProbResult, [][]float64, error) {
+	base, err := forwardProbs(h, img)
+	if err != nil {
+		return nil, nil, err
+	}
+	baseProb := base.Probs[base.Pred]
+	rows, cols := len(img), len(img[0])
+	sum := make([][]float64, rows)
+	cnt := make([][]int, rows)
+	for r := range sum {
+		sum[r] = make([]float64, cols)
+		cnt[r] = make([]int, cols)
+	}
+
+	work := make([][]float64, rows)
+	for r := range work {
+		work[r] = make([]float64, cols)
+	}
+	for y := 0; y+patch <= rows; y += stride {
+		for x := 0; x+patch <= cols; x += stride {
+			for r := range img {
+				copy(work[r], img[r])
+			}
+			for r := y; r < y+patch; r++ {
+				for c := x; c < x+patch; c++ {
+					work[r][c] = 0
+				}
+			}
+			out, err := forwardProbs(h, work)
+			if err != nil {
+				return nil, nil, err
+			}
+			drop := baseProb - out.Probs[base.Pred]
+			for r := y; r < y+patch; r++ {
+				for c := x; c < x+patch; c++ {
+					sum[r][c] += drop
+					cnt[r][c]++
+				}
+			}
+		}
+	}
+
+	heat := make([][]float64, rows)
+	for r := range heat {
+		heat[r] = make([]float64, cols)
+		for c := range heat[r] {
+			if cnt[r][c] > 0 {
+				heat[r][c] = round6(sum[r][c] / float64(cnt[r][c]))
+			}
+		}
+	}
+	return base, heat, nil
+}
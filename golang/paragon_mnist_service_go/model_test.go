@@ -0,0 +1,64 @@
+package main
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"testing"
+)
+
+// TestInferConcurrent fires more goroutines than the handle has network
+// copies, each with its own input, and checks every output matches the one
+// computed serially. Run with -race.
+func TestInferConcurrent(t *testing.T) {
+	h := useTestModel(t)
+	const n = 32
+	rng := rand.New(rand.NewPCG(1, 2))
+	imgs := make([][][]float64, n)
+	want := make([][]float64, n)
+	for i := range imgs {
+		imgs[i] = make([][]float64, h.Input().H)
+		for y := range imgs[i] {
+			imgs[i][y] = make([]float64, h.Input().W)
+			for x := range imgs[i][y] {
+				imgs[i][y][x] = rng.Float64()
+			}
+		}
+		want[i] = h.Infer(imgs[i])
+	}
+	if slices.Equal(want[0], want[1]) {
+		t.Fatal("distinct inputs gave identical outputs; the test can't tell them apart")
+	}
+
+	got := make([][]float64, n)
+	probs := make([]*ProbResult, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if i%2 == 0 {
+				got[i] = h.Infer(imgs[i])
+			} else {
+				probs[i], errs[i] = forwardProbs(h, imgs[i])
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i := range n {
+		if i%2 == 0 {
+			if !slices.Equal(got[i], want[i]) {
+				t.Errorf("Infer %d: got %v, want %v", i, got[i], want[i])
+			}
+			continue
+		}
+		if errs[i] != nil {
+			t.Fatalf("forwardProbs %d: %v", i, errs[i])
+		}
+		if p := probs[i].Pred; p != argmax(want[i]) {
+			t.Errorf("forwardProbs %d: predicted %d, want %d", i, p, argmax(want[i]))
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"unsafe"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// network is the part of a paragon.Network[T] the service uses, so models of
+// any numeric type Paragon supports can be loaded, served and trained.
+// paragonNet adapts each instantiation, exposing the fields it needs as methods.
+type network interface {
+	Forward(inputs [][]float64)
+	ExtractOutput() []float64
+	ComputeLoss(target [][]float64) float64
+	EvaluateModel(expected, actual []float64)
+	MarshalJSONModel() ([]byte, error)
+	UnmarshalJSONModel(data []byte) error
+	SaveJSON(path string) error
+	InitializeOptimizedGPU() error
+	CleanupOptimizedGPU()
+
+	numericType() string
+	bytesPerParam() int
+	gpuNative() bool
+	setGPUNative(on bool)
+	performance() *paragon.ADHDPerformance
+	topology() ([]struct{ Width, Height int }, []string, []bool)
+	numLayers() int
+	params() int64
+	layerValues(layer int) ([][]float64, string)
+	train(inputs, targets [][][]float64, lr, clip float64)
+}
+
+type paragonNet[T paragon.Numeric] struct{ *paragon.Network[T] }
+
+func (n paragonNet[T]) numericType() string { var z T; return fmt.Sprintf("%T", z) }
+func (n paragonNet[T]) bytesPerParam() int  { var z T; return int(unsafe.Sizeof(z)) }
+func (n paragonNet[T]) gpuNative() bool     { return n.WebGPUNative }
+func (n paragonNet[T]) setGPUNative(on bool) {
+	n.WebGPUNative = on
+}
+func (n paragonNet[T]) performance() *paragon.ADHDPerformance { return n.Performance }
+
+// topology is a best-effort extraction that keeps the same layer
+// shapes/activations/trainable, enough to rebuild the network.
+func (n paragonNet[T]) topology() ([]struct{ Width, Height int }, []string, []bool) {
+	shapes := make([]struct{ Width, Height int }, len(n.Layers))
+	acts := make([]string, len(n.Layers))
+	tr := make([]bool, len(n.Layers))
+	for i, L := range n.Layers {
+		shapes[i] = struct{ Width, Height int }{L.Width, L.Height}
+		act := "linear"
+		if L.Height > 0 && L.Width > 0 && len(L.Neurons) > 0 && len(L.Neurons[0]) > 0 && L.Neurons[0][0] != nil {
+			act = L.Neurons[0][0].Activation
+		}
+		acts[i], tr[i] = act, true
+	}
+	return shapes, acts, tr
+}
+
+func (n paragonNet[T]) numLayers() int { return len(n.Layers) }
+
+// params counts weights and biases from the actual connections, so it is
+// right for non-dense layers too.
+func (n paragonNet[T]) params() int64 {
+	var p int64
+	for i := 1; i < len(n.Layers); i++ {
+		for _, row := range n.Layers[i].Neurons {
+			for _, nr := range row {
+				if nr != nil {
+					p += int64(len(nr.Inputs)) + 1
+				}
+			}
+		}
+	}
+	return p
+}
+
+// layerValues returns the neuron values of a layer as Height rows of Width,
+// and the layer's activation.
+func (n paragonNet[T]) layerValues(layer int) ([][]float64, string) {
+	L := n.Layers[layer]
+	vals := make([][]float64, L.Height)
+	act := "linear"
+	for y := 0; y < L.Height; y++ {
+		vals[y] = make([]float64, L.Width)
+		for x := 0; x < L.Width; x++ {
+			if nr := L.Neurons[y][x]; nr != nil {
+				vals[y][x] = float64(nr.Value)
+				act = nr.Activation
+			}
+		}
+	}
+	return vals, act
+}
+
+// train runs one epoch over the batch, clipping gradients to ±clip (0..clip
+// for unsigned types).
+func (n paragonNet[T]) train(inputs, targets [][][]float64, lr, clip float64) {
+	upper, lower := clip, -clip
+	var z T
+	if z-1 > z {
+		lower = 0
+	}
+	n.Train(inputs, targets, 1, lr, false, T(upper), T(lower))
+}
+
+// wrapNetwork adapts a network returned by paragon.LoadNamedNetworkFromJSONFile.
+func wrapNetwork(v any) (network, error) {
+	switch nn := v.(type) {
+	case *paragon.Network[float32]:
+		return paragonNet[float32]{nn}, nil
+	case *paragon.Network[float64]:
+		return paragonNet[float64]{nn}, nil
+	case *paragon.Network[int8]:
+		return paragonNet[int8]{nn}, nil
+	case *paragon.Network[int16]:
+		return paragonNet[int16]{nn}, nil
+	case *paragon.Network[int32]:
+		return paragonNet[int32]{nn}, nil
+	case *paragon.Network[int64]:
+		return paragonNet[int64]{nn}, nil
+	case *paragon.Network[uint8]:
+		return paragonNet[uint8]{nn}, nil
+	case *paragon.Network[uint16]:
+		return paragonNet[uint16]{nn}, nil
+	case *paragon.Network[uint32]:
+		return paragonNet[uint32]{nn}, nil
+	case *paragon.Network[uint64]:
+		return paragonNet[uint64]{nn}, nil
+	}
+	return nil, fmt.Errorf("%w: %T", errNumericType, v)
+}
+
+// newNetwork builds an untrained network of the given numeric type ("" is
+// float32), the names numericType reports.
+func newNetwork(numType string, shapes []struct{ Width, Height int }, acts []string, trainable []bool) (network, error) {
+	switch numType {
+	case "", "float32":
+		return buildNetwork[float32](shapes, acts, trainable)
+	case "float64":
+		return buildNetwork[float64](shapes, acts, trainable)
+	case "int8":
+		return buildNetwork[int8](shapes, acts, trainable)
+	case "int16":
+		return buildNetwork[int16](shapes, acts, trainable)
+	case "int32":
+		return buildNetwork[int32](shapes, acts, trainable)
+	case "int64":
+		return buildNetwork[int64](shapes, acts, trainable)
+	case "uint8":
+		return buildNetwork[uint8](shapes, acts, trainable)
+	case "uint16":
+		return buildNetwork[uint16](shapes, acts, trainable)
+	case "uint32":
+		return buildNetwork[uint32](shapes, acts, trainable)
+	case "uint64":
+		return buildNetwork[uint64](shapes, acts, trainable)
+	}
+	return nil, fmt.Errorf("%w: %q", errNumericType, numType)
+}
+
+func buildNetwork[T paragon.Numeric](shapes []struct{ Width, Height int }, acts []string, trainable []bool) (network, error) {
+	nn, err := paragon.NewNetwork[T](shapes, acts, trainable)
+	if err != nil {
+		return nil, err
+	}
+	return paragonNet[T]{nn}, nil
+}
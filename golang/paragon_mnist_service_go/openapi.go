@@ -0,0 +1,46 @@
+package main
+
+import (
+	_ "embed"
+	"html/template"
+	"net/http"
+)
+
+// openapi.json is maintained by hand next to the handlers; update it when a
+// route or request/response shape changes.
+//
+//go:embed openapi.json
+var openapiSpec []byte
+
+// SWAGGER_UI_URL points /docs at another swagger-ui-dist build, e.g. a local
+// mirror for air-gapped hosts.
+var swaggerUIURL = getEnv("SWAGGER_UI_URL", "https://unpkg.com/swagger-ui-dist@5")
+
+var swaggerPage = template.Must(template.New("docs").Parse(`<!doctype html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Paragon MNIST service API</title>
+<link rel="stylesheet" href="{{.}}/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="{{.}}/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+</script>
+</body>
+</html>
+`))
+
+func handleOpenAPI(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(openapiSpec)
+}
+
+func handleDocs(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := swaggerPage.Execute(w, swaggerUIURL); err != nil {
+		warnf("docs page: %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// paritySource selects what /parity compares: PNGs from IMAGES_DIR (the
+// default), or N random samples drawn from the DATASET IDX files.
+type paritySource struct {
+	Images []string // IMAGES_DIR file names; every image when empty
+	N      int      // >0 samples N random IDX items instead of files
+	Split  string   // IDX split: "test" (default) | "train"
+	Seed   *uint64  // sampling seed; random when nil
+}
+
+// paritySample is one row of a parity run.
+type paritySample struct {
+	name  string
+	label *int
+	load  func(in inputShape) ([][]float64, error)
+}
+
+var errParityNotFound = errors.New("not found")
+
+func (s *paritySource) validate() error {
+	if s.N == 0 {
+		if s.Split != "" || s.Seed != nil {
+			return newHTTPError(http.StatusBadRequest, "?split= and ?seed= need ?n=")
+		}
+		return nil
+	}
+	if len(s.Images) > 0 {
+		return newHTTPError(http.StatusBadRequest, "use either ?images= or ?n=, not both")
+	}
+	switch s.Split {
+	case "":
+		s.Split = "test"
+	case "test", "train":
+	default:
+		return newHTTPError(http.StatusBadRequest, "bad ?split= (want test or train)")
+	}
+	if s.Seed == nil {
+		seed := rand.Uint64()
+		s.Seed = &seed
+	}
+	return nil
+}
+
+func (s paritySource) source() string {
+	if s.N > 0 {
+		return s.Split
+	}
+	return "images"
+}
+
+func (s paritySource) samples() ([]paritySample, error) {
+	if err := s.validate(); err != nil {
+		return nil, err
+	}
+	if s.N > 0 {
+		return s.idxSamples()
+	}
+	imgs := s.Images
+	if len(imgs) == 0 {
+		imgs, _ = listImages()
+	}
+	if len(imgs) == 0 {
+		imgs = []string{"0.png", "1.png", "2.png", "3.png", "4.png", "5.png", "6.png", "7.png", "8.png", "9.png"}
+	}
+	imgs = append([]string(nil), imgs...)
+	sort.Strings(imgs)
+
+	out := make([]paritySample, len(imgs))
+	for i, name := range imgs {
+		path, err := imagePath(name)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = paritySample{name: name, load: func(in inputShape) ([][]float64, error) {
+			if exists, _ := fileExists(path); !exists {
+				return nil, errParityNotFound
+			}
+			img, err := loadImageToInput(path, in.W, in.H)
+			if err != nil {
+				return nil, fmt.Errorf("bad image: %w", err)
+			}
+			return img, nil
+		}}
+	}
+	return out, nil
+}
+
+// idxSamples draws N distinct items from the IDX split, named
+// "<split>#<index>" so a row can be replayed through /predict/idx.
+func (s paritySource) idxSamples() ([]paritySample, error) {
+	ensure := ensureTestIDX
+	if s.Split == "train" {
+		ensure = ensureTrainIDX
+	}
+	imgRaw, labRaw, err := ensure()
+	if err != nil {
+		return nil, newHTTPError(http.StatusServiceUnavailable, activeDataset.name+" idx unavailable: "+err.Error())
+	}
+	images, err := cachedIDX(imgRaw, idxMagicImages)
+	if err != nil {
+		return nil, err
+	}
+	labels, err := cachedIDX(labRaw, idxMagicLabels)
+	if err != nil {
+		return nil, err
+	}
+
+	n := min(s.N, images.Len())
+	rng := rand.New(rand.NewPCG(*s.Seed, 0))
+	perm := rng.Perm(images.Len())[:n]
+	sort.Ints(perm)
+
+	out := make([]paritySample, n)
+	for i, idx := range perm {
+		lbl, err := labels.Label(idx)
+		if err != nil {
+			return nil, err
+		}
+		out[i] = paritySample{name: fmt.Sprintf("%s#%05d", s.Split, idx), label: &lbl, load: func(in inputShape) ([][]float64, error) {
+			img, err := images.Image(idx)
+			if err != nil {
+				return nil, err
+			}
+			if len(img) != in.H || len(img[0]) != in.W {
+				img = scaleGrid(img, in.W, in.H)
+			}
+			return img, nil
+		}}
+	}
+	return out, nil
+}
+
+// PARITY_WORKERS runs /parity rows concurrently (default 1: sequentially on
+// the serving handles). Above 1, each run snapshots the model into private
+// pools of that many CPU and GPU copies, so a long run neither serialises on
+// nor starves the handles serving /predict.
+var parityWorkers = max(getEnvInt("PARITY_WORKERS", 1), 1)
+
+// parityHandles builds the private CPU/GPU pools for a concurrent parity run.
+// The GPU pool is skipped when the serving model has no GPU.
+func parityHandles(cpu *ParagonHandle, gpuOK bool, n int) (*ParagonHandle, *ParagonHandle, bool, error) {
+	snap, err := cpu.snapshot()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	gpuN := 0
+	if gpuOK {
+		gpuN = n
+	}
+	return handlesFromSnapshotN(snap, n, gpuN)
+}
+
+// parityFormat renders a finished report for ?format=.
+type parityFormat struct {
+	contentType string
+	write       func(io.Writer, *ParityReport) error
+}
+
+// parityFormats are the ?format= values /parity accepts besides json.
+var parityFormats = map[string]parityFormat{
+	"csv":      {"text/csv; charset=utf-8", writeParityCSV},
+	"markdown": {"text/markdown; charset=utf-8", writeParityMarkdown},
+	"md":       {"text/markdown; charset=utf-8", writeParityMarkdown},
+}
+
+// writeParityCSV writes one line per row; cells a row doesn't have (e.g. GPU
+// columns without a GPU) are left empty.
+func writeParityCSV(w io.Writer, rep *ParityReport) error {
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"image", "label", "cpu_pred", "gpu_pred", "match", "within_tol", "mae", "max_abs_diff", "max_rel_diff", "cpu_latency_sec", "gpu_latency_sec", "error"})
+	for _, row := range rep.Results {
+		rec := []string{row.Image, fmtOptInt(row.Label), "", "", fmtOptBool(row.Match), fmtOptBool(row.WithinTol),
+			fmtOptFloat(row.MAE, 'g', -1), fmtOptFloat(row.MaxAbsDiff, 'g', -1), fmtOptFloat(row.MaxRelDiff, 'g', -1), "", "", row.Error}
+		if row.CPU != nil {
+			rec[2], rec[9] = strconv.Itoa(row.CPU.Pred), strconv.FormatFloat(row.CPU.LatencySec, 'g', -1, 64)
+		}
+		if row.GPU != nil {
+			rec[3], rec[10] = strconv.Itoa(row.GPU.Pred), strconv.FormatFloat(row.GPU.LatencySec, 'g', -1, 64)
+		}
+		_ = cw.Write(rec)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// writeParityMarkdown writes a summary line and a GitHub-flavoured table,
+// ready to paste into an issue.
+func writeParityMarkdown(w io.Writer, rep *ParityReport) error {
+	var b strings.Builder
+	title := "CPU/GPU parity"
+	if rep.Model != "" {
+		title += ": " + rep.Model
+	}
+	fmt.Fprintf(&b, "### %s\n\n", title)
+	src := rep.Source
+	if rep.Seed != nil {
+		src += fmt.Sprintf(" (seed %d)", *rep.Seed)
+	}
+	fmt.Fprintf(&b, "**%d/%d mismatches** · source %s · atol %g · rtol %g", rep.Mismatches, rep.Total, src, rep.Tolerance, rep.RelTolerance)
+	if !rep.GPUAvailable {
+		b.WriteString(" · GPU unavailable")
+	}
+	b.WriteString("\n\n| image | label | cpu | gpu | ok | mae | max abs | max rel | error |\n")
+	b.WriteString("|---|---:|---:|---:|:-:|---:|---:|---:|---|\n")
+	for _, row := range rep.Results {
+		cpu, gpu, ok := "", "", ""
+		if row.CPU != nil {
+			cpu = strconv.Itoa(row.CPU.Pred)
+		}
+		if row.GPU != nil {
+			gpu = strconv.Itoa(row.GPU.Pred)
+		}
+		if row.Match != nil {
+			ok = "✅"
+			if !*row.Match || !*row.WithinTol {
+				ok = "❌"
+			}
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s | %s | %s | %s | %s | %s | %s |\n", mdCell(row.Image), fmtOptInt(row.Label), cpu, gpu, ok,
+			fmtOptFloat(row.MAE, 'e', 2), fmtOptFloat(row.MaxAbsDiff, 'e', 2), fmtOptFloat(row.MaxRelDiff, 'e', 2), mdCell(row.Error))
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// mdCell keeps s on one table row.
+func mdCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ", "\r", "").Replace(s)
+}
+
+func fmtOptInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func fmtOptBool(v *bool) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatBool(*v)
+}
+
+func fmtOptFloat(v *float64, f byte, prec int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, f, prec, 64)
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"net/http"
+	_ "net/http/pprof" // registers /debug/pprof/ on http.DefaultServeMux
+	"strings"
+)
+
+// PPROF_ENABLED=true exposes /debug/pprof/. The import above always
+// registers the handlers, so withPprofGuard hides them unless enabled.
+var pprofOn = getEnvBool("PPROF_ENABLED", false)
+
+func withPprofGuard(next http.Handler) http.Handler {
+	if pprofOn {
+		warnf("🔬 pprof enabled at /debug/pprof/ — do not expose publicly")
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/debug/pprof") {
+			http.NotFound(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Preprocess adjusts a decoded input before Forward. MNIST digits are white
+// ink on black; photos and canvas drawings are usually the opposite, which is
+// the most common reason for wrong predictions in demos. Steps run in field
+// order: invert, deskew, crop or center, threshold, normalize.
+type Preprocess struct {
+	Invert     bool    `json:"invert,omitempty"`      // x → 1-x
+	AutoInvert bool    `json:"auto_invert,omitempty"` // invert when the border is brighter than the middle
+	Deskew     bool    `json:"deskew,omitempty"`      // undo slant with a moment-based shear
+	Crop       bool    `json:"crop,omitempty"`        // crop to the ink bounding box and scale back up
+	Center     bool    `json:"center,omitempty"`      // MNIST-style: fit into 20x20, center by mass (supersedes crop)
+	Threshold  float64 `json:"threshold,omitempty"`   // binarize: x >= t → 1, else 0 (0 = off)
+	Mean       float64 `json:"mean,omitempty"`        // normalize (x-mean)/std when std > 0
+	Std        float64 `json:"std,omitempty"`
+}
+
+// inkLevel separates ink from background when cropping.
+const inkLevel = 0.1
+
+func (p Preprocess) enabled() bool { return p != Preprocess{} }
+
+func (p Preprocess) validate() error {
+	switch {
+	case p.Threshold < 0 || p.Threshold > 1:
+		return newFieldError("preprocess.threshold", "invalid", "threshold must be in [0,1]")
+	case p.Std < 0:
+		return newFieldError("preprocess.std", "invalid", "std must be >= 0")
+	case p.Mean != 0 && p.Std == 0:
+		return newFieldError("preprocess.mean", "invalid", "mean needs std > 0")
+	}
+	return nil
+}
+
+// key distinguishes cached results computed with different preprocessing.
+func (p Preprocess) key() string {
+	if !p.enabled() {
+		return ""
+	}
+	return fmt.Sprintf("pre:%t,%t,%t,%t,%t,%g,%g,%g", p.Invert, p.AutoInvert, p.Deskew, p.Crop, p.Center, p.Threshold, p.Mean, p.Std)
+}
+
+// preprocessFromQuery reads ?invert=, ?auto_invert=, ?deskew=, ?crop=,
+// ?center=, ?threshold=, ?mean= and ?std=.
+func preprocessFromQuery(q url.Values) (Preprocess, error) {
+	var p Preprocess
+	for name, dst := range map[string]*bool{"invert": &p.Invert, "auto_invert": &p.AutoInvert, "deskew": &p.Deskew, "crop": &p.Crop, "center": &p.Center} {
+		if v := strings.TrimSpace(q.Get(name)); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return p, newHTTPError(http.StatusBadRequest, "bad ?"+name+"=")
+			}
+			*dst = b
+		}
+	}
+	for name, dst := range map[string]*float64{"threshold": &p.Threshold, "mean": &p.Mean, "std": &p.Std} {
+		if v := strings.TrimSpace(q.Get(name)); v != "" {
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+				return p, newHTTPError(http.StatusBadRequest, "bad ?"+name+"=")
+			}
+			*dst = f
+		}
+	}
+	return p, p.validate()
+}
+
+// apply returns a preprocessed copy of img; img itself is left untouched
+// since it may be shared through the input cache.
+func (p Preprocess) apply(img [][]float64) [][]float64 {
+	if !p.enabled() || len(img) == 0 || len(img[0]) == 0 {
+		return img
+	}
+	out := make([][]float64, len(img))
+	for y, row := range img {
+		out[y] = append([]float64(nil), row...)
+	}
+	if p.Invert || (p.AutoInvert && brightBorder(out)) {
+		mapGrid(out, func(v float64) float64 { return 1 - v })
+	}
+	if p.Deskew {
+		out = deskew(out)
+	}
+	switch {
+	case p.Center:
+		out = centerByMass(out)
+	case p.Crop:
+		out = cropToInk(out)
+	}
+	if p.Threshold > 0 {
+		mapGrid(out, func(v float64) float64 {
+			if v >= p.Threshold {
+				return 1
+			}
+			return 0
+		})
+	}
+	if p.Std > 0 {
+		mapGrid(out, func(v float64) float64 { return (v - p.Mean) / p.Std })
+	}
+	return out
+}
+
+func mapGrid(img [][]float64, f func(float64) float64) {
+	for _, row := range img {
+		for x, v := range row {
+			row[x] = f(v)
+		}
+	}
+}
+
+// brightBorder reports whether the outer ring of img is on average brighter
+// than the rest, i.e. dark ink on a light background.
+func brightBorder(img [][]float64) bool {
+	h, w := len(img), len(img[0])
+	var border, inner float64
+	var nb, ni int
+	for y, row := range img {
+		for x, v := range row {
+			if y == 0 || x == 0 || y == h-1 || x == w-1 {
+				border += v
+				nb++
+			} else {
+				inner += v
+				ni++
+			}
+		}
+	}
+	if ni == 0 {
+		return border/float64(nb) > 0.5
+	}
+	return border/float64(nb) > inner/float64(ni)
+}
+
+// deskew straightens slanted strokes: the second-order moments give the
+// slant mu11/mu02, and each row is shifted horizontally in proportion to its
+// distance from the centroid to cancel it.
+func deskew(img [][]float64) [][]float64 {
+	var mass, cx, cy float64
+	for y, row := range img {
+		for x, v := range row {
+			mass += v
+			cx += v * float64(x)
+			cy += v * float64(y)
+		}
+	}
+	if mass == 0 {
+		return img
+	}
+	cx, cy = cx/mass, cy/mass
+	var mu11, mu02 float64
+	for y, row := range img {
+		for x, v := range row {
+			dx, dy := float64(x)-cx, float64(y)-cy
+			mu11 += v * dx * dy
+			mu02 += v * dy * dy
+		}
+	}
+	if mu02 < 1e-9 {
+		return img
+	}
+	skew := mu11 / mu02
+	h, w := len(img), len(img[0])
+	out := make([][]float64, h)
+	for y := range out {
+		out[y] = make([]float64, w)
+		shift := skew * (float64(y) - cy)
+		for x := range out[y] {
+			// sample the source where this pixel was before the slant
+			sx := float64(x) + shift
+			x0 := int(math.Floor(sx))
+			t := sx - float64(x0)
+			var a, b float64
+			if x0 >= 0 && x0 < w {
+				a = img[y][x0]
+			}
+			if x0+1 >= 0 && x0+1 < w {
+				b = img[y][x0+1]
+			}
+			out[y][x] = a*(1-t) + b*t
+		}
+	}
+	return out
+}
+
+// inkBounds returns the bounding box [x0,x1)×[y0,y1) of pixels above
+// inkLevel, or ok=false for a blank input.
+func inkBounds(img [][]float64) (x0, y0, x1, y1 int, ok bool) {
+	x0, y0 = len(img[0]), len(img)
+	for y, row := range img {
+		for x, v := range row {
+			if v > inkLevel {
+				x0, y0 = min(x0, x), min(y0, y)
+				x1, y1 = max(x1, x+1), max(y1, y+1)
+				ok = true
+			}
+		}
+	}
+	return
+}
+
+// cropToInk crops to the ink bounding box, pads it to a square so the digit
+// keeps its aspect ratio, and scales it back to the input size.
+func cropToInk(img [][]float64) [][]float64 {
+	h, w := len(img), len(img[0])
+	x0, y0, x1, y1, ok := inkBounds(img)
+	if !ok {
+		return img
+	}
+	side := max(x1-x0, y1-y0)
+	cx, cy := float64(x0+x1)/2, float64(y0+y1)/2
+	square := make([][]float64, side)
+	for y := range square {
+		square[y] = make([]float64, side)
+		sy := int(math.Floor(cy - float64(side)/2 + float64(y)))
+		for x := range square[y] {
+			sx := int(math.Floor(cx - float64(side)/2 + float64(x)))
+			if sy >= 0 && sy < h && sx >= 0 && sx < w {
+				square[y][x] = img[sy][sx]
+			}
+		}
+	}
+	return resizeGrid(square, w, h)
+}
+
+// centerByMass follows the original MNIST preparation: the digit's bounding
+// box is scaled to fit a 20x20 box (keeping its aspect ratio) and placed in
+// the 28x28 frame so its center of mass sits in the middle. Other input sizes
+// keep the same 20/28 proportion.
+func centerByMass(img [][]float64) [][]float64 {
+	h, w := len(img), len(img[0])
+	x0, y0, x1, y1, ok := inkBounds(img)
+	if !ok {
+		return img
+	}
+	crop := make([][]float64, y1-y0)
+	for y := range crop {
+		crop[y] = img[y0+y][x0:x1]
+	}
+	box := max(1, int(math.Round(float64(min(w, h))*20/28)))
+	bw, bh := x1-x0, y1-y0
+	nw, nh := box, box
+	if bw > bh {
+		nh = max(1, int(math.Round(float64(bh)*float64(box)/float64(bw))))
+	} else {
+		nw = max(1, int(math.Round(float64(bw)*float64(box)/float64(bh))))
+	}
+	digit := resizeGrid(crop, nw, nh)
+
+	var mass, mx, my float64
+	for y, row := range digit {
+		for x, v := range row {
+			mass += v
+			mx += v * (float64(x) + 0.5)
+			my += v * (float64(y) + 0.5)
+		}
+	}
+	ox, oy := (w-nw)/2, (h-nh)/2
+	if mass > 0 {
+		// shift so the mass lands on the frame center, without clipping ink
+		ox = min(max(int(math.Round(float64(w)/2-mx/mass)), 0), w-nw)
+		oy = min(max(int(math.Round(float64(h)/2-my/mass)), 0), h-nh)
+	}
+	out := make([][]float64, h)
+	for y := range out {
+		out[y] = make([]float64, w)
+	}
+	for y, row := range digit {
+		copy(out[oy+y][ox:], row)
+	}
+	return out
+}
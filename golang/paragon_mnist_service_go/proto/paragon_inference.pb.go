@@ -0,0 +1,989 @@
+// Paragon inference API served over gRPC next to the HTTP endpoints
+// (GRPC_ADDR). Regenerate the Go stubs after editing:
+//
+//	protoc -I proto --go_out=proto --go_opt=paths=source_relative \
+//	  --go-grpc_out=proto --go-grpc_opt=paths=source_relative paragon_inference.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.11
+// 	protoc        (unknown)
+// source: paragon_inference.proto
+
+package inferencev1
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type PredictRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Image         string                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`     // file name under IMAGES_DIR; or
+	Png           []byte                 `protobuf:"bytes,2,opt,name=png,proto3" json:"png,omitempty"`         // inline PNG, JPEG or BMP bytes
+	Backend       string                 `protobuf:"bytes,3,opt,name=backend,proto3" json:"backend,omitempty"` // "auto" (default: GPU if available, else CPU) | "gpu" | "cpu" | "cpu-int8" | "ensemble"
+	Topk          int32                  `protobuf:"varint,4,opt,name=topk,proto3" json:"topk,omitempty"`      // default 1
+	Model         string                 `protobuf:"bytes,5,opt,name=model,proto3" json:"model,omitempty"`     // registry name, default model if empty
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictRequest) Reset() {
+	*x = PredictRequest{}
+	mi := &file_paragon_inference_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictRequest) ProtoMessage() {}
+
+func (x *PredictRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictRequest.ProtoReflect.Descriptor instead.
+func (*PredictRequest) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *PredictRequest) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetPng() []byte {
+	if x != nil {
+		return x.Png
+	}
+	return nil
+}
+
+func (x *PredictRequest) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *PredictRequest) GetTopk() int32 {
+	if x != nil {
+		return x.Topk
+	}
+	return 0
+}
+
+func (x *PredictRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type ClassProb struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Class         int32                  `protobuf:"varint,1,opt,name=class,proto3" json:"class,omitempty"`
+	Prob          float64                `protobuf:"fixed64,2,opt,name=prob,proto3" json:"prob,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClassProb) Reset() {
+	*x = ClassProb{}
+	mi := &file_paragon_inference_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClassProb) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClassProb) ProtoMessage() {}
+
+func (x *ClassProb) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClassProb.ProtoReflect.Descriptor instead.
+func (*ClassProb) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ClassProb) GetClass() int32 {
+	if x != nil {
+		return x.Class
+	}
+	return 0
+}
+
+func (x *ClassProb) GetProb() float64 {
+	if x != nil {
+		return x.Prob
+	}
+	return 0
+}
+
+type PredictResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Prediction    int32                  `protobuf:"varint,1,opt,name=prediction,proto3" json:"prediction,omitempty"`
+	Probabilities []float64              `protobuf:"fixed64,2,rep,packed,name=probabilities,proto3" json:"probabilities,omitempty"`
+	TopK          []*ClassProb           `protobuf:"bytes,3,rep,name=top_k,json=topK,proto3" json:"top_k,omitempty"`
+	LatencySec    float64                `protobuf:"fixed64,4,opt,name=latency_sec,json=latencySec,proto3" json:"latency_sec,omitempty"`
+	Backend       string                 `protobuf:"bytes,5,opt,name=backend,proto3" json:"backend,omitempty"`
+	Image         string                 `protobuf:"bytes,6,opt,name=image,proto3" json:"image,omitempty"`
+	Error         string                 `protobuf:"bytes,7,opt,name=error,proto3" json:"error,omitempty"` // set on per-item failures in PredictBatch
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictResponse) Reset() {
+	*x = PredictResponse{}
+	mi := &file_paragon_inference_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictResponse) ProtoMessage() {}
+
+func (x *PredictResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictResponse.ProtoReflect.Descriptor instead.
+func (*PredictResponse) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *PredictResponse) GetPrediction() int32 {
+	if x != nil {
+		return x.Prediction
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetProbabilities() []float64 {
+	if x != nil {
+		return x.Probabilities
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetTopK() []*ClassProb {
+	if x != nil {
+		return x.TopK
+	}
+	return nil
+}
+
+func (x *PredictResponse) GetLatencySec() float64 {
+	if x != nil {
+		return x.LatencySec
+	}
+	return 0
+}
+
+func (x *PredictResponse) GetBackend() string {
+	if x != nil {
+		return x.Backend
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *PredictResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+type PredictBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Items         []*PredictRequest      `protobuf:"bytes,1,rep,name=items,proto3" json:"items,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictBatchRequest) Reset() {
+	*x = PredictBatchRequest{}
+	mi := &file_paragon_inference_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictBatchRequest) ProtoMessage() {}
+
+func (x *PredictBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictBatchRequest.ProtoReflect.Descriptor instead.
+func (*PredictBatchRequest) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *PredictBatchRequest) GetItems() []*PredictRequest {
+	if x != nil {
+		return x.Items
+	}
+	return nil
+}
+
+type PredictBatchResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Results       []*PredictResponse     `protobuf:"bytes,1,rep,name=results,proto3" json:"results,omitempty"`
+	Failed        int32                  `protobuf:"varint,2,opt,name=failed,proto3" json:"failed,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *PredictBatchResponse) Reset() {
+	*x = PredictBatchResponse{}
+	mi := &file_paragon_inference_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *PredictBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*PredictBatchResponse) ProtoMessage() {}
+
+func (x *PredictBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use PredictBatchResponse.ProtoReflect.Descriptor instead.
+func (*PredictBatchResponse) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *PredictBatchResponse) GetResults() []*PredictResponse {
+	if x != nil {
+		return x.Results
+	}
+	return nil
+}
+
+func (x *PredictBatchResponse) GetFailed() int32 {
+	if x != nil {
+		return x.Failed
+	}
+	return 0
+}
+
+type ParityRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Images        []string               `protobuf:"bytes,1,rep,name=images,proto3" json:"images,omitempty"` // all images when empty
+	Tol           float64                `protobuf:"fixed64,2,opt,name=tol,proto3" json:"tol,omitempty"`     // absolute, default 1e-4
+	Model         string                 `protobuf:"bytes,3,opt,name=model,proto3" json:"model,omitempty"`
+	Rtol          float64                `protobuf:"fixed64,4,opt,name=rtol,proto3" json:"rtol,omitempty"` // relative to the GPU value, default 0
+	N             int32                  `protobuf:"varint,5,opt,name=n,proto3" json:"n,omitempty"`        // >0 samples n random IDX items instead of images
+	Split         string                 `protobuf:"bytes,6,opt,name=split,proto3" json:"split,omitempty"` // IDX split for n: "test" (default) | "train"
+	Seed          uint64                 `protobuf:"varint,7,opt,name=seed,proto3" json:"seed,omitempty"`  // sampling seed, random when 0
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParityRequest) Reset() {
+	*x = ParityRequest{}
+	mi := &file_paragon_inference_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParityRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParityRequest) ProtoMessage() {}
+
+func (x *ParityRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParityRequest.ProtoReflect.Descriptor instead.
+func (*ParityRequest) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ParityRequest) GetImages() []string {
+	if x != nil {
+		return x.Images
+	}
+	return nil
+}
+
+func (x *ParityRequest) GetTol() float64 {
+	if x != nil {
+		return x.Tol
+	}
+	return 0
+}
+
+func (x *ParityRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ParityRequest) GetRtol() float64 {
+	if x != nil {
+		return x.Rtol
+	}
+	return 0
+}
+
+func (x *ParityRequest) GetN() int32 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+func (x *ParityRequest) GetSplit() string {
+	if x != nil {
+		return x.Split
+	}
+	return ""
+}
+
+func (x *ParityRequest) GetSeed() uint64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+type ParityRow struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Image         string                 `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	CpuPred       int32                  `protobuf:"varint,2,opt,name=cpu_pred,json=cpuPred,proto3" json:"cpu_pred,omitempty"`
+	GpuPred       int32                  `protobuf:"varint,3,opt,name=gpu_pred,json=gpuPred,proto3" json:"gpu_pred,omitempty"`
+	Mae           float64                `protobuf:"fixed64,4,opt,name=mae,proto3" json:"mae,omitempty"`
+	MaxAbsDiff    float64                `protobuf:"fixed64,5,opt,name=max_abs_diff,json=maxAbsDiff,proto3" json:"max_abs_diff,omitempty"`
+	Match         bool                   `protobuf:"varint,6,opt,name=match,proto3" json:"match,omitempty"`
+	WithinTol     bool                   `protobuf:"varint,7,opt,name=within_tol,json=withinTol,proto3" json:"within_tol,omitempty"`
+	Error         string                 `protobuf:"bytes,8,opt,name=error,proto3" json:"error,omitempty"`
+	MaxRelDiff    float64                `protobuf:"fixed64,9,opt,name=max_rel_diff,json=maxRelDiff,proto3" json:"max_rel_diff,omitempty"`
+	Label         *int32                 `protobuf:"varint,10,opt,name=label,proto3,oneof" json:"label,omitempty"` // IDX samples only
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParityRow) Reset() {
+	*x = ParityRow{}
+	mi := &file_paragon_inference_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParityRow) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParityRow) ProtoMessage() {}
+
+func (x *ParityRow) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParityRow.ProtoReflect.Descriptor instead.
+func (*ParityRow) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ParityRow) GetImage() string {
+	if x != nil {
+		return x.Image
+	}
+	return ""
+}
+
+func (x *ParityRow) GetCpuPred() int32 {
+	if x != nil {
+		return x.CpuPred
+	}
+	return 0
+}
+
+func (x *ParityRow) GetGpuPred() int32 {
+	if x != nil {
+		return x.GpuPred
+	}
+	return 0
+}
+
+func (x *ParityRow) GetMae() float64 {
+	if x != nil {
+		return x.Mae
+	}
+	return 0
+}
+
+func (x *ParityRow) GetMaxAbsDiff() float64 {
+	if x != nil {
+		return x.MaxAbsDiff
+	}
+	return 0
+}
+
+func (x *ParityRow) GetMatch() bool {
+	if x != nil {
+		return x.Match
+	}
+	return false
+}
+
+func (x *ParityRow) GetWithinTol() bool {
+	if x != nil {
+		return x.WithinTol
+	}
+	return false
+}
+
+func (x *ParityRow) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ParityRow) GetMaxRelDiff() float64 {
+	if x != nil {
+		return x.MaxRelDiff
+	}
+	return 0
+}
+
+func (x *ParityRow) GetLabel() int32 {
+	if x != nil && x.Label != nil {
+		return *x.Label
+	}
+	return 0
+}
+
+type ParityResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GpuAvailable  bool                   `protobuf:"varint,1,opt,name=gpu_available,json=gpuAvailable,proto3" json:"gpu_available,omitempty"`
+	Tolerance     float64                `protobuf:"fixed64,2,opt,name=tolerance,proto3" json:"tolerance,omitempty"`
+	Mismatches    int32                  `protobuf:"varint,3,opt,name=mismatches,proto3" json:"mismatches,omitempty"`
+	Total         int32                  `protobuf:"varint,4,opt,name=total,proto3" json:"total,omitempty"`
+	Rows          []*ParityRow           `protobuf:"bytes,5,rep,name=rows,proto3" json:"rows,omitempty"`
+	RelTolerance  float64                `protobuf:"fixed64,6,opt,name=rel_tolerance,json=relTolerance,proto3" json:"rel_tolerance,omitempty"`
+	Source        string                 `protobuf:"bytes,7,opt,name=source,proto3" json:"source,omitempty"` // "images" | "test" | "train"
+	Seed          uint64                 `protobuf:"varint,8,opt,name=seed,proto3" json:"seed,omitempty"`    // set for IDX samples
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ParityResponse) Reset() {
+	*x = ParityResponse{}
+	mi := &file_paragon_inference_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ParityResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ParityResponse) ProtoMessage() {}
+
+func (x *ParityResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ParityResponse.ProtoReflect.Descriptor instead.
+func (*ParityResponse) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *ParityResponse) GetGpuAvailable() bool {
+	if x != nil {
+		return x.GpuAvailable
+	}
+	return false
+}
+
+func (x *ParityResponse) GetTolerance() float64 {
+	if x != nil {
+		return x.Tolerance
+	}
+	return 0
+}
+
+func (x *ParityResponse) GetMismatches() int32 {
+	if x != nil {
+		return x.Mismatches
+	}
+	return 0
+}
+
+func (x *ParityResponse) GetTotal() int32 {
+	if x != nil {
+		return x.Total
+	}
+	return 0
+}
+
+func (x *ParityResponse) GetRows() []*ParityRow {
+	if x != nil {
+		return x.Rows
+	}
+	return nil
+}
+
+func (x *ParityResponse) GetRelTolerance() float64 {
+	if x != nil {
+		return x.RelTolerance
+	}
+	return 0
+}
+
+func (x *ParityResponse) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+func (x *ParityResponse) GetSeed() uint64 {
+	if x != nil {
+		return x.Seed
+	}
+	return 0
+}
+
+type ModelInfoRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Model         string                 `protobuf:"bytes,1,opt,name=model,proto3" json:"model,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModelInfoRequest) Reset() {
+	*x = ModelInfoRequest{}
+	mi := &file_paragon_inference_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelInfoRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelInfoRequest) ProtoMessage() {}
+
+func (x *ModelInfoRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelInfoRequest.ProtoReflect.Descriptor instead.
+func (*ModelInfoRequest) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ModelInfoRequest) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+type Layer struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Width         int32                  `protobuf:"varint,1,opt,name=width,proto3" json:"width,omitempty"`
+	Height        int32                  `protobuf:"varint,2,opt,name=height,proto3" json:"height,omitempty"`
+	Activation    string                 `protobuf:"bytes,3,opt,name=activation,proto3" json:"activation,omitempty"`
+	Trainable     bool                   `protobuf:"varint,4,opt,name=trainable,proto3" json:"trainable,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Layer) Reset() {
+	*x = Layer{}
+	mi := &file_paragon_inference_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Layer) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Layer) ProtoMessage() {}
+
+func (x *Layer) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Layer.ProtoReflect.Descriptor instead.
+func (*Layer) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *Layer) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Layer) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *Layer) GetActivation() string {
+	if x != nil {
+		return x.Activation
+	}
+	return ""
+}
+
+func (x *Layer) GetTrainable() bool {
+	if x != nil {
+		return x.Trainable
+	}
+	return false
+}
+
+type ModelInfoResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	NumericType   string                 `protobuf:"bytes,1,opt,name=numeric_type,json=numericType,proto3" json:"numeric_type,omitempty"`
+	Layers        []*Layer               `protobuf:"bytes,2,rep,name=layers,proto3" json:"layers,omitempty"`
+	Params        int64                  `protobuf:"varint,3,opt,name=params,proto3" json:"params,omitempty"`
+	EstVramMb     float64                `protobuf:"fixed64,4,opt,name=est_vram_mb,json=estVramMb,proto3" json:"est_vram_mb,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ModelInfoResponse) Reset() {
+	*x = ModelInfoResponse{}
+	mi := &file_paragon_inference_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ModelInfoResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ModelInfoResponse) ProtoMessage() {}
+
+func (x *ModelInfoResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_paragon_inference_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ModelInfoResponse.ProtoReflect.Descriptor instead.
+func (*ModelInfoResponse) Descriptor() ([]byte, []int) {
+	return file_paragon_inference_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ModelInfoResponse) GetNumericType() string {
+	if x != nil {
+		return x.NumericType
+	}
+	return ""
+}
+
+func (x *ModelInfoResponse) GetLayers() []*Layer {
+	if x != nil {
+		return x.Layers
+	}
+	return nil
+}
+
+func (x *ModelInfoResponse) GetParams() int64 {
+	if x != nil {
+		return x.Params
+	}
+	return 0
+}
+
+func (x *ModelInfoResponse) GetEstVramMb() float64 {
+	if x != nil {
+		return x.EstVramMb
+	}
+	return 0
+}
+
+var File_paragon_inference_proto protoreflect.FileDescriptor
+
+const file_paragon_inference_proto_rawDesc = "" +
+	"\n" +
+	"\x17paragon_inference.proto\x12\x14paragon.inference.v1\"|\n" +
+	"\x0ePredictRequest\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\tR\x05image\x12\x10\n" +
+	"\x03png\x18\x02 \x01(\fR\x03png\x12\x18\n" +
+	"\abackend\x18\x03 \x01(\tR\abackend\x12\x12\n" +
+	"\x04topk\x18\x04 \x01(\x05R\x04topk\x12\x14\n" +
+	"\x05model\x18\x05 \x01(\tR\x05model\"5\n" +
+	"\tClassProb\x12\x14\n" +
+	"\x05class\x18\x01 \x01(\x05R\x05class\x12\x12\n" +
+	"\x04prob\x18\x02 \x01(\x01R\x04prob\"\xf4\x01\n" +
+	"\x0fPredictResponse\x12\x1e\n" +
+	"\n" +
+	"prediction\x18\x01 \x01(\x05R\n" +
+	"prediction\x12$\n" +
+	"\rprobabilities\x18\x02 \x03(\x01R\rprobabilities\x124\n" +
+	"\x05top_k\x18\x03 \x03(\v2\x1f.paragon.inference.v1.ClassProbR\x04topK\x12\x1f\n" +
+	"\vlatency_sec\x18\x04 \x01(\x01R\n" +
+	"latencySec\x12\x18\n" +
+	"\abackend\x18\x05 \x01(\tR\abackend\x12\x14\n" +
+	"\x05image\x18\x06 \x01(\tR\x05image\x12\x14\n" +
+	"\x05error\x18\a \x01(\tR\x05error\"Q\n" +
+	"\x13PredictBatchRequest\x12:\n" +
+	"\x05items\x18\x01 \x03(\v2$.paragon.inference.v1.PredictRequestR\x05items\"o\n" +
+	"\x14PredictBatchResponse\x12?\n" +
+	"\aresults\x18\x01 \x03(\v2%.paragon.inference.v1.PredictResponseR\aresults\x12\x16\n" +
+	"\x06failed\x18\x02 \x01(\x05R\x06failed\"\x9b\x01\n" +
+	"\rParityRequest\x12\x16\n" +
+	"\x06images\x18\x01 \x03(\tR\x06images\x12\x10\n" +
+	"\x03tol\x18\x02 \x01(\x01R\x03tol\x12\x14\n" +
+	"\x05model\x18\x03 \x01(\tR\x05model\x12\x12\n" +
+	"\x04rtol\x18\x04 \x01(\x01R\x04rtol\x12\f\n" +
+	"\x01n\x18\x05 \x01(\x05R\x01n\x12\x14\n" +
+	"\x05split\x18\x06 \x01(\tR\x05split\x12\x12\n" +
+	"\x04seed\x18\a \x01(\x04R\x04seed\"\x9d\x02\n" +
+	"\tParityRow\x12\x14\n" +
+	"\x05image\x18\x01 \x01(\tR\x05image\x12\x19\n" +
+	"\bcpu_pred\x18\x02 \x01(\x05R\acpuPred\x12\x19\n" +
+	"\bgpu_pred\x18\x03 \x01(\x05R\agpuPred\x12\x10\n" +
+	"\x03mae\x18\x04 \x01(\x01R\x03mae\x12 \n" +
+	"\fmax_abs_diff\x18\x05 \x01(\x01R\n" +
+	"maxAbsDiff\x12\x14\n" +
+	"\x05match\x18\x06 \x01(\bR\x05match\x12\x1d\n" +
+	"\n" +
+	"within_tol\x18\a \x01(\bR\twithinTol\x12\x14\n" +
+	"\x05error\x18\b \x01(\tR\x05error\x12 \n" +
+	"\fmax_rel_diff\x18\t \x01(\x01R\n" +
+	"maxRelDiff\x12\x19\n" +
+	"\x05label\x18\n" +
+	" \x01(\x05H\x00R\x05label\x88\x01\x01B\b\n" +
+	"\x06_label\"\x8f\x02\n" +
+	"\x0eParityResponse\x12#\n" +
+	"\rgpu_available\x18\x01 \x01(\bR\fgpuAvailable\x12\x1c\n" +
+	"\ttolerance\x18\x02 \x01(\x01R\ttolerance\x12\x1e\n" +
+	"\n" +
+	"mismatches\x18\x03 \x01(\x05R\n" +
+	"mismatches\x12\x14\n" +
+	"\x05total\x18\x04 \x01(\x05R\x05total\x123\n" +
+	"\x04rows\x18\x05 \x03(\v2\x1f.paragon.inference.v1.ParityRowR\x04rows\x12#\n" +
+	"\rrel_tolerance\x18\x06 \x01(\x01R\frelTolerance\x12\x16\n" +
+	"\x06source\x18\a \x01(\tR\x06source\x12\x12\n" +
+	"\x04seed\x18\b \x01(\x04R\x04seed\"(\n" +
+	"\x10ModelInfoRequest\x12\x14\n" +
+	"\x05model\x18\x01 \x01(\tR\x05model\"s\n" +
+	"\x05Layer\x12\x14\n" +
+	"\x05width\x18\x01 \x01(\x05R\x05width\x12\x16\n" +
+	"\x06height\x18\x02 \x01(\x05R\x06height\x12\x1e\n" +
+	"\n" +
+	"activation\x18\x03 \x01(\tR\n" +
+	"activation\x12\x1c\n" +
+	"\ttrainable\x18\x04 \x01(\bR\ttrainable\"\xa3\x01\n" +
+	"\x11ModelInfoResponse\x12!\n" +
+	"\fnumeric_type\x18\x01 \x01(\tR\vnumericType\x123\n" +
+	"\x06layers\x18\x02 \x03(\v2\x1b.paragon.inference.v1.LayerR\x06layers\x12\x16\n" +
+	"\x06params\x18\x03 \x01(\x03R\x06params\x12\x1e\n" +
+	"\vest_vram_mb\x18\x04 \x01(\x01R\testVramMb2\xfd\x02\n" +
+	"\tInference\x12V\n" +
+	"\aPredict\x12$.paragon.inference.v1.PredictRequest\x1a%.paragon.inference.v1.PredictResponse\x12e\n" +
+	"\fPredictBatch\x12).paragon.inference.v1.PredictBatchRequest\x1a*.paragon.inference.v1.PredictBatchResponse\x12S\n" +
+	"\x06Parity\x12#.paragon.inference.v1.ParityRequest\x1a$.paragon.inference.v1.ParityResponse\x12\\\n" +
+	"\tModelInfo\x12&.paragon.inference.v1.ModelInfoRequest\x1a'.paragon.inference.v1.ModelInfoResponseB,Z*paragon_mnist_service_go/proto;inferencev1b\x06proto3"
+
+var (
+	file_paragon_inference_proto_rawDescOnce sync.Once
+	file_paragon_inference_proto_rawDescData []byte
+)
+
+func file_paragon_inference_proto_rawDescGZIP() []byte {
+	file_paragon_inference_proto_rawDescOnce.Do(func() {
+		file_paragon_inference_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_paragon_inference_proto_rawDesc), len(file_paragon_inference_proto_rawDesc)))
+	})
+	return file_paragon_inference_proto_rawDescData
+}
+
+var file_paragon_inference_proto_msgTypes = make([]protoimpl.MessageInfo, 11)
+var file_paragon_inference_proto_goTypes = []any{
+	(*PredictRequest)(nil),       // 0: paragon.inference.v1.PredictRequest
+	(*ClassProb)(nil),            // 1: paragon.inference.v1.ClassProb
+	(*PredictResponse)(nil),      // 2: paragon.inference.v1.PredictResponse
+	(*PredictBatchRequest)(nil),  // 3: paragon.inference.v1.PredictBatchRequest
+	(*PredictBatchResponse)(nil), // 4: paragon.inference.v1.PredictBatchResponse
+	(*ParityRequest)(nil),        // 5: paragon.inference.v1.ParityRequest
+	(*ParityRow)(nil),            // 6: paragon.inference.v1.ParityRow
+	(*ParityResponse)(nil),       // 7: paragon.inference.v1.ParityResponse
+	(*ModelInfoRequest)(nil),     // 8: paragon.inference.v1.ModelInfoRequest
+	(*Layer)(nil),                // 9: paragon.inference.v1.Layer
+	(*ModelInfoResponse)(nil),    // 10: paragon.inference.v1.ModelInfoResponse
+}
+var file_paragon_inference_proto_depIdxs = []int32{
+	1,  // 0: paragon.inference.v1.PredictResponse.top_k:type_name -> paragon.inference.v1.ClassProb
+	0,  // 1: paragon.inference.v1.PredictBatchRequest.items:type_name -> paragon.inference.v1.PredictRequest
+	2,  // 2: paragon.inference.v1.PredictBatchResponse.results:type_name -> paragon.inference.v1.PredictResponse
+	6,  // 3: paragon.inference.v1.ParityResponse.rows:type_name -> paragon.inference.v1.ParityRow
+	9,  // 4: paragon.inference.v1.ModelInfoResponse.layers:type_name -> paragon.inference.v1.Layer
+	0,  // 5: paragon.inference.v1.Inference.Predict:input_type -> paragon.inference.v1.PredictRequest
+	3,  // 6: paragon.inference.v1.Inference.PredictBatch:input_type -> paragon.inference.v1.PredictBatchRequest
+	5,  // 7: paragon.inference.v1.Inference.Parity:input_type -> paragon.inference.v1.ParityRequest
+	8,  // 8: paragon.inference.v1.Inference.ModelInfo:input_type -> paragon.inference.v1.ModelInfoRequest
+	2,  // 9: paragon.inference.v1.Inference.Predict:output_type -> paragon.inference.v1.PredictResponse
+	4,  // 10: paragon.inference.v1.Inference.PredictBatch:output_type -> paragon.inference.v1.PredictBatchResponse
+	7,  // 11: paragon.inference.v1.Inference.Parity:output_type -> paragon.inference.v1.ParityResponse
+	10, // 12: paragon.inference.v1.Inference.ModelInfo:output_type -> paragon.inference.v1.ModelInfoResponse
+	9,  // [9:13] is the sub-list for method output_type
+	5,  // [5:9] is the sub-list for method input_type
+	5,  // [5:5] is the sub-list for extension type_name
+	5,  // [5:5] is the sub-list for extension extendee
+	0,  // [0:5] is the sub-list for field type_name
+}
+
+func init() { file_paragon_inference_proto_init() }
+func file_paragon_inference_proto_init() {
+	if File_paragon_inference_proto != nil {
+		return
+	}
+	file_paragon_inference_proto_msgTypes[6].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_paragon_inference_proto_rawDesc), len(file_paragon_inference_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   11,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_paragon_inference_proto_goTypes,
+		DependencyIndexes: file_paragon_inference_proto_depIdxs,
+		MessageInfos:      file_paragon_inference_proto_msgTypes,
+	}.Build()
+	File_paragon_inference_proto = out.File
+	file_paragon_inference_proto_goTypes = nil
+	file_paragon_inference_proto_depIdxs = nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/openfluke/paragon/v3"
+)
+
+// QUANTIZE_INT8=true serves an int8-quantized copy of each model as backend
+// "cpu-int8", so accuracy and latency can be compared with the float model
+// through the same API. The copy is built from the CPU handle on first use;
+// QUANTIZE_CALIB_N images from IMAGES_DIR (noise if empty) set its scales.
+var (
+	quantizeInt8 = getEnvBool("QUANTIZE_INT8", false)
+	quantCalibN  = max(getEnvInt("QUANTIZE_CALIB_N", 32), 1)
+)
+
+const backendInt8 = "cpu-int8"
+
+// int8Copy is the lazily built quantized copy of a float CPU handle.
+type int8Copy struct {
+	once sync.Once
+	h    *ParagonHandle
+	err  error
+}
+
+// quantScales maps an int8 copy back to the float model: layer l holds
+// values multiplied by scales[l], chosen so the largest value seen during
+// calibration lands on ±127. The network itself has no requantization step,
+// so weights carry the ratio between a layer's scale and its inputs'.
+type quantScales struct {
+	scales  []float64
+	softmax bool // the float model ends in softmax; applied after dequantizing
+}
+
+func (q *quantScales) input(img [][]float64) [][]float64 {
+	if q == nil {
+		return img
+	}
+	out := make([][]float64, len(img))
+	for y, row := range img {
+		out[y] = make([]float64, len(row))
+		for x, v := range row {
+			out[y][x] = v * q.scales[0]
+		}
+	}
+	return out
+}
+
+// layer dequantizes the values of layer l in place; the output layer is left
+// as logits even when the float model applies softmax.
+func (q *quantScales) layer(vals [][]float64, l int) [][]float64 {
+	if q == nil {
+		return vals
+	}
+	for _, row := range vals {
+		for x := range row {
+			row[x] /= q.scales[l]
+		}
+	}
+	return vals
+}
+
+func (q *quantScales) output(out []float64) []float64 {
+	if q == nil || len(out) == 0 {
+		return out
+	}
+	s := q.scales[len(q.scales)-1]
+	for i := range out {
+		out[i] /= s
+	}
+	if q.softmax {
+		return softmax(out)
+	}
+	return out
+}
+
+// int8 returns the quantized copy of h, building it on first call.
+func (h *ParagonHandle) int8() (*ParagonHandle, error) {
+	if !quantizeInt8 {
+		return nil, newHTTPError(http.StatusForbidden, "cpu-int8 backend disabled (set QUANTIZE_INT8=true)")
+	}
+	h.quant.once.Do(func() {
+		start := time.Now()
+		nn := h.acquire()
+		h.quant.h, h.quant.err = quantizeNetwork(nn, h.in, h.size)
+		h.put(nn)
+		if h.quant.err != nil {
+			warnf("int8 quantization failed: %v", h.quant.err)
+			return
+		}
+		infof("🧮 int8 copy built in %.2fs", time.Since(start).Seconds())
+	})
+	if h.quant.err != nil {
+		return nil, newHTTPError(http.StatusUnprocessableEntity, "int8 quantization failed: "+h.quant.err.Error())
+	}
+	return h.quant.h, nil
+}
+
+// scaleFree activations commute with positive scaling, so a layer using one
+// computes the same thing on scaled values.
+var scaleFree = map[string]bool{"linear": true, "relu": true, "leaky_relu": true}
+
+// quantizeNetwork builds a pool of size int8 copies of a float network.
+func quantizeNetwork(nn network, in inputShape, size int) (*ParagonHandle, error) {
+	dst, q, err := quantizeNet(nn)
+	if err != nil {
+		return nil, err
+	}
+	snap, err := snapshotOf(dst)
+	if err != nil {
+		return nil, err
+	}
+	nets := make([]network, size)
+	for i := range nets {
+		if nets[i], err = networkFromSnapshot(snap); err != nil {
+			return nil, err
+		}
+	}
+	h := newHandle(nets, in)
+	h.q = q
+	return h, nil
+}
+
+// quantizeNet converts a float network to int8 with calibrated scales.
+func quantizeNet(nn network) (network, *quantScales, error) {
+	switch n := nn.(type) {
+	case paragonNet[float32]:
+		return quantize(n.Network)
+	case paragonNet[float64]:
+		return quantize(n.Network)
+	}
+	return nil, nil, fmt.Errorf("model is %s, not a float type", nn.numericType())
+}
+
+// quantize calibrates per-layer scales on sample inputs and copies src into
+// an int8 network with weights and biases rescaled to match.
+func quantize[T float32 | float64](src *paragon.Network[T]) (network, *quantScales, error) {
+	shapes, acts, _ := paragonNet[T]{src}.topology()
+	if len(shapes) < 2 {
+		return nil, nil, fmt.Errorf("model has %d layers", len(shapes))
+	}
+	last := len(acts) - 1
+	for l, a := range acts[1:] {
+		if !scaleFree[a] && !(l+1 == last && a == "softmax") {
+			return nil, nil, fmt.Errorf("layer %d: %s activation can't be served on rescaled int8 values", l+1, a)
+		}
+	}
+
+	// calibration: the largest |pre-activation| each layer sees
+	peak := make([]float64, len(shapes))
+	for _, img := range warmupInputs(shapes[0].Width, shapes[0].Height, warmupOpts{Forwards: quantCalibN, Input: "images"}) {
+		src.Forward(img)
+		for l, L := range src.Layers {
+			for _, row := range L.Neurons {
+				for _, nr := range row {
+					if nr == nil {
+						continue
+					}
+					z := float64(nr.Value)
+					if l > 0 {
+						z = float64(nr.Bias)
+						for _, c := range nr.Inputs {
+							z += float64(c.Weight) * float64(src.Layers[c.SourceLayer].Neurons[c.SourceY][c.SourceX].Value)
+						}
+					}
+					peak[l] = max(peak[l], math.Abs(z))
+				}
+			}
+		}
+	}
+	q := &quantScales{scales: make([]float64, len(shapes)), softmax: acts[last] == "softmax"}
+	for l, p := range peak {
+		q.scales[l] = 1
+		if p > 0 {
+			q.scales[l] = 127 / p
+		}
+	}
+
+	qacts := append([]string(nil), acts...)
+	if q.softmax {
+		qacts[last] = "linear"
+	}
+	clipped := 0
+	dst, err := castNetwork(src, qacts, func(v float64, l, from int) int8 {
+		v *= q.scales[l]
+		if from >= 0 {
+			v /= q.scales[from]
+		}
+		r := math.Round(v)
+		if r > 127 || r < -127 {
+			clipped++
+			r = math.Max(-127, math.Min(127, r))
+		}
+		return int8(r)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	if clipped > 0 {
+		warnf("int8 quantization clipped %d weights/biases to ±127", clipped)
+	}
+	return paragonNet[int8]{dst}, q, nil
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Token-bucket limits for the forward-heavy routes. A rate of 0 disables
+// that limit; burst defaults to max(1, rate).
+//
+//	RATE_LIMIT_RPS / RATE_LIMIT_BURST               per client IP
+//	RATE_LIMIT_GLOBAL_RPS / RATE_LIMIT_GLOBAL_BURST across all clients
+var limiter = newRateLimiter(
+	getEnvFloat("RATE_LIMIT_RPS", 0), getEnvFloat("RATE_LIMIT_BURST", 0),
+	getEnvFloat("RATE_LIMIT_GLOBAL_RPS", 0), getEnvFloat("RATE_LIMIT_GLOBAL_BURST", 0),
+)
+
+const bucketIdle = 10 * time.Minute
+
+type tokenBucket struct {
+	rate, burst float64
+	tokens      float64
+	last        time.Time
+}
+
+func newBucket(rate, burst float64, now time.Time) *tokenBucket {
+	if burst <= 0 {
+		burst = math.Max(1, rate)
+	}
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, last: now}
+}
+
+// take spends one token, or reports how long until one is available.
+func (b *tokenBucket) take(now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+}
+
+type rateLimiter struct {
+	mu          sync.Mutex
+	rate, burst float64
+	global      *tokenBucket
+	clients     map[string]*tokenBucket
+	lastSweep   time.Time
+}
+
+func newRateLimiter(rate, burst, grate, gburst float64) *rateLimiter {
+	now := time.Now()
+	l := &rateLimiter{rate: rate, burst: burst, clients: map[string]*tokenBucket{}, lastSweep: now}
+	if grate > 0 {
+		l.global = newBucket(grate, gburst, now)
+	}
+	return l
+}
+
+func (l *rateLimiter) enabled() bool { return l.rate > 0 || l.global != nil }
+
+// allow checks the client bucket first so a throttled client doesn't also
+// drain the global budget.
+func (l *rateLimiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if l.rate > 0 {
+		if now.Sub(l.lastSweep) > time.Minute {
+			for k, b := range l.clients {
+				if now.Sub(b.last) > bucketIdle {
+					delete(l.clients, k)
+				}
+			}
+			l.lastSweep = now
+		}
+		b := l.clients[client]
+		if b == nil {
+			b = newBucket(l.rate, l.burst, now)
+			l.clients[client] = b
+		}
+		if ok, wait := b.take(now); !ok {
+			return false, wait
+		}
+	}
+	if l.global != nil {
+		return l.global.take(now)
+	}
+	return true, 0
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// retryAfter rounds wait up to whole seconds, at least one.
+func retryAfter(wait time.Duration) int {
+	return max(int(math.Ceil(wait.Seconds())), 1)
+}
+
+// rateLimited rejects requests over the limit with 429 and Retry-After.
+func rateLimited(next http.HandlerFunc) http.HandlerFunc {
+	if !limiter.enabled() {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := limiter.allow(clientIP(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter(wait)))
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// READY_REQUIRE_GPU=true keeps /readyz at 503 while the GPU is unavailable,
+// for deployments that would rather not serve than serve on the CPU.
+var readyRequireGPU = getEnvBool("READY_REQUIRE_GPU", false)
+
+// startup tracks the work main does before the API is registered (dataset
+// download, model load, GPU pipeline compilation) so probes can report it.
+var startup struct {
+	done  atomic.Bool
+	mu    sync.Mutex
+	stage string
+	since time.Time
+}
+
+func setStartupStage(stage string) {
+	startup.mu.Lock()
+	startup.stage, startup.since = stage, time.Now()
+	startup.mu.Unlock()
+	debugf("startup: %s", stage)
+}
+
+func markStarted() {
+	setStartupStage("done")
+	startup.done.Store(true)
+}
+
+// probePaths answer while the service is still starting.
+var probePaths = map[string]bool{"/livez": true, "/readyz": true, "/health": true}
+
+// withStartupGate turns away everything but the probes with 503 until main
+// has finished starting up; the API routes aren't registered before then.
+func withStartupGate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !startup.done.Load() && !probePaths[r.URL.Path] {
+			w.Header().Set("Retry-After", "5")
+			http.Error(w, "service is starting up", http.StatusServiceUnavailable)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleReadyz reports whether traffic should be routed here: startup has
+// finished, a model is loaded, IMAGES_DIR has images and, with
+// READY_REQUIRE_GPU, the GPU is up. It answers 503 with the failing checks
+// otherwise.
+func handleReadyz(w http.ResponseWriter, _ *http.Request) {
+	startup.mu.Lock()
+	stage, since := startup.stage, startup.since
+	startup.mu.Unlock()
+
+	checks := map[string]bool{"startup": startup.done.Load()}
+	if checks["startup"] {
+		cpu, _, _ := currentHandles()
+		imgs, _ := listImages()
+		checks["model"] = cpu != nil
+		checks["images"] = len(imgs) > 0
+		if readyRequireGPU {
+			checks["gpu"] = gpuAvailable()
+		}
+	}
+	ready := true
+	for _, ok := range checks {
+		ready = ready && ok
+	}
+	res := map[string]any{"ready": ready, "checks": checks, "gpu_available": gpuAvailable()}
+	if !checks["startup"] {
+		res["stage"] = stage
+		res["stage_sec"] = round6(time.Since(since).Seconds())
+	}
+	code := http.StatusOK
+	if !ready {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, res)
+}
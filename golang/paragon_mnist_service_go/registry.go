@@ -0,0 +1,365 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MODELS_DIR holds extra model JSON files served by name (?model=<file stem>)
+// next to the default MODEL_JSON pair.
+var modelsDir = getEnv("MODELS_DIR", "")
+
+type registeredModel struct {
+	Name  string `json:"name"`
+	Path  string `json:"path,omitempty"` // empty for uploads without MODELS_DIR
+	GPUOK bool   `json:"gpu_available"`
+	cpu   *ParagonHandle
+	gpu   *ParagonHandle
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = map[string]*registeredModel{}
+)
+
+// loadRegistry loads every *.json in dir; a bad file is logged and skipped
+// so one broken variant doesn't keep the service down.
+func loadRegistry(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(strings.ToLower(e.Name()), ".json") {
+			continue
+		}
+		name := strings.TrimSuffix(e.Name(), filepath.Ext(e.Name()))
+		path := filepath.Join(dir, e.Name())
+		m, err := loadRegisteredModel(name, path)
+		if err != nil {
+			warnf("skipping model %s: %v", path, err)
+			continue
+		}
+		ok := m.GPUOK
+		registerModel(m)
+		infof("📦 registered model %q from %s (gpu=%v)", name, path, ok)
+	}
+	return nil
+}
+
+// loadConfiguredModels registers the models listed in MODELS (the models
+// list of CONFIG_FILE).
+func loadConfiguredModels() error {
+	models, err := configuredModels()
+	if err != nil {
+		return err
+	}
+	for name, path := range models {
+		m, err := loadRegisteredModel(name, path)
+		if err != nil {
+			warnf("skipping model %s: %v", path, err)
+			continue
+		}
+		ok := m.GPUOK
+		registerModel(m)
+		infof("📦 registered model %q from %s (gpu=%v)", name, path, ok)
+	}
+	return nil
+}
+
+func loadRegisteredModel(name, path string) (*registeredModel, error) {
+	snap, err := loadModelSnapshot(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateTopology(snap.shapes, snap.acts); err != nil {
+		return nil, err
+	}
+	cpu, gpu, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		return nil, err
+	}
+	return &registeredModel{Name: name, Path: path, GPUOK: ok, cpu: cpu, gpu: gpu}, nil
+}
+
+// registerModel adds or replaces m, releasing the GPU of any model it replaces.
+func registerModel(m *registeredModel) {
+	registryMu.Lock()
+	old := registry[m.Name]
+	registry[m.Name] = m
+	registryMu.Unlock()
+	if old != nil {
+		old.gpu.release()
+	}
+}
+
+// registerNewModel adds m unless a model of that name is registered.
+func registerNewModel(m *registeredModel) bool {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if _, ok := registry[m.Name]; ok {
+		return false
+	}
+	registry[m.Name] = m
+	return true
+}
+
+// registeredModels copies the registry, so callers can read and encode the
+// entries while setGPUOK updates the originals.
+func registeredModels() []registeredModel {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	out := make([]registeredModel, 0, len(registry))
+	for _, m := range registry {
+		out = append(out, *m)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// modelHandles resolves ?model=; "" and "default" mean the MODEL_JSON pair.
+func modelHandles(name string) (*ParagonHandle, *ParagonHandle, bool, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || name == "default" {
+		cpu, gpu, ok := currentHandles()
+		return cpu, gpu, ok, nil
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	m := registry[name]
+	if m == nil {
+		return nil, nil, false, newHTTPError(http.StatusNotFound, "unknown model: "+name)
+	}
+	return m.cpu, m.gpu, m.GPUOK, nil
+}
+
+// cpuTwin returns the CPU handle serving the same model as gpu, and the
+// model's name, or nil when gpu isn't a served GPU handle.
+func cpuTwin(gpu *ParagonHandle) (*ParagonHandle, string) {
+	if cpu, g, _ := currentHandles(); g == gpu {
+		return cpu, "default"
+	}
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	for name, m := range registry {
+		if m.gpu == gpu {
+			return m.cpu, name
+		}
+	}
+	return nil, ""
+}
+
+// pickModelHandle is pickHandle for a named model.
+func pickModelHandle(name, backend string) (*ParagonHandle, error) {
+	_, h, err := pickBackend(name, backend)
+	return h, err
+}
+
+// defaultBackend is used when a request names none: the GPU when the model
+// has a working one, the CPU otherwise.
+const defaultBackend = "auto"
+
+// pickBackend resolves a backend name for a model to the backend that will
+// actually run ("auto" or empty becomes "gpu" or "cpu") and its handle.
+// "cpu-int8" is the quantized copy (QUANTIZE_INT8); anything else but "gpu"
+// and "auto" runs on the CPU, as it always has.
+func pickBackend(name, backend string) (string, *ParagonHandle, error) {
+	cpu, gpu, ok, err := modelHandles(name)
+	if err != nil {
+		return "", nil, err
+	}
+	switch backend = strings.ToLower(strings.TrimSpace(backend)); backend {
+	case "", defaultBackend:
+		if ok && gpu != nil {
+			return "gpu", gpu, nil
+		}
+		return "cpu", cpu, nil
+	case "gpu":
+		if !ok || gpu == nil {
+			return "", nil, newStageError(stageForward, http.StatusServiceUnavailable, "GPU backend not available")
+		}
+		return "gpu", gpu, nil
+	case backendInt8:
+		h, err := cpu.int8()
+		if err != nil {
+			return "", nil, err
+		}
+		return backendInt8, h, nil
+	}
+	return backend, cpu, nil
+}
+
+// gpuFailed reports whether err is a GPU forward failure that an "auto"
+// request should retry on the CPU; timeouts and cancellations are not.
+func gpuFailed(err error) bool {
+	var he *httpError
+	return errors.As(err, &he) && he.stage == stageForward && he.code == http.StatusInternalServerError
+}
+
+// MODEL_UPLOAD_MAX_MB bounds POST /models bodies (default 64).
+var modelUploadMax = int64(getEnvInt("MODEL_UPLOAD_MAX_MB", 64)) << 20
+
+var modelNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]{0,63}$`)
+
+// modelFilePath resolves a client-supplied model path: empty or MODEL_JSON
+// itself means MODEL_JSON, anything else is taken as a file name under
+// MODELS_DIR. Directories in p are dropped so a request can't reach outside.
+func modelFilePath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "" || filepath.Clean(p) == filepath.Clean(modelJSON) {
+		return modelJSON, nil
+	}
+	if modelsDir == "" {
+		return "", newFieldError("path", "invalid", "path must be MODEL_JSON unless MODELS_DIR is set")
+	}
+	name := filepath.Base(p)
+	if strings.Contains(p, "..") || !modelNameRe.MatchString(name) {
+		return "", newFieldError("path", "invalid", "path must be a file name under MODELS_DIR matching "+modelNameRe.String())
+	}
+	return filepath.Join(modelsDir, name), nil
+}
+
+// ModelValidationError is the JSON body of a rejected model upload.
+type ModelValidationError struct {
+	Reason string `json:"reason"` // "name" | "json" | "numeric_type" | "load" | "topology" | "init"
+	Error  string `json:"error"`
+}
+
+func handleModels(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]any{"models": registeredModels()})
+	case http.MethodPost:
+		adminOnly(handleModelUpload)(w, r)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleModelUpload registers a Paragon model JSON sent as the body or as
+// multipart field "file", under ?name= (or form field "name"). With
+// MODELS_DIR set the file is kept there so it survives restarts. It needs an
+// admin key, see requireAdmin.
+func handleModelUpload(w http.ResponseWriter, r *http.Request) {
+	reject := func(code int, reason, msg string) {
+		writeJSON(w, code, ModelValidationError{Reason: reason, Error: msg})
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, modelUploadMax)
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			reject(httpStatus(uploadError(err)), "json", uploadError(err).Error())
+			return
+		}
+		f, _, err := r.FormFile("file")
+		if err != nil {
+			reject(http.StatusBadRequest, "json", "missing multipart field \"file\"")
+			return
+		}
+		defer f.Close()
+		body = f
+	}
+	name := strings.TrimSpace(r.URL.Query().Get("name"))
+	if name == "" {
+		name = strings.TrimSpace(r.FormValue("name"))
+	}
+	if !modelNameRe.MatchString(name) || name == "default" {
+		reject(http.StatusBadRequest, "name", "?name= must match "+modelNameRe.String()+" and not be \"default\"")
+		return
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		reject(httpStatus(uploadError(err)), "json", uploadError(err).Error())
+		return
+	}
+	if !json.Valid(data) {
+		reject(http.StatusBadRequest, "json", "body is not valid JSON")
+		return
+	}
+
+	// LoadNamedNetworkFromJSONFile only reads files, so stage the upload
+	dir := modelsDir
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*.json")
+	if err != nil {
+		http.Error(w, "stage upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath) // no-op once renamed
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		http.Error(w, "stage upload: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	snap, err := loadModelSnapshot(tmpPath)
+	if err != nil {
+		reason := "load"
+		if errors.Is(err, errNumericType) {
+			reason = "numeric_type"
+		}
+		reject(http.StatusUnprocessableEntity, reason, err.Error())
+		return
+	}
+	if err := validateTopology(snap.shapes, snap.acts); err != nil {
+		reject(http.StatusUnprocessableEntity, "topology", err.Error())
+		return
+	}
+	cpu, gpu, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		reject(http.StatusUnprocessableEntity, "init", err.Error())
+		return
+	}
+
+	path := ""
+	if modelsDir != "" {
+		path = filepath.Join(modelsDir, name+".json")
+		if err := os.Rename(tmpPath, path); err != nil {
+			gpu.release()
+			http.Error(w, "save model: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	m := &registeredModel{Name: name, Path: path, GPUOK: ok, cpu: cpu, gpu: gpu}
+	out := *m // m belongs to the registry once registered
+	registerModel(m)
+	infof("📦 registered uploaded model %q (gpu=%v)", name, ok)
+	writeJSON(w, http.StatusCreated, out)
+}
+
+// handleModelExport streams a model as Paragon JSON; "default" is the
+// MODEL_JSON model as currently served. The CPU handle is exported since it
+// is the one updated in-process.
+func handleModelExport(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	cpu, _, _, err := modelHandles(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	data, err := cpu.Export()
+	if err != nil {
+		http.Error(w, "export failed: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`.json"`)
+	_, _ = w.Write(data)
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+)
+
+// TestRegistryGPUOKRace flips a registered model's GPU flag while it is
+// resolved and listed; run with -race.
+func TestRegistryGPUOKRace(t *testing.T) {
+	cpu := useTestModel(t)
+	gpu := &ParagonHandle{}
+	registerModel(&registeredModel{Name: "race", cpu: cpu, gpu: gpu})
+	t.Cleanup(func() {
+		registryMu.Lock()
+		delete(registry, "race")
+		registryMu.Unlock()
+	})
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for i := range 2000 {
+			setGPUOK("race", gpu, i%2 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 2000 {
+			if _, _, _, err := modelHandles("race"); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 2000 {
+			if _, err := json.Marshal(registeredModels()); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+	wg.Wait()
+
+	setGPUOK("race", gpu, true)
+	if _, _, ok, _ := modelHandles("race"); !ok {
+		t.Fatal("gpu_available not updated")
+	}
+}
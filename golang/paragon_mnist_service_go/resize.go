@@ -0,0 +1,130 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// RESIZE_METHOD picks how inputs that aren't already the model's size are
+// scaled: "area" (default) averages every source pixel a target pixel covers,
+// which keeps thin strokes from large canvas exports (e.g. 280x280) intact;
+// "bilinear" interpolates between the nearest four; "nearest" picks one
+// source pixel and aliases badly when shrinking.
+var resizeMethod = parseResizeMethod(getEnv("RESIZE_METHOD", "area"))
+
+func parseResizeMethod(s string) string {
+	switch m := strings.ToLower(strings.TrimSpace(s)); m {
+	case "area", "bilinear", "nearest":
+		return m
+	default:
+		warnf("unknown RESIZE_METHOD %q, using area", s)
+		return "area"
+	}
+}
+
+// scaleGrid resizes src to w×h with RESIZE_METHOD. Area averaging only
+// applies when shrinking; enlarging falls back to bilinear.
+func scaleGrid(src [][]float64, w, h int) [][]float64 {
+	sh, sw := len(src), len(src[0])
+	if sw == w && sh == h {
+		return src
+	}
+	switch {
+	case resizeMethod == "nearest":
+		return resizeNearest(src, w, h)
+	case resizeMethod == "area" && sw >= w && sh >= h:
+		return resizeArea(src, w, h)
+	}
+	return resizeGrid(src, w, h)
+}
+
+func resizeNearest(src [][]float64, w, h int) [][]float64 {
+	sh, sw := len(src), len(src[0])
+	out := make([][]float64, h)
+	for y := range out {
+		out[y] = make([]float64, w)
+		for x := range out[y] {
+			out[y][x] = src[y*sh/h][x*sw/w]
+		}
+	}
+	return out
+}
+
+// resizeArea averages the source area under each target pixel, weighting
+// partially covered source pixels by their overlap.
+func resizeArea(src [][]float64, w, h int) [][]float64 {
+	sh, sw := len(src), len(src[0])
+	xw := areaWeights(sw, w)
+	yw := areaWeights(sh, h)
+	// rows first, then columns
+	tmp := make([][]float64, sh)
+	for y, row := range src {
+		tmp[y] = make([]float64, w)
+		for x, ws := range xw {
+			var sum float64
+			for _, c := range ws {
+				sum += row[c.i] * c.w
+			}
+			tmp[y][x] = sum
+		}
+	}
+	out := make([][]float64, h)
+	for y, ws := range yw {
+		out[y] = make([]float64, w)
+		for x := range out[y] {
+			var sum float64
+			for _, c := range ws {
+				sum += tmp[c.i][x] * c.w
+			}
+			out[y][x] = sum
+		}
+	}
+	return out
+}
+
+type areaWeight struct {
+	i int
+	w float64
+}
+
+// areaWeights lists, for each of the n target cells, the source cells it
+// covers and their normalized overlap.
+func areaWeights(src, n int) [][]areaWeight {
+	scale := float64(src) / float64(n)
+	out := make([][]areaWeight, n)
+	for t := range out {
+		lo, hi := float64(t)*scale, float64(t+1)*scale
+		for i := int(math.Floor(lo)); i < src && float64(i) < hi; i++ {
+			cover := math.Min(hi, float64(i+1)) - math.Max(lo, float64(i))
+			if cover > 0 {
+				out[t] = append(out[t], areaWeight{i, cover / scale})
+			}
+		}
+	}
+	return out
+}
+
+// resizeGrid scales a grid to w×h with bilinear interpolation.
+func resizeGrid(src [][]float64, w, h int) [][]float64 {
+	sh, sw := len(src), len(src[0])
+	out := make([][]float64, h)
+	for y := range out {
+		out[y] = make([]float64, w)
+		fy := (float64(y)+0.5)*float64(sh)/float64(h) - 0.5
+		y0 := int(math.Floor(fy))
+		ty := fy - float64(y0)
+		for x := range out[y] {
+			fx := (float64(x)+0.5)*float64(sw)/float64(w) - 0.5
+			x0 := int(math.Floor(fx))
+			tx := fx - float64(x0)
+			at := func(yy, xx int) float64 {
+				yy, xx = min(max(yy, 0), sh-1), min(max(xx, 0), sw-1)
+				return src[yy][xx]
+			}
+			top := at(y0, x0)*(1-tx) + at(y0, x0+1)*tx
+			bot := at(y0+1, x0)*(1-tx) + at(y0+1, x0+1)*tx
+			out[y][x] = top*(1-ty) + bot*ty
+		}
+	}
+	return out
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// apiPrefix is the current API version. Routes registered through a router
+// live under it; probes, static files and "/" stay unversioned.
+const apiPrefix = "/v1"
+
+// router registers handlers under a version prefix. A future breaking change
+// gets its own router (e.g. "/v2") with new handlers for the routes that
+// changed, while the v1 ones keep serving old clients.
+type router struct {
+	mux    *http.ServeMux
+	prefix string
+	legacy bool // also serve each route unprefixed, as before versioning
+}
+
+func newRouter(mux *http.ServeMux, prefix string, legacy bool) *router {
+	return &router{mux: mux, prefix: prefix, legacy: legacy}
+}
+
+// HandleFunc takes a ServeMux pattern, with or without a method
+// ("GET /models/{name}/export").
+func (rt *router) HandleFunc(pattern string, h http.HandlerFunc) {
+	method, path, ok := strings.Cut(pattern, " ")
+	if !ok {
+		method, path = "", pattern
+	} else {
+		method += " "
+	}
+	rt.mux.HandleFunc(method+rt.prefix+path, h)
+	if rt.legacy {
+		rt.mux.HandleFunc(pattern, h)
+	}
+}
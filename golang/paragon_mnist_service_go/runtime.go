@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuntimeStats is returned by GET /admin/runtime, for tracking down memory
+// growth without attaching pprof.
+type RuntimeStats struct {
+	GoVersion  string         `json:"go_version"`
+	Uptime     float64        `json:"uptime_sec"`
+	Goroutines int            `json:"goroutines"`
+	GOMAXPROCS int            `json:"gomaxprocs"`
+	Memory     MemoryStats    `json:"memory"`
+	GC         GCStats        `json:"gc"`
+	Handles    HandleStats    `json:"handles"`
+	IDX        []IDXStats     `json:"idx"`
+	Caches     map[string]any `json:"caches"`
+}
+
+type MemoryStats struct {
+	HeapAllocMB    float64 `json:"heap_alloc_mb"`
+	HeapInuseMB    float64 `json:"heap_inuse_mb"`
+	HeapIdleMB     float64 `json:"heap_idle_mb"`
+	HeapReleasedMB float64 `json:"heap_released_mb"`
+	HeapObjects    uint64  `json:"heap_objects"`
+	StackInuseMB   float64 `json:"stack_inuse_mb"`
+	SysMB          float64 `json:"sys_mb"` // everything obtained from the OS
+	TotalAllocMB   float64 `json:"total_alloc_mb"`
+}
+
+type GCStats struct {
+	Count        uint32    `json:"count"`
+	Forced       uint32    `json:"forced"`
+	NextMB       float64   `json:"next_mb"` // heap size that triggers the next cycle
+	PauseTotalMS float64   `json:"pause_total_ms"`
+	RecentMS     []float64 `json:"recent_pauses_ms"` // newest first, up to 16
+	CPUFraction  float64   `json:"cpu_fraction"`
+	LastGC       string    `json:"last_gc,omitempty"`
+}
+
+// HandleStats counts model handles not yet garbage collected. Handles swapped
+// out by reloads and training should drop away after a GC; a count that only
+// grows means something still holds them.
+type HandleStats struct {
+	Live     int64 `json:"live"`
+	Networks int64 `json:"networks"` // pooled network copies across live handles
+}
+
+func mb(b uint64) float64 { return round6(float64(b) / (1 << 20)) }
+
+// handleRuntime reports Go runtime and service memory stats. ?gc=true runs a
+// collection first, so the numbers show what is actually still reachable.
+func handleRuntime(w http.ResponseWriter, r *http.Request) {
+	if gc, _ := strconv.ParseBool(strings.TrimSpace(r.URL.Query().Get("gc"))); gc {
+		runtime.GC()
+	}
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+
+	s := RuntimeStats{
+		GoVersion:  runtime.Version(),
+		Uptime:     round6(time.Since(startedAt).Seconds()),
+		Goroutines: runtime.NumGoroutine(),
+		GOMAXPROCS: runtime.GOMAXPROCS(0),
+		Memory: MemoryStats{
+			HeapAllocMB:    mb(m.HeapAlloc),
+			HeapInuseMB:    mb(m.HeapInuse),
+			HeapIdleMB:     mb(m.HeapIdle),
+			HeapReleasedMB: mb(m.HeapReleased),
+			HeapObjects:    m.HeapObjects,
+			StackInuseMB:   mb(m.StackInuse),
+			SysMB:          mb(m.Sys),
+			TotalAllocMB:   mb(m.TotalAlloc),
+		},
+		GC: GCStats{
+			Count:        m.NumGC,
+			Forced:       m.NumForcedGC,
+			NextMB:       mb(m.NextGC),
+			PauseTotalMS: round6(float64(m.PauseTotalNs) / 1e6),
+			RecentMS:     []float64{},
+			CPUFraction:  round6(m.GCCPUFraction),
+		},
+		Handles: HandleStats{Live: liveHandles.Load(), Networks: liveNetworks.Load()},
+		IDX:     idxStats(),
+		Caches:  map[string]any{"input": inputCache.stats(), "result": resultCache.stats()},
+	}
+	// PauseNs is a ring; cycle n (1-based) is at (n-1)%256
+	for i := uint32(0); i < min(m.NumGC, 16); i++ {
+		s.GC.RecentMS = append(s.GC.RecentMS, round6(float64(m.PauseNs[(m.NumGC-1-i)%256])/1e6))
+	}
+	if m.LastGC > 0 {
+		s.GC.LastGC = time.Unix(0, int64(m.LastGC)).UTC().Format(time.RFC3339Nano)
+	}
+	writeJSON(w, http.StatusOK, s)
+}
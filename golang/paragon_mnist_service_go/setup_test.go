@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+// useTestModel serves a small untrained CPU-only MNIST-shaped model as the
+// default model for the duration of the test.
+func useTestModel(t *testing.T) *ParagonHandle {
+	t.Helper()
+	shapes := []struct{ Width, Height int }{{defaultInput.W, defaultInput.H}, {32, 1}, {10, 1}}
+	_, snap, err := newModelSnapshot("float32", shapes, []string{"linear", "relu", "softmax"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cpu, _, _, err := handlesFromSnapshotN(snap, 2, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCPU, oldGPU, oldOK := currentHandles()
+	modelMu.Lock()
+	hCPU, hGPU, gpuOK = cpu, nil, false
+	modelMu.Unlock()
+	t.Cleanup(func() {
+		modelMu.Lock()
+		hCPU, hGPU, gpuOK = oldCPU, oldGPU, oldOK
+		modelMu.Unlock()
+	})
+	return cpu
+}
+
+// testDigit is a 28x28 grayscale PNG with a bar at column x.
+func testDigit(t *testing.T, x int) []byte {
+	t.Helper()
+	img := image.NewGray(image.Rect(0, 0, 28, 28))
+	for y := 4; y < 24; y++ {
+		img.SetGray(x, y, color.Gray{Y: 255})
+		img.SetGray(x+1, y, color.Gray{Y: 255})
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// useTestLimiter replaces the rate limiter for the duration of the test.
+func useTestLimiter(t *testing.T, rate, burst float64) {
+	t.Helper()
+	old := limiter
+	limiter = newRateLimiter(rate, burst, 0, 0)
+	t.Cleanup(func() { limiter = old })
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SHADOW_MODE=true re-runs GPU predictions on the model's CPU handle in the
+// background and counts where they disagree: a different argmax, or a max
+// |cpu-gpu| over the probabilities above SHADOW_TOL (default 1e-3). Responses
+// are never held up or changed. SHADOW_SAMPLE (0..1, default 1) is the share
+// of GPU forwards shadowed, and at most SHADOW_MAX_INFLIGHT (default 4) run at
+// once; the rest are skipped, since shadows take CPU copies from requests.
+var (
+	shadowOn          = getEnvBool("SHADOW_MODE", false)
+	shadowSample      = min(max(getEnvFloat("SHADOW_SAMPLE", 1), 0), 1)
+	shadowTol         = getEnvFloat("SHADOW_TOL", 1e-3)
+	shadowMaxInflight = max(getEnvInt("SHADOW_MAX_INFLIGHT", 4), 1)
+)
+
+// shadowSlots bounds the shadow forwards in flight.
+var shadowSlots = make(chan struct{}, shadowMaxInflight)
+
+var shadow struct {
+	checks, argmax, tol, skipped, errors atomic.Int64
+
+	mu      sync.Mutex // guards maxDiff and last
+	maxDiff float64
+	last    *ShadowDivergence
+}
+
+// ShadowDivergence is the most recent GPU result that disagreed with the CPU.
+type ShadowDivergence struct {
+	At        string  `json:"at"`
+	Model     string  `json:"model"`
+	RequestID string  `json:"request_id,omitempty"`
+	GPUPred   int     `json:"gpu_pred"`
+	CPUPred   int     `json:"cpu_pred"`
+	MaxDiff   float64 `json:"max_diff"`
+}
+
+// ShadowStats is exposed under /metrics.
+type ShadowStats struct {
+	Enabled   bool              `json:"enabled"`
+	Sample    float64           `json:"sample"`
+	Tol       float64           `json:"tol"`
+	Checks    int64             `json:"checks"`
+	Argmax    int64             `json:"argmax_divergences"`
+	Tolerance int64             `json:"tol_divergences"` // same argmax, probabilities beyond SHADOW_TOL
+	Skipped   int64             `json:"skipped"`         // SHADOW_MAX_INFLIGHT reached
+	Errors    int64             `json:"errors"`          // the CPU forward failed
+	MaxDiff   float64           `json:"max_diff"`        // largest max |cpu-gpu| seen
+	Last      *ShadowDivergence `json:"last_divergence,omitempty"`
+}
+
+func shadowStats() ShadowStats {
+	shadow.mu.Lock()
+	defer shadow.mu.Unlock()
+	return ShadowStats{
+		Enabled:   shadowOn,
+		Sample:    shadowSample,
+		Tol:       shadowTol,
+		Checks:    shadow.checks.Load(),
+		Argmax:    shadow.argmax.Load(),
+		Tolerance: shadow.tol.Load(),
+		Skipped:   shadow.skipped.Load(),
+		Errors:    shadow.errors.Load(),
+		MaxDiff:   round6(shadow.maxDiff),
+		Last:      shadow.last,
+	}
+}
+
+// shadowGPU checks out, the result of a forward of img on gpu, against the
+// CPU handle of the same model in the background. Handles that aren't a
+// served model's GPU handle are ignored.
+func shadowGPU(ctx context.Context, gpu *ParagonHandle, img [][]float64, out *ProbResult) {
+	if !shadowOn || (shadowSample < 1 && rand.Float64() >= shadowSample) {
+		return
+	}
+	cpu, model := cpuTwin(gpu)
+	if cpu == nil {
+		return
+	}
+	select {
+	case shadowSlots <- struct{}{}:
+	default:
+		shadow.skipped.Add(1)
+		return
+	}
+	pred, probs, reqID := out.Pred, slices.Clone(out.Probs), requestID(ctx)
+	go func() {
+		defer func() { <-shadowSlots }()
+		want, err := forwardProbs(cpu, img)
+		if err != nil {
+			shadow.errors.Add(1)
+			debugf("shadow forward failed for model %q: %v", model, err)
+			return
+		}
+		shadow.checks.Add(1)
+		_, maxd, _ := diffStats(want.Probs, probs)
+		shadow.mu.Lock()
+		shadow.maxDiff = max(shadow.maxDiff, maxd)
+		shadow.mu.Unlock()
+		switch {
+		case want.Pred != pred:
+			shadow.argmax.Add(1)
+		case maxd > shadowTol:
+			shadow.tol.Add(1)
+		default:
+			return
+		}
+		d := &ShadowDivergence{
+			At:        time.Now().UTC().Format(time.RFC3339),
+			Model:     model,
+			RequestID: reqID,
+			GPUPred:   pred,
+			CPUPred:   want.Pred,
+			MaxDiff:   round6(maxd),
+		}
+		shadow.mu.Lock()
+		shadow.last = d
+		shadow.mu.Unlock()
+		warnf("⚠️  shadow: GPU diverges from CPU on model %q (pred %d vs %d, max |diff| %.3g, request %s)", model, pred, want.Pred, maxd, reqID)
+	}()
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Long-running endpoints stream their progress as server-sent events when
+// the client asks for Accept: text/event-stream, instead of blocking until
+// the whole report is ready.
+
+func wantsSSE(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+type sseWriter struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func startSSE(w http.ResponseWriter) *sseWriter {
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	h.Set("X-Accel-Buffering", "no") // keep nginx from holding events back
+	w.WriteHeader(http.StatusOK)
+	s := &sseWriter{w: w, rc: http.NewResponseController(w)}
+	_ = s.rc.Flush()
+	return s
+}
+
+// send writes one event with v as its JSON data line and flushes it.
+func (s *sseWriter) send(event string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, b); err != nil {
+		return err
+	}
+	return s.rc.Flush()
+}
+
+// streamParity emits a "row" event per image, then "done" with the totals
+// (results omitted, the rows were already sent) or "error".
+func streamParity(w http.ResponseWriter, r *http.Request, model string, src paritySource, tol parityTol) {
+	s := startSSE(w)
+	rep, err := runParity(r.Context(), model, src, tol, func(i, total int, row ParityRow) {
+		_ = s.send("row", map[string]any{"index": i, "total": total, "row": row})
+	})
+	if err != nil {
+		_ = s.send("error", map[string]any{"error": err.Error(), "status": httpStatus(err)})
+		return
+	}
+	rep.Results = nil
+	_ = s.send("done", rep)
+}
+
+const trainPollInterval = 250 * time.Millisecond
+
+// streamTrainJob sends a "progress" event whenever the job advances and
+// finishes with "done", "failed" or "canceled".
+func streamTrainJob(w http.ResponseWriter, r *http.Request, job *TrainJob) {
+	s := startSSE(w)
+	t := time.NewTicker(trainPollInterval)
+	defer t.Stop()
+	last := -1
+	for {
+		trainMu.Lock()
+		cp := *job
+		trainMu.Unlock()
+		switch cp.Status {
+		case jobDone, jobFailed, jobCanceled:
+			_ = s.send(cp.Status, cp)
+			return
+		}
+		if step := cp.Epoch*(cp.Batches+1) + cp.Batch; step != last {
+			last = step
+			if err := s.send("progress", cp); err != nil {
+				return
+			}
+		}
+		select {
+		case <-t.C:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
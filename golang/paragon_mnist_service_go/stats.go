@@ -0,0 +1,146 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// STATS_WINDOW is how many recent prediction latencies /stats keeps per
+// backend for its percentiles (default 1024).
+var statsWindow = max(getEnvInt("STATS_WINDOW", 1024), 1)
+
+var startedAt = time.Now()
+
+// latencyRing holds the last len(buf) latencies, in milliseconds.
+type latencyRing struct {
+	buf  []float64
+	next int
+	full bool
+}
+
+func (r *latencyRing) add(ms float64) {
+	if r.buf == nil {
+		r.buf = make([]float64, statsWindow)
+	}
+	r.buf[r.next] = ms
+	if r.next++; r.next == len(r.buf) {
+		r.next, r.full = 0, true
+	}
+}
+
+// sorted returns a sorted copy of the window.
+func (r *latencyRing) sorted() []float64 {
+	n := r.next
+	if r.full {
+		n = len(r.buf)
+	}
+	out := append([]float64(nil), r.buf[:n]...)
+	sort.Float64s(out)
+	return out
+}
+
+type backendStats struct {
+	requests, errors int64
+	ring             latencyRing
+}
+
+// reqStats counts every request but the probes; latencies are only kept for
+// prediction routes, keyed by the backend that ran them.
+var reqStats = struct {
+	mu                             sync.Mutex
+	requests, errors, clientErrors int64
+	backends                       map[string]*backendStats
+}{backends: map[string]*backendStats{}}
+
+// recordRequest is called by withRequestLog once a request has finished.
+func recordRequest(path, backend string, status int, d time.Duration) {
+	if probePaths[path] {
+		return
+	}
+	reqStats.mu.Lock()
+	defer reqStats.mu.Unlock()
+	reqStats.requests++
+	switch {
+	case status >= 500:
+		reqStats.errors++
+	case status >= 400:
+		reqStats.clientErrors++
+	}
+	if backend = strings.ToLower(backend); backend == "" || !isPredictPath(path) {
+		return
+	}
+	b := reqStats.backends[backend]
+	if b == nil {
+		b = &backendStats{}
+		reqStats.backends[backend] = b
+	}
+	b.requests++
+	if status >= 400 {
+		b.errors++
+		return
+	}
+	b.ring.add(float64(d.Microseconds()) / 1000)
+}
+
+// isPredictPath matches the one-shot prediction routes, versioned or not;
+// the long-lived /ws stream is left out.
+func isPredictPath(path string) bool {
+	return strings.HasPrefix(strings.TrimPrefix(path, apiPrefix), "/predict")
+}
+
+// percentile is the nearest-rank percentile of sorted values.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(math.Ceil(p/100*float64(len(sorted)))) - 1
+	return sorted[min(max(i, 0), len(sorted)-1)]
+}
+
+// handleStats reports rolling latency percentiles per backend over the last
+// STATS_WINDOW predictions, request and error counts, cache hit rates and
+// uptime.
+func handleStats(w http.ResponseWriter, _ *http.Request) {
+	reqStats.mu.Lock()
+	backends := map[string]any{}
+	for name, b := range reqStats.backends {
+		lat := b.ring.sorted()
+		mean := 0.0
+		for _, v := range lat {
+			mean += v
+		}
+		if len(lat) > 0 {
+			mean /= float64(len(lat))
+		}
+		backends[name] = map[string]any{
+			"requests": b.requests,
+			"errors":   b.errors,
+			"samples":  len(lat),
+			"mean_ms":  round6(mean),
+			"p50_ms":   round6(percentile(lat, 50)),
+			"p95_ms":   round6(percentile(lat, 95)),
+			"p99_ms":   round6(percentile(lat, 99)),
+			"max_ms":   round6(percentile(lat, 100)),
+		}
+	}
+	res := map[string]any{
+		"uptime_sec":    round6(time.Since(startedAt).Seconds()),
+		"started_at":    startedAt.UTC().Format(time.RFC3339),
+		"requests":      reqStats.requests,
+		"errors":        reqStats.errors,
+		"client_errors": reqStats.clientErrors,
+		"window":        statsWindow,
+		"backends":      backends,
+	}
+	reqStats.mu.Unlock()
+
+	res["cache_hit_rate"] = map[string]any{
+		"input":  inputCache.stats()["hit_rate"],
+		"result": resultCache.stats()["hit_rate"],
+	}
+	writeJSON(w, http.StatusOK, res)
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+type TensorPredictRequest struct {
+	Tensor   json.RawMessage `json:"tensor"` // HxW rows or a flat W*H array, values in [0,1]
+	Backend  string          `json:"backend"`
+	RawProbs bool            `json:"raw_probs"`
+	TopK     int             `json:"topk"`
+
+	Preprocess Preprocess `json:"preprocess"`
+}
+
+// parseTensor accepts either nested rows or a flat row-major array and
+// returns the HxW grid the model expects.
+func parseTensor(raw json.RawMessage, in inputShape) ([][]float64, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, newStageError(stageDecode, http.StatusBadRequest, "missing tensor")
+	}
+	var rows [][]float64
+	if err := json.Unmarshal(raw, &rows); err == nil {
+		return rows, nil
+	}
+	var flat []float64
+	if err := json.Unmarshal(raw, &flat); err != nil {
+		return nil, newStageError(stageDecode, http.StatusBadRequest, "tensor must be a number array or array of rows")
+	}
+	if len(flat) != in.W*in.H {
+		return nil, newStageError(stageDecode, http.StatusBadRequest, fmt.Sprintf("flat tensor has %d values, model expects %d (%dx%d)", len(flat), in.W*in.H, in.W, in.H))
+	}
+	rows = make([][]float64, in.H)
+	for y := range rows {
+		rows[y] = flat[y*in.W : (y+1)*in.W]
+	}
+	return rows, nil
+}
+
+func handlePredictTensor(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	var req TensorPredictRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, err)
+		return
+	}
+	opts, err := predictOpts{RawProbs: req.RawProbs, TopK: req.TopK, Pre: req.Preprocess}.normalize()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	cpu, _, _ := currentHandles()
+	img, err := parseTensor(req.Tensor, cpu.Input())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res, err := predictTensor(img, "tensor", req.Backend, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
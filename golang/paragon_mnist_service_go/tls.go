@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// HTTPS is served when CERT_FILE and KEY_FILE are set, or with a throwaway
+// self-signed certificate when TLS_SELF_SIGNED=true (dev only). net/http
+// negotiates HTTP/2 on TLS listeners automatically.
+var (
+	certFile   = getEnv("CERT_FILE", "")
+	keyFile    = getEnv("KEY_FILE", "")
+	selfSigned = getEnvBool("TLS_SELF_SIGNED", false)
+)
+
+func scheme() string {
+	if (certFile != "" && keyFile != "") || selfSigned {
+		return "https"
+	}
+	return "http"
+}
+
+func serve(addr string, h http.Handler) error {
+	srv := &http.Server{Addr: addr, Handler: h}
+	switch {
+	case certFile != "" && keyFile != "":
+		infof("🔒 TLS with %s", certFile)
+		return srv.ListenAndServeTLS(certFile, keyFile)
+	case selfSigned:
+		cert, err := selfSignedCert()
+		if err != nil {
+			return err
+		}
+		warnf("🔓 TLS with a self-signed certificate — clients must skip verification")
+		srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		return srv.ListenAndServeTLS("", "")
+	}
+	return srv.ListenAndServe()
+}
+
+func selfSignedCert() (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 62))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "paragon_mnist_service_go (dev)"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
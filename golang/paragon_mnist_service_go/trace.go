@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Minimal OpenTelemetry tracing: spans are exported as OTLP/HTTP JSON, so no
+// SDK dependency is needed. Enabled when OTEL_EXPORTER_OTLP_TRACES_ENDPOINT
+// (full URL) or OTEL_EXPORTER_OTLP_ENDPOINT (base URL, /v1/traces appended)
+// is set; OTEL_EXPORTER_OTLP_HEADERS and OTEL_SERVICE_NAME are honored.
+var tracer = newTracer()
+
+type spanAttr struct {
+	Key   string         `json:"key"`
+	Value map[string]any `json:"value"`
+}
+
+type span struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         int        `json:"kind"` // 1 internal, 2 server
+	StartNano    string     `json:"startTimeUnixNano"`
+	EndNano      string     `json:"endTimeUnixNano"`
+	Attributes   []spanAttr `json:"attributes,omitempty"`
+	Status       struct {
+		Code    int    `json:"code,omitempty"` // 2 = error
+		Message string `json:"message,omitempty"`
+	} `json:"status"`
+
+	start time.Time
+}
+
+type spanKey struct{}
+
+type otlpTracer struct {
+	endpoint string
+	headers  map[string]string
+	service  string
+
+	mu      sync.Mutex
+	pending []*span
+}
+
+func newTracer() *otlpTracer {
+	ep := getEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "")
+	if ep == "" {
+		if base := getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""); base != "" {
+			ep = strings.TrimRight(base, "/") + "/v1/traces"
+		}
+	}
+	if ep == "" {
+		return nil
+	}
+	t := &otlpTracer{endpoint: ep, headers: map[string]string{}, service: getEnv("OTEL_SERVICE_NAME", "paragon_mnist_service_go")}
+	for _, kv := range strings.Split(getEnv("OTEL_EXPORTER_OTLP_HEADERS", ""), ",") {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			t.headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	go t.loop()
+	return t
+}
+
+func randHex(n int) string {
+	b := make([]byte, n)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// startSpan opens a child of the span in ctx, or a new trace. With tracing
+// off it returns ctx and a nil span, whose methods are no-ops.
+func startSpan(ctx context.Context, name string) (context.Context, *span) {
+	if tracer == nil {
+		return ctx, nil
+	}
+	s := &span{Name: name, Kind: 1, SpanID: randHex(8), start: time.Now()}
+	if p, ok := ctx.Value(spanKey{}).(*span); ok && p != nil {
+		s.TraceID, s.ParentSpanID = p.TraceID, p.SpanID
+	} else {
+		s.TraceID = randHex(16)
+	}
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// startServerSpan is startSpan for an incoming request, continuing a W3C
+// traceparent header when present.
+func startServerSpan(r *http.Request, name string) (context.Context, *span) {
+	ctx, s := startSpan(r.Context(), name)
+	if s == nil {
+		return ctx, nil
+	}
+	s.Kind = 2
+	// traceparent: 00-<32 hex trace id>-<16 hex parent id>-<flags>
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 && len(parts[1]) == 32 && len(parts[2]) == 16 {
+		s.TraceID, s.ParentSpanID = parts[1], parts[2]
+	}
+	s.SetAttr("http.method", r.Method)
+	s.SetAttr("http.target", r.URL.Path)
+	if id := requestID(r.Context()); id != "" {
+		s.SetAttr("request.id", id)
+	}
+	return ctx, s
+}
+
+func (s *span) SetAttr(key string, v any) {
+	if s == nil {
+		return
+	}
+	var val map[string]any
+	switch x := v.(type) {
+	case string:
+		val = map[string]any{"stringValue": x}
+	case bool:
+		val = map[string]any{"boolValue": x}
+	case int:
+		val = map[string]any{"intValue": strconv.Itoa(x)}
+	case float64:
+		val = map[string]any{"doubleValue": x}
+	default:
+		return
+	}
+	s.Attributes = append(s.Attributes, spanAttr{key, val})
+}
+
+func (s *span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Status.Code, s.Status.Message = 2, err.Error()
+}
+
+func (s *span) End() {
+	if s == nil {
+		return
+	}
+	s.StartNano = strconv.FormatInt(s.start.UnixNano(), 10)
+	s.EndNano = strconv.FormatInt(time.Now().UnixNano(), 10)
+	tracer.mu.Lock()
+	tracer.pending = append(tracer.pending, s)
+	full := len(tracer.pending) >= 512
+	tracer.mu.Unlock()
+	if full {
+		go tracer.flush()
+	}
+}
+
+func (t *otlpTracer) loop() {
+	for range time.Tick(5 * time.Second) {
+		t.flush()
+	}
+}
+
+func (t *otlpTracer) flush() {
+	t.mu.Lock()
+	batch := t.pending
+	t.pending = nil
+	t.mu.Unlock()
+	if len(batch) == 0 {
+		return
+	}
+	body, err := json.Marshal(map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{"attributes": []spanAttr{{"service.name", map[string]any{"stringValue": t.service}}}},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": "paragon_mnist_service_go"},
+				"spans": batch,
+			}},
+		}},
+	})
+	if err != nil {
+		warnf("otlp encode: %v", err)
+		return
+	}
+	req, err := http.NewRequest(http.MethodPost, t.endpoint, bytes.NewReader(body))
+	if err != nil {
+		warnf("otlp export: %v", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		warnf("otlp export: %v (%d spans dropped)", err, len(batch))
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		warnf("otlp export: %s (%d spans dropped)", resp.Status, len(batch))
+	}
+}
+
+// statusRecorder captures the response code for logging and tracing.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(code int) {
+	r.status = code
+	r.ResponseWriter.WriteHeader(code)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (Hijack, Flush).
+func (r *statusRecorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
+
+// traced wraps a handler in a server span named after the route.
+func traced(name string, next http.HandlerFunc) http.HandlerFunc {
+	if tracer == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx, s := startServerSpan(r, name)
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(ctx))
+		s.SetAttr("http.status_code", rec.status)
+		if rec.status >= 500 {
+			s.Status.Code = 2
+		}
+		s.End()
+	}
+}
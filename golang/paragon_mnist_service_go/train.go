@@ -0,0 +1,542 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// TrainRequest holds the hyperparameters of a training job. Fields left out
+// of the body keep the values from GET /train/defaults, or with resume those
+// of the checkpoint's job; unknown fields are rejected.
+type TrainRequest struct {
+	Epochs       int     `json:"epochs"`
+	LearningRate float64 `json:"learning_rate"`  // rate of the first epoch
+	LRSchedule   string  `json:"lr_schedule"`    // "constant" | "step" | "cosine"
+	LRStep       int     `json:"lr_step"`        // step: epochs between decays
+	LRDecay      float64 `json:"lr_decay"`       // step: factor applied every lr_step epochs
+	LRMin        float64 `json:"lr_min"`         // cosine: rate of the last epoch
+	BatchSize    int     `json:"batch_size"`     // samples per progress update
+	Shuffle      bool    `json:"shuffle"`        // reorder samples every epoch
+	Seed         *uint64 `json:"seed,omitempty"` // shuffle and augment seed, random when unset
+	Augment      Augment `json:"augment"`        // applied to training samples, not validation
+	Clip         float64 `json:"clip"`           // gradients are clipped to ±clip
+	Limit        int     `json:"limit"`          // first N training images, 0 = all
+	ValSplit     float64 `json:"val_split"`      // fraction of those held out for validation
+	Patience     int     `json:"patience"`       // stop after this many epochs without improvement, 0 = never
+	MinDelta     float64 `json:"min_delta"`      // val_loss drop that counts as an improvement
+	RestoreBest  bool    `json:"restore_best"`   // serve the best epoch's weights rather than the last
+	Resume       string  `json:"resume,omitempty"`
+	CallbackURL  string  `json:"callback_url,omitempty"` // POSTed the job when it ends, see WEBHOOK_SECRET
+}
+
+var trainDefaults = TrainRequest{
+	Epochs:       1,
+	LearningRate: 0.01,
+	LRSchedule:   "constant",
+	LRStep:       1,
+	LRDecay:      0.5,
+	BatchSize:    64,
+	Clip:         5,
+	ValSplit:     0.1,
+	RestoreBest:  true,
+	Augment:      Augment{Prob: 1, ElasticSigma: 4},
+}
+
+const maxTrainEpochs = 100
+
+var lrSchedules = []string{"constant", "step", "cosine"}
+
+type TrainJob struct {
+	ID             string       `json:"id"`
+	Status         string       `json:"status"` // "running" | "done" | "failed"
+	Epoch          int          `json:"epoch"`
+	Epochs         int          `json:"epochs"`
+	Batch          int          `json:"batch"`
+	Batches        int          `json:"batches"` // per epoch
+	Samples        int          `json:"samples"`
+	LearningRate   float64      `json:"learning_rate"` // of the current epoch
+	BatchSize      int          `json:"batch_size"`
+	Loss           float64      `json:"loss"` // mean loss of the last completed batch
+	Params         TrainRequest `json:"params"`
+	ValSamples     int          `json:"val_samples,omitempty"`
+	ValLoss        float64      `json:"val_loss,omitempty"` // after the last completed epoch
+	ValAccuracy    float64      `json:"val_accuracy,omitempty"`
+	BestEpoch      int          `json:"best_epoch,omitempty"` // lowest val_loss so far
+	BestValLoss    float64      `json:"best_val_loss,omitempty"`
+	BestCheckpoint string       `json:"best_checkpoint,omitempty"`
+	StoppedEarly   bool         `json:"stopped_early,omitempty"`
+	ResumedFrom    string       `json:"resumed_from,omitempty"`
+	Checkpoint     string       `json:"checkpoint,omitempty"` // latest saved, see CHECKPOINT_DIR
+	Error          string       `json:"error,omitempty"`
+	StartedAt      time.Time    `json:"started_at"`
+	FinishedAt     *time.Time   `json:"finished_at,omitempty"`
+}
+
+var (
+	trainMu   sync.Mutex // guards trainBusy and every TrainJob
+	trainBusy bool
+)
+
+// decodeTrainRequest overlays a JSON body on base.
+func decodeTrainRequest(body []byte, base TrainRequest) (TrainRequest, error) {
+	req := base
+	if len(bytes.TrimSpace(body)) == 0 {
+		return req, nil
+	}
+	err := decodeStrict(bytes.NewReader(body), &req)
+	return req, err
+}
+
+func (req *TrainRequest) normalize() error {
+	req.LRSchedule = strings.ToLower(strings.TrimSpace(req.LRSchedule))
+	switch {
+	case req.Epochs < 1 || req.Epochs > maxTrainEpochs:
+		return newFieldError("epochs", "invalid", fmt.Sprintf("epochs must be in [1,%d]", maxTrainEpochs))
+	case !(req.LearningRate > 0) || req.LearningRate > 10:
+		return newFieldError("learning_rate", "invalid", "learning_rate must be in (0,10]")
+	case !slices.Contains(lrSchedules, req.LRSchedule):
+		return newFieldError("lr_schedule", "invalid", fmt.Sprintf("lr_schedule must be one of %s", strings.Join(lrSchedules, ", ")))
+	case req.LRStep < 1:
+		return newFieldError("lr_step", "invalid", "lr_step must be >= 1")
+	case !(req.LRDecay > 0) || req.LRDecay > 1:
+		return newFieldError("lr_decay", "invalid", "lr_decay must be in (0,1]")
+	case req.LRMin < 0 || req.LRMin > req.LearningRate:
+		return newFieldError("lr_min", "invalid", "lr_min must be in [0,learning_rate]")
+	case req.BatchSize < 1:
+		return newFieldError("batch_size", "invalid", "batch_size must be >= 1")
+	case !(req.Clip > 0) || math.IsInf(req.Clip, 0):
+		return newFieldError("clip", "invalid", "clip must be > 0")
+	case req.Limit < 0:
+		return newFieldError("limit", "invalid", "limit must be >= 0")
+	case !(req.ValSplit >= 0) || req.ValSplit > 0.5:
+		return newFieldError("val_split", "invalid", "val_split must be in [0,0.5]")
+	case req.Patience < 0 || req.Patience > maxTrainEpochs:
+		return newFieldError("patience", "invalid", fmt.Sprintf("patience must be in [0,%d]", maxTrainEpochs))
+	case req.Patience > 0 && req.ValSplit == 0:
+		return newFieldError("patience", "invalid", "patience needs a val_split")
+	case !(req.MinDelta >= 0):
+		return newFieldError("min_delta", "invalid", "min_delta must be >= 0")
+	}
+	if req.CallbackURL = strings.TrimSpace(req.CallbackURL); req.CallbackURL != "" {
+		if err := checkCallbackURL(req.CallbackURL); err != nil {
+			return err
+		}
+	}
+	if err := req.Augment.validate(); err != nil {
+		return err
+	}
+	if (req.Shuffle || req.Augment.enabled()) && req.Seed == nil {
+		seed := rand.Uint64()
+		req.Seed = &seed
+	}
+	return nil
+}
+
+// lrAt is the learning rate of epoch (1-based) under the schedule.
+func (req TrainRequest) lrAt(epoch int) float64 {
+	switch req.LRSchedule {
+	case "step":
+		return req.LearningRate * math.Pow(req.LRDecay, float64((epoch-1)/req.LRStep))
+	case "cosine":
+		if req.Epochs == 1 {
+			return req.LearningRate
+		}
+		t := float64(epoch-1) / float64(req.Epochs-1)
+		return req.LRMin + (req.LearningRate-req.LRMin)*(1+math.Cos(math.Pi*t))/2
+	}
+	return req.LearningRate
+}
+
+// order is the sample order of epoch: 0..n-1, or with shuffle a permutation
+// that depends only on the seed and epoch, so a resumed job sees the same.
+func (req TrainRequest) order(epoch, n int) []int {
+	if !req.Shuffle || req.Seed == nil {
+		order := make([]int, n)
+		for i := range order {
+			order[i] = i
+		}
+		return order
+	}
+	return rand.New(rand.NewPCG(*req.Seed, uint64(epoch))).Perm(n)
+}
+
+// augmentRand is the augmentation source of epoch, a stream separate from
+// the shuffle's so turning one on doesn't change the other.
+func (req TrainRequest) augmentRand(epoch int) *rand.Rand {
+	if !req.Augment.enabled() || req.Seed == nil {
+		return nil
+	}
+	return rand.New(rand.NewPCG(*req.Seed, uint64(epoch)|1<<63))
+}
+
+// handleTrainDefaults describes the hyperparameters POST /train takes.
+func handleTrainDefaults(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{
+		"defaults":     trainDefaults,
+		"lr_schedules": lrSchedules,
+		"max_epochs":   maxTrainEpochs,
+	})
+}
+
+// handleTrain starts training a copy of the served model on the MNIST IDX
+// training set; the copy is swapped in when it finishes. One job runs at a time.
+// With "resume" it continues from a checkpoint's weights after its epoch.
+func handleTrain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !trainingOn {
+		http.Error(w, "training disabled (set TRAINING_ENABLED=true)", http.StatusForbidden)
+		return
+	}
+	body, err := io.ReadAll(r.Body) // bounded by withBodyLimits
+	if err != nil {
+		writeError(w, jsonError(err))
+		return
+	}
+	req, err := decodeTrainRequest(body, trainDefaults)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	var ck *TrainCheckpoint
+	var snap *modelSnapshot
+	if id := strings.TrimSpace(req.Resume); id != "" {
+		if ck, snap, err = loadCheckpoint(id); err != nil {
+			writeError(w, err)
+			return
+		}
+		base := ck.Params
+		base.CallbackURL = "" // the old job's, not this one's
+		if req, err = decodeTrainRequest(body, base); err != nil {
+			writeError(w, err)
+			return
+		}
+		req.Resume = id
+	}
+	if err := req.normalize(); err != nil {
+		writeError(w, err)
+		return
+	}
+	if ck != nil && req.Epochs <= ck.Epoch {
+		http.Error(w, fmt.Sprintf("checkpoint %s is already at epoch %d; ask for more epochs", ck.ID, ck.Epoch), http.StatusBadRequest)
+		return
+	}
+
+	imgRaw, labRaw, err := ensureTrainIDX()
+	if err != nil {
+		http.Error(w, "mnist idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	images, err := cachedIDX(imgRaw, idxMagicImages)
+	if err != nil {
+		http.Error(w, "mnist idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	labels, err := cachedIDX(labRaw, idxMagicLabels)
+	if err != nil {
+		http.Error(w, "mnist idx unavailable: "+err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	cpu, _, _ := currentHandles()
+	if snap == nil {
+		if snap, err = cpu.snapshot(); err != nil {
+			http.Error(w, "snapshot failed: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	first := snap.shapes[0]
+	in, err := inputShapeFor(first)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("model input layer %dx%d can't take %dx%d IDX images: %v", first.Width, first.Height, images.cols, images.rows, err), http.StatusBadRequest)
+		return
+	}
+	if in.W != images.cols || in.H != images.rows {
+		http.Error(w, fmt.Sprintf("model expects %dx%d input; IDX images are %dx%d", in.W, in.H, images.cols, images.rows), http.StatusBadRequest)
+		return
+	}
+	if out := snap.shapes[len(snap.shapes)-1]; out.Width*out.Height != numClasses {
+		http.Error(w, fmt.Sprintf("output layer has %d units, want %d", out.Width*out.Height, numClasses), http.StatusBadRequest)
+		return
+	}
+
+	n := min(images.Len(), labels.Len())
+	if req.Limit > 0 {
+		n = min(n, req.Limit)
+	}
+	nVal := int(float64(n) * req.ValSplit)
+	if req.ValSplit > 0 && (nVal < 1 || nVal == n) {
+		http.Error(w, fmt.Sprintf("val_split %g of %d samples leaves nothing to train or validate on", req.ValSplit, n), http.StatusBadRequest)
+		return
+	}
+	n -= nVal
+	trainMu.Lock()
+	if trainBusy {
+		trainMu.Unlock()
+		http.Error(w, "a training job is already running", http.StatusConflict)
+		return
+	}
+	job := &TrainJob{
+		Status:       jobRunning,
+		Epochs:       req.Epochs,
+		Batches:      (n + req.BatchSize - 1) / req.BatchSize,
+		Samples:      n,
+		ValSamples:   nVal,
+		LearningRate: req.LearningRate,
+		BatchSize:    req.BatchSize,
+		Params:       req,
+		StartedAt:    time.Now().UTC(),
+	}
+	if ck != nil {
+		job.Epoch, job.Loss, job.ResumedFrom = ck.Epoch, ck.Loss, ck.ID
+	}
+	j, ctx := newJob("train", req.CallbackURL, job, func() (any, float64) {
+		trainMu.Lock()
+		defer trainMu.Unlock()
+		done := float64(job.Epoch)
+		if job.Batch > 0 && job.Batches > 0 {
+			done = float64(job.Epoch-1) + float64(job.Batch)/float64(job.Batches)
+		}
+		return *job, done / float64(job.Epochs)
+	})
+	job.ID = j.ID
+	trainBusy = true
+	trainMu.Unlock()
+
+	infof("🏋️  train job %s: %d samples, %d epochs, lr=%g (%s), batch=%d, clip=%g", job.ID, n, req.Epochs, req.LearningRate, req.LRSchedule, req.BatchSize, req.Clip)
+	if ck != nil {
+		infof("🏋️  train job %s resumes from checkpoint %s (epoch %d)", job.ID, ck.ID, ck.Epoch)
+	}
+	j.start(ctx, func(ctx context.Context) (any, error) {
+		return runTrainJob(ctx, job, cpu, snap, images, labels, n, nVal)
+	})
+	writeJSON(w, http.StatusAccepted, map[string]any{"id": job.ID, "status_url": apiPrefix + "/train/status/" + job.ID, "job_url": apiPrefix + "/jobs/" + job.ID})
+}
+
+// runTrainJob trains and serves the result, returning the finished job.
+func runTrainJob(ctx context.Context, job *TrainJob, base *ParagonHandle, snap *modelSnapshot, images, labels *idxFile, nTrain, nVal int) (TrainJob, error) {
+	err := trainSnapshot(ctx, job, snap, images, labels, nTrain, nVal)
+	if err == nil {
+		err = swapTrained(base, snap)
+	}
+	trainMu.Lock()
+	defer trainMu.Unlock()
+	now := time.Now().UTC()
+	job.FinishedAt = &now
+	trainBusy = false
+	switch {
+	case err != nil && ctx.Err() != nil:
+		job.Status = jobCanceled
+		infof("🛑 train job %s canceled at epoch %d", job.ID, job.Epoch)
+	case err != nil:
+		job.Status, job.Error = jobFailed, err.Error()
+		errorf("train job %s failed: %v", job.ID, err)
+	default:
+		job.Status = jobDone
+		infof("✅ train job %s done in %.1fs (loss %.4f)", job.ID, now.Sub(job.StartedAt).Seconds(), job.Loss)
+	}
+	return *job, err
+}
+
+// trainSnapshot trains a CPU copy of snap on the first nTrain samples and
+// writes the result back into snap.state, starting after job.Epoch. The next
+// nVal samples are held out and scored after every epoch for early stopping.
+// Paragon updates weights per sample; batch_size only sets how often progress
+// is published.
+func trainSnapshot(ctx context.Context, job *TrainJob, snap *modelSnapshot, images, labels *idxFile, nTrain, nVal int) error {
+	nn, err := networkFromSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	in, err := inputShapeFor(snap.shapes[0])
+	if err != nil {
+		return err
+	}
+	out := snap.shapes[len(snap.shapes)-1]
+	// sample loads sample i, augmented when rng is set.
+	sample := func(i int, rng *rand.Rand) ([][]float64, int, error) {
+		img, err := images.Image(i)
+		if err != nil {
+			return nil, 0, fmt.Errorf("image %d: %w", i, err)
+		}
+		lbl, err := labels.Label(i)
+		if err != nil {
+			return nil, 0, fmt.Errorf("label %d: %w", i, err)
+		}
+		if rng != nil {
+			img = job.Params.Augment.apply(img, rng)
+		}
+		if in.Flat {
+			img = flatten(img)
+		}
+		return img, lbl, nil
+	}
+	// validate returns the mean loss and accuracy on the held-out samples.
+	validate := func() (float64, float64, error) {
+		var loss float64
+		correct := 0
+		for i := nTrain; i < nTrain+nVal; i++ {
+			img, lbl, err := sample(i, nil)
+			if err != nil {
+				return 0, 0, err
+			}
+			nn.Forward(img)
+			loss += nn.ComputeLoss(oneHot(lbl, out.Width, out.Height))
+			if argmax(nn.ExtractOutput()) == lbl {
+				correct++
+			}
+		}
+		return loss / float64(nVal), float64(correct) / float64(nVal), nil
+	}
+
+	trainMu.Lock()
+	first := job.Epoch + 1
+	trainMu.Unlock()
+	var (
+		best      = math.Inf(1)
+		bestState []byte
+		wait      int
+	)
+	for epoch := first; epoch <= job.Epochs; epoch++ {
+		lr := job.Params.lrAt(epoch)
+		order := job.Params.order(epoch, nTrain)
+		rng := job.Params.augmentRand(epoch)
+		trainMu.Lock()
+		job.LearningRate = round6(lr)
+		trainMu.Unlock()
+		for b, lo := 0, 0; lo < nTrain; b, lo = b+1, lo+job.BatchSize {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			hi := min(lo+job.BatchSize, nTrain)
+			inputs := make([][][]float64, 0, hi-lo)
+			targets := make([][][]float64, 0, hi-lo)
+			for _, i := range order[lo:hi] {
+				img, lbl, err := sample(i, rng)
+				if err != nil {
+					return err
+				}
+				inputs = append(inputs, img)
+				targets = append(targets, oneHot(lbl, out.Width, out.Height))
+			}
+			nn.train(inputs, targets, lr, job.Params.Clip)
+
+			var loss float64
+			for i := range inputs {
+				nn.Forward(inputs[i])
+				loss += nn.ComputeLoss(targets[i])
+			}
+			trainMu.Lock()
+			job.Epoch, job.Batch = epoch, b+1
+			job.Loss = round6(loss / float64(len(inputs)))
+			trainMu.Unlock()
+		}
+
+		improved := false
+		if nVal > 0 {
+			vloss, vacc, err := validate()
+			if err != nil {
+				return err
+			}
+			improved = vloss < best-job.Params.MinDelta
+			trainMu.Lock()
+			job.ValLoss, job.ValAccuracy = round6(vloss), round6(vacc)
+			if improved {
+				job.BestEpoch, job.BestValLoss = epoch, round6(vloss)
+			}
+			trainMu.Unlock()
+			if improved {
+				best, wait = vloss, 0
+				if bestState, err = nn.MarshalJSONModel(); err != nil {
+					return err
+				}
+			} else {
+				wait++
+			}
+			debugf("train job %s epoch %d/%d loss %.4f val_loss %.4f val_acc %.4f", job.ID, epoch, job.Epochs, job.Loss, vloss, vacc)
+		} else {
+			debugf("train job %s epoch %d/%d loss %.4f", job.ID, epoch, job.Epochs, job.Loss)
+		}
+
+		if checkpointDir != "" && (improved || epoch%checkpointEvery == 0 || epoch == job.Epochs) {
+			trainMu.Lock()
+			cp := *job
+			trainMu.Unlock()
+			if id, err := saveCheckpoint(cp, epoch, nn); err != nil {
+				warnf("train job %s: checkpoint after epoch %d failed: %v", job.ID, epoch, err)
+			} else {
+				trainMu.Lock()
+				job.Checkpoint = id
+				if improved {
+					job.BestCheckpoint = id
+				}
+				trainMu.Unlock()
+			}
+		}
+		if p := job.Params.Patience; p > 0 && wait >= p {
+			trainMu.Lock()
+			job.StoppedEarly = true
+			trainMu.Unlock()
+			infof("⏹️  train job %s stopped after epoch %d: no val_loss improvement in %d epochs (best %.4f at epoch %d)", job.ID, epoch, p, best, job.BestEpoch)
+			break
+		}
+	}
+	if job.Params.RestoreBest && bestState != nil {
+		snap.state = bestState
+		return nil
+	}
+	snap.state, err = nn.MarshalJSONModel()
+	return err
+}
+
+// swapTrained serves the trained weights unless the model was replaced
+// (reload/reset/new) while training ran.
+func swapTrained(base *ParagonHandle, snap *modelSnapshot) error {
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	if cur, _, _ := currentHandles(); cur != base {
+		return fmt.Errorf("model changed during training; result discarded")
+	}
+	cpu, gpu, ok, err := handlesFromSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	swapModels(cpu, gpu, ok)
+	return nil
+}
+
+func oneHot(label, w, h int) [][]float64 {
+	t := make([][]float64, h)
+	for y := range t {
+		t[y] = make([]float64, w)
+	}
+	t[label/w][label%w] = 1
+	return t
+}
+
+func handleTrainStatus(w http.ResponseWriter, r *http.Request) {
+	j := lookupJob(r.PathValue("id"), "train")
+	if j == nil {
+		http.Error(w, "unknown train job", http.StatusNotFound)
+		return
+	}
+	job := j.state.(*TrainJob)
+	trainMu.Lock()
+	cp := *job
+	trainMu.Unlock()
+	if wantsSSE(r) {
+		streamTrainJob(w, r, job)
+		return
+	}
+	writeJSON(w, http.StatusOK, cp)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const maxUploadBytes = 2 << 20 // 2 MB
+
+// readUploadImage returns the image bytes of a request, either from multipart
+// field "file" or from a raw image body, bounded by maxUploadBytes.
+func readUploadImage(w http.ResponseWriter, r *http.Request) ([]byte, string, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	name := "upload"
+	var data []byte
+	ct := r.Header.Get("Content-Type")
+	switch {
+	case strings.HasPrefix(ct, "multipart/form-data"):
+		if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
+			return nil, "", uploadError(err)
+		}
+		f, hdr, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", newHTTPError(http.StatusBadRequest, "missing multipart field \"file\"")
+		}
+		defer f.Close()
+		if hdr.Filename != "" {
+			name = hdr.Filename
+		}
+		if data, err = io.ReadAll(f); err != nil {
+			return nil, "", uploadError(err)
+		}
+	case strings.HasPrefix(ct, "image/"), ct == "application/octet-stream":
+		var err error
+		if data, err = io.ReadAll(r.Body); err != nil {
+			return nil, "", uploadError(err)
+		}
+	default:
+		return nil, "", newHTTPError(http.StatusUnsupportedMediaType, "send multipart/form-data (field \"file\") or an image/png, image/jpeg, image/bmp or image/webp body")
+	}
+	if len(data) == 0 {
+		return nil, "", newHTTPError(http.StatusBadRequest, "empty upload")
+	}
+	if err := checkImageFormat(data); err != nil {
+		return nil, "", err
+	}
+	return data, name, nil
+}
+
+// decodeImageB64 decodes a base64 image as sent by canvas clients; a
+// "data:image/png;base64," (or jpeg/bmp/webp) prefix is accepted and stripped.
+func decodeImageB64(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "data:") {
+		i := strings.Index(s, ",")
+		if i < 0 || !strings.HasSuffix(s[:i], ";base64") {
+			return nil, newStageError(stageDecode, http.StatusBadRequest, "image_b64: malformed data URL")
+		}
+		s = s[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, newStageError(stageDecode, http.StatusBadRequest, "image_b64: "+err.Error())
+	}
+	if len(data) > maxUploadBytes {
+		return nil, newStageError(stageDecode, http.StatusRequestEntityTooLarge, "image_b64 exceeds 2 MB")
+	}
+	if err := checkImageFormat(data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// checkImageFormat rejects bytes that are not a supported image before they
+// reach the decoder or the caches.
+func checkImageFormat(data []byte) error {
+	if imageFormat(data) == "" {
+		return newStageError(stageDecode, http.StatusBadRequest, "not a PNG, JPEG, BMP or WebP image")
+	}
+	return nil
+}
+
+func uploadError(err error) error {
+	var mbe *http.MaxBytesError
+	if errors.As(err, &mbe) {
+		return newHTTPError(http.StatusRequestEntityTooLarge, "upload exceeds 2 MB")
+	}
+	return newHTTPError(http.StatusBadRequest, "bad upload: "+err.Error())
+}
+
+func handlePredictUpload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, name, err := readUploadImage(w, r)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	backend := strings.TrimSpace(r.URL.Query().Get("backend"))
+	if backend == "" {
+		backend = r.FormValue("backend")
+	}
+	opts, err := optsFromQuery(r.URL.Query())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	res, err := predictData(r.Context(), data, name, backend, opts)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, res)
+}
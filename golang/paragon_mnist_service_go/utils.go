@@ -2,8 +2,9 @@ package main
 
 import (
 	"compress/gzip"
-	"encoding/binary"
+	"context"
 	"errors"
+	"fmt"
 	"image"
 	"image/color"
 	"image/png"
@@ -14,16 +15,52 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const (
-	mnistBase   = "https://storage.googleapis.com/cvdf-datasets/mnist/"
 	trainImgsGZ = "train-images-idx3-ubyte.gz"
 	trainLabsGZ = "train-labels-idx1-ubyte.gz"
+	testImgsGZ  = "t10k-images-idx3-ubyte.gz"
+	testLabsGZ  = "t10k-labels-idx1-ubyte.gz"
 )
 
 func getEnv(k, def string) string {
-	if v := os.Getenv(k); v != "" {
+	if v := lookupSetting(k); v != "" {
+		return v
+	}
+	return def
+}
+
+func getEnvBool(k string, def bool) bool {
+	v := strings.ToLower(strings.TrimSpace(lookupSetting(k)))
+	switch v {
+	case "1", "true", "yes", "on":
+		return true
+	case "0", "false", "no", "off":
+		return false
+	}
+	return def
+}
+
+func getEnvInt(k string, def int) int {
+	if v, err := strconv.Atoi(strings.TrimSpace(lookupSetting(k))); err == nil {
+		return v
+	}
+	return def
+}
+
+// getEnvDuration parses a Go duration ("250ms", "1m"); negative or
+// malformed values fall back to def.
+func getEnvDuration(k string, def time.Duration) time.Duration {
+	if v, err := time.ParseDuration(strings.TrimSpace(lookupSetting(k))); err == nil && v >= 0 {
+		return v
+	}
+	return def
+}
+
+func getEnvFloat(k string, def float64) float64 {
+	if v, err := strconv.ParseFloat(strings.TrimSpace(lookupSetting(k)), 64); err == nil {
 		return v
 	}
 	return def
@@ -44,14 +81,21 @@ func fileExists(path string) (bool, error) {
 	return false, err
 }
 
-func downloadFile(url, outPath string) error {
+// downloadFile fetches url to outPath unless it exists. The body goes to a
+// temporary file first, so a canceled or failed download leaves nothing
+// behind that would pass for the real file.
+func downloadFile(ctx context.Context, url, outPath string) error {
 	if ok, _ := fileExists(outPath); ok {
 		return nil
 	}
 	if err := ensureDir(filepath.Dir(outPath)); err != nil {
 		return err
 	}
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -59,13 +103,41 @@ func downloadFile(url, outPath string) error {
 	if resp.StatusCode != 200 {
 		return errors.New(resp.Status)
 	}
-	f, err := os.Create(outPath)
+	f, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	_, err = io.Copy(f, resp.Body)
-	return err
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), outPath)
+}
+
+// writeGzipped decompresses r into outPath through a temporary file, so a
+// failed write never leaves a truncated file behind.
+func writeGzipped(outPath string, r io.Reader) error {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(outPath), filepath.Base(outPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := io.Copy(f, gr); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), outPath)
 }
 
 func unzipGZToFile(gzPath, rawPath string) error {
@@ -91,6 +163,15 @@ func unzipGZToFile(gzPath, rawPath string) error {
 	return err
 }
 
+// SAMPLES_PER_CLASS (default 1) sets how many images per class
+// autopopulateImages exports, and SAMPLES_SPLIT=train|test (default train)
+// which split they come from. One sample per class keeps the "<label>.png"
+// names; otherwise files are "<label>_<index>.png" with the split index.
+var (
+	samplesPerClass = max(getEnvInt("SAMPLES_PER_CLASS", 1), 1)
+	samplesSplit    = strings.ToLower(getEnv("SAMPLES_SPLIT", "train"))
+)
+
 func autopopulateImages() error {
 	// if any PNG already exists, skip
 	entries, _ := os.ReadDir(imagesDir)
@@ -99,118 +180,116 @@ func autopopulateImages() error {
 			return nil
 		}
 	}
-	// download + extract MNIST idx files
-	mnistDir := "./mnist_idx"
-	if err := ensureDir(mnistDir); err != nil {
-		return err
-	}
-
-	imgGZ := filepath.Join(mnistDir, trainImgsGZ)
-	labGZ := filepath.Join(mnistDir, trainLabsGZ)
-	if err := downloadFile(mnistBase+trainImgsGZ, imgGZ); err != nil {
-		return err
-	}
-	if err := downloadFile(mnistBase+trainLabsGZ, labGZ); err != nil {
-		return err
-	}
-
-	imgRaw := filepath.Join(mnistDir, "train-images-idx3-ubyte")
-	labRaw := filepath.Join(mnistDir, "train-labels-idx1-ubyte")
-	if err := unzipGZToFile(imgGZ, imgRaw); err != nil {
-		return err
+	ensure := ensureTrainIDX
+	switch samplesSplit {
+	case "train":
+	case "test":
+		ensure = ensureTestIDX
+	default:
+		return fmt.Errorf("SAMPLES_SPLIT=%q: want train or test", samplesSplit)
 	}
-	if err := unzipGZToFile(labGZ, labRaw); err != nil {
+	imgRaw, labRaw, err := ensure()
+	if err != nil {
 		return err
 	}
 
-	images, err := readImagesIDX(imgRaw)
+	images, err := cachedIDX(imgRaw, idxMagicImages)
 	if err != nil {
 		return err
 	}
-	labels, err := readLabelsIDX(labRaw)
+	labels, err := cachedIDX(labRaw, idxMagicLabels)
 	if err != nil {
 		return err
 	}
 
-	seen := map[int]bool{}
-	for i := 0; i < len(images) && len(seen) < 10; i++ {
-		lbl := labels[i]
-		if seen[lbl] {
+	counts := map[int]int{}
+	done := 0
+	for i := 0; i < images.Len() && done < numClasses; i++ {
+		lbl, err := labels.Label(i)
+		if err != nil {
+			return err
+		}
+		if counts[lbl] >= samplesPerClass {
 			continue
 		}
-		if err := writePNG28x28(filepath.Join(imagesDir, strconv.Itoa(lbl)+".png"), images[i]); err != nil {
+		img, err := images.Image(i)
+		if err != nil {
 			return err
 		}
-		seen[lbl] = true
+		name := strconv.Itoa(lbl) + ".png"
+		if samplesPerClass > 1 {
+			name = fmt.Sprintf("%d_%05d.png", lbl, i)
+		}
+		if err := writePNG(filepath.Join(imagesDir, name), img); err != nil {
+			return err
+		}
+		if counts[lbl]++; counts[lbl] == samplesPerClass {
+			done++
+		}
 	}
+	infof("🖼️  exported %d %s samples per class to %s", samplesPerClass, samplesSplit, imagesDir)
 	return nil
 }
 
-func readImagesIDX(path string) ([][][]float64, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
+// ensureTrainIDX downloads and extracts the training IDX files of DATASET if
+// they are not already present, returning the raw image and label paths.
+func ensureTrainIDX() (string, string, error) {
+	return ensureIDXPair(trainImgsGZ, trainLabsGZ)
+}
 
-	var head [16]byte
-	if _, err := io.ReadFull(f, head[:]); err != nil {
-		return nil, err
+// ensureTestIDX is ensureTrainIDX for the 10k test split.
+func ensureTestIDX() (string, string, error) {
+	return ensureIDXPair(testImgsGZ, testLabsGZ)
+}
+
+func ensureIDXPair(imgsGZ, labsGZ string) (string, string, error) {
+	if err := ensureDir(activeDataset.dir); err != nil {
+		return "", "", err
+	}
+	imgRaw, err := ensureIDXFile(context.Background(), imgsGZ)
+	if err != nil {
+		return "", "", err
 	}
-	magic := binary.BigEndian.Uint32(head[0:4])
-	if magic != 2051 {
-		return nil, errors.New("bad magic for images")
+	labRaw, err := ensureIDXFile(context.Background(), labsGZ)
+	if err != nil {
+		return "", "", err
 	}
-	num := int(binary.BigEndian.Uint32(head[4:8]))
-	rows := int(binary.BigEndian.Uint32(head[8:12]))
-	cols := int(binary.BigEndian.Uint32(head[12:16]))
+	return imgRaw, labRaw, nil
+}
 
-	images := make([][][]float64, num)
-	buf := make([]byte, rows*cols)
-	for i := 0; i < num; i++ {
-		if _, err := io.ReadFull(f, buf); err != nil {
-			return nil, err
+func ensureIDXFile(ctx context.Context, gzName string) (string, error) {
+	gz := filepath.Join(activeDataset.dir, gzName)
+	raw := filepath.Join(activeDataset.dir, strings.TrimSuffix(gzName, ".gz"))
+	if ok, _ := fileExists(raw); !ok {
+		if err := downloadFile(ctx, activeDataset.baseURL+gzName, gz); err != nil {
+			return "", err
 		}
-		img := make([][]float64, rows)
-		for r := 0; r < rows; r++ {
-			row := make([]float64, cols)
-			for c := 0; c < cols; c++ {
-				row[c] = float64(buf[r*cols+c]) / 255.0
-			}
-			img[r] = row
+		if err := unzipGZToFile(gz, raw); err != nil {
+			return "", err
 		}
-		images[i] = img
 	}
-	return images, nil
+	return raw, nil
 }
 
-func readLabelsIDX(path string) ([]int, error) {
-	f, err := os.Open(path)
+// readImageIDXAt reads a single image from an IDX3 file without loading the rest.
+func readImageIDXAt(path string, index int) ([][]float64, error) {
+	x, err := cachedIDX(path, idxMagicImages)
 	if err != nil {
 		return nil, err
 	}
-	defer f.Close()
-	var head [8]byte
-	if _, err := io.ReadFull(f, head[:]); err != nil {
-		return nil, err
-	}
-	magic := binary.BigEndian.Uint32(head[0:4])
-	if magic != 2049 {
-		return nil, errors.New("bad magic for labels")
-	}
-	num := int(binary.BigEndian.Uint32(head[4:8]))
-	labels := make([]int, num)
-	b := make([]byte, 1)
-	for i := 0; i < num; i++ {
-		if _, err := io.ReadFull(f, b); err != nil {
-			return nil, err
-		}
-		labels[i] = int(b[0])
+	return x.Image(index)
+}
+
+// readLabelIDXAt reads a single label from an IDX1 file.
+func readLabelIDXAt(path string, index int) (int, error) {
+	x, err := cachedIDX(path, idxMagicLabels)
+	if err != nil {
+		return 0, err
 	}
-	return labels, nil
+	return x.Label(index)
 }
 
-func writePNG28x28(outPath string, img [][]float64) error {
+func writePNG(outPath string, img [][]float64) error {
 	if err := ensureDir(filepath.Dir(outPath)); err != nil {
 		return err
 	}
@@ -231,56 +310,122 @@ func writePNG28x28(outPath string, img [][]float64) error {
 	return png.Encode(f, gray)
 }
 
-func loadPNG28x28(path string) ([][]float64, error) {
+// loadImageToInput decodes a PNG, JPEG, BMP or WebP file to a w×h luminance grid in [0,1].
+func loadImageToInput(path string, w, h int) ([][]float64, error) {
 	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
-	im, err := png.Decode(f)
+	return decodeImageToInput(f, w, h)
+}
+
+// decodeImageToInput decodes an image stream into a w×h luminance grid in [0,1].
+func decodeImageToInput(r io.Reader, w, h int) ([][]float64, error) {
+	img, warn, err := decodeImageInput(r, w, h)
+	if warn != "" {
+		warnf("%s", warn)
+	}
+	return img, err
+}
+
+// decodeImageInput is decodeImageToInput that also returns a channel-conversion
+// warning for the caller to surface.
+func decodeImageInput(r io.Reader, w, h int) ([][]float64, string, error) {
+	im, err := decodeImage(r)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
+	warn, err := checkChannels(im)
+	if err != nil {
+		return nil, "", err
+	}
+	img, err := toGrid(im, w, h)
+	return img, warn, err
+}
+
+// the served model takes a single luminance plane; a color image is either
+// rejected or collapsed to luminance depending on CHANNEL_MISMATCH
+var channelMismatch = strings.ToLower(getEnv("CHANNEL_MISMATCH", "convert")) // "convert" | "reject"
+
+// imageChannels reports 1 for grayscale content (whatever the stored color type,
+// e.g. a canvas export stored as RGBA) and 3 when any pixel carries chroma.
+func imageChannels(im image.Image) int {
+	switch im.(type) {
+	case *image.Gray, *image.Gray16:
+		return 1
+	}
+	const tol = 2 * 257 // 2/255 in 16-bit space
 	b := im.Bounds()
-	w, h := b.Dx(), b.Dy()
-	if w != 28 || h != 28 {
-		// normalize to 28x28 if someone drops a different PNG in
-		dst := image.NewGray(image.Rect(0, 0, 28, 28))
-		// nearest-neighbor manual scale
-		for y := 0; y < 28; y++ {
-			for x := 0; x < 28; x++ {
-				sx := b.Min.X + x*w/28
-				sy := b.Min.Y + y*h/28
-				R, G, B, _ := im.At(sx, sy).RGBA()
-				Y := (0.2126*float64(R) + 0.7152*float64(G) + 0.0722*float64(B)) / 65535.0
-				dst.SetGray(x, y, color.Gray{Y: uint8(Y*255 + 0.5)})
-			}
-		}
-		// convert dst back to [][]float64
-		out := make([][]float64, 28)
-		for r := 0; r < 28; r++ {
-			row := make([]float64, 28)
-			for c := 0; c < 28; c++ {
-				row[c] = float64(dst.GrayAt(c, r).Y) / 255.0
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			R, G, B, _ := im.At(x, y).RGBA()
+			if absDiff(R, G) > tol || absDiff(G, B) > tol {
+				return 3
 			}
-			out[r] = row
 		}
-		return out, nil
 	}
-	// exact 28x28
-	out := make([][]float64, 28)
-	for r := 0; r < 28; r++ {
-		row := make([]float64, 28)
-		for c := 0; c < 28; c++ {
+	return 1
+}
+
+func absDiff(a, b uint32) uint32 {
+	if a > b {
+		return a - b
+	}
+	return b - a
+}
+
+func checkChannels(im image.Image) (string, error) {
+	if imageChannels(im) == 1 {
+		return "", nil
+	}
+	if channelMismatch == "reject" {
+		return "", errors.New("image has 3 color channels but the model expects 1 (grayscale); convert it first or set CHANNEL_MISMATCH=convert")
+	}
+	return "color image converted to grayscale luminance for a 1-channel model", nil
+}
+
+// toGrid converts im to a w×h luminance grid, scaling with RESIZE_METHOD
+// when the source size differs.
+func toGrid(im image.Image, w, h int) ([][]float64, error) {
+	if w <= 0 || h <= 0 {
+		return nil, fmt.Errorf("model input %dx%d is not an image shape", w, h)
+	}
+	b := im.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	if sw == 0 || sh == 0 {
+		return nil, errors.New("empty image")
+	}
+	out := make([][]float64, sh)
+	for r := 0; r < sh; r++ {
+		row := make([]float64, sw)
+		for c := 0; c < sw; c++ {
 			R, G, B, _ := im.At(b.Min.X+c, b.Min.Y+r).RGBA()
 			Y := (0.2126*float64(R) + 0.7152*float64(G) + 0.0722*float64(B)) / 65535.0
 			row[c] = Y
 		}
 		out[r] = row
 	}
+	if sw != w || sh != h {
+		// normalize to the model input if someone drops in a different size
+		debugf("resizing %dx%d to %dx%d (%s)", sw, sh, w, h, resizeMethod)
+		out = scaleGrid(out, w, h)
+	}
 	return out, nil
 }
 
+// imagePath resolves name, as sent by a client, in IMAGES_DIR. Only plain
+// file names are accepted, so a request can't read outside the directory.
+func imagePath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || name == "." || name == ".." {
+		return "", newStageError(stageDecode, http.StatusBadRequest, fmt.Sprintf("%q must be a file name in IMAGES_DIR", name))
+	}
+	return filepath.Join(imagesDir, name), nil
+}
+
+// imageExts are the file types listImages serves from IMAGES_DIR.
+var imageExts = map[string]bool{".png": true, ".jpg": true, ".jpeg": true, ".bmp": true, ".webp": true}
+
 func listImages() ([]string, error) {
 	ents, err := os.ReadDir(imagesDir)
 	if err != nil {
@@ -291,7 +436,7 @@ func listImages() ([]string, error) {
 		if e.IsDir() {
 			continue
 		}
-		if filepath.Ext(stringsLower(e.Name())) == ".png" {
+		if imageExts[filepath.Ext(stringsLower(e.Name()))] {
 			out = append(out, e.Name())
 		}
 	}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestImagePath(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "../x.png", "../../etc/passwd", "a/b.png", "/etc/passwd"} {
+		if p, err := imagePath(name); err == nil {
+			t.Errorf("imagePath(%q) = %q, want an error", name, p)
+		} else if httpStatus(err) != 400 {
+			t.Errorf("imagePath(%q): status %d, want 400", name, httpStatus(err))
+		}
+	}
+	if p, err := imagePath("7.png"); err != nil || p != filepath.Join(imagesDir, "7.png") {
+		t.Errorf("imagePath(7.png) = %q, %v", p, err)
+	}
+}
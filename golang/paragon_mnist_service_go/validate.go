@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MAX_BODY_MB caps request bodies (default 8); model uploads to POST /models
+// are bounded by MODEL_UPLOAD_MAX_MB instead. Endpoints with tighter limits
+// (image uploads) still apply their own.
+var maxBodyBytes = int64(max(getEnvInt("MAX_BODY_MB", 8), 1)) << 20
+
+// bodyTypes are the media types any endpoint accepts; anything else gets 415
+// before reaching a handler.
+var bodyTypes = map[string]bool{
+	"application/json":         true,
+	"application/octet-stream": true,
+	"multipart/form-data":      true,
+	"text/csv":                 true,
+	"text/plain":               true,
+}
+
+// ValidationError is the JSON body of a request rejected for its size, type
+// or content.
+type ValidationError struct {
+	Error  string `json:"error"`
+	Field  string `json:"field,omitempty"` // JSON path of the offending field, e.g. "augment.shift"
+	Reason string `json:"reason"`          // "too_large" | "content_type" | "json" | "unknown_field" | "type" | "required" | "invalid"
+}
+
+// newFieldError is a 400 about one field of the request body.
+func newFieldError(field, reason, msg string) *httpError {
+	return &httpError{code: http.StatusBadRequest, msg: msg, field: field, reason: reason}
+}
+
+// writeError answers with err: a ValidationError body for field errors and
+// other 400s, plain text otherwise.
+func writeError(w http.ResponseWriter, err error) {
+	var he *httpError
+	if !errors.As(err, &he) || (he.reason == "" && he.code != http.StatusBadRequest) {
+		http.Error(w, err.Error(), httpStatus(err))
+		return
+	}
+	reason := he.reason
+	if reason == "" {
+		reason = "invalid"
+	}
+	writeJSON(w, he.code, ValidationError{Error: he.msg, Field: he.field, Reason: reason})
+}
+
+func bodyTooLarge(limit int64) *httpError {
+	return &httpError{code: http.StatusRequestEntityTooLarge, msg: fmt.Sprintf("request body exceeds %d bytes", limit), reason: "too_large"}
+}
+
+// bodyLimit is the most r may send.
+func bodyLimit(r *http.Request) int64 {
+	if r.Method == http.MethodPost && strings.TrimPrefix(r.URL.Path, apiPrefix) == "/models" {
+		return modelUploadMax
+	}
+	return maxBodyBytes
+}
+
+// withBodyLimits rejects bodies over their limit or of an unexpected media
+// type, and bounds what handlers can read.
+func withBodyLimits(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body == nil || r.Body == http.NoBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+		limit := bodyLimit(r)
+		if r.ContentLength > limit {
+			writeError(w, bodyTooLarge(limit))
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "" && r.ContentLength != 0 {
+			mt, _, err := mime.ParseMediaType(ct)
+			if err != nil || !(bodyTypes[mt] || strings.HasPrefix(mt, "image/") || strings.HasSuffix(mt, "+json")) {
+				writeError(w, &httpError{code: http.StatusUnsupportedMediaType, msg: "unsupported Content-Type " + ct, reason: "content_type"})
+				return
+			}
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// decodeJSON decodes the body of r into v. Unknown fields and trailing data
+// are rejected, and errors name the offending field where there is one.
+func decodeJSON(r *http.Request, v any) error {
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, _ := mime.ParseMediaType(ct); mt != "application/json" && !strings.HasSuffix(mt, "+json") {
+			return &httpError{code: http.StatusUnsupportedMediaType, msg: "Content-Type must be application/json", reason: "content_type"}
+		}
+	}
+	return decodeStrict(r.Body, v)
+}
+
+// decodeStrict is decodeJSON for a body already read or checked.
+func decodeStrict(body io.Reader, v any) error {
+	dec := json.NewDecoder(body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return jsonError(err)
+	}
+	if err := dec.Decode(&struct{}{}); err != io.EOF {
+		return newFieldError("", "json", "unexpected data after the JSON body")
+	}
+	return nil
+}
+
+// jsonError maps a json.Decoder error onto a ValidationError.
+func jsonError(err error) *httpError {
+	var (
+		tooLarge *http.MaxBytesError
+		typeErr  *json.UnmarshalTypeError
+		syntax   *json.SyntaxError
+	)
+	switch {
+	case errors.As(err, &tooLarge):
+		return bodyTooLarge(tooLarge.Limit)
+	case errors.As(err, &typeErr):
+		return newFieldError(typeErr.Field, "type", fmt.Sprintf("%s must be %s, not %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value))
+	case errors.As(err, &syntax):
+		return newFieldError("", "json", fmt.Sprintf("invalid JSON at offset %d: %v", syntax.Offset, err))
+	case errors.Is(err, io.EOF):
+		return newFieldError("", "required", "empty body, expected a JSON object")
+	}
+	// encoding/json has no type for this one
+	if f, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+		f = strings.Trim(f, `"`)
+		return newFieldError(f, "unknown_field", "unknown field "+f)
+	}
+	return newFieldError("", "json", "invalid JSON: "+err.Error())
+}
+
+// jsonTypeName names a Go kind the way a JSON client thinks of it.
+func jsonTypeName(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"):
+		return "an integer"
+	case strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "bool":
+		return "a boolean"
+	case kind == "string":
+		return "a string"
+	case kind == "slice", kind == "array":
+		return "an array"
+	}
+	return "an object"
+}
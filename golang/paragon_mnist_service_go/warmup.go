@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// warmupOpts says how GPU copies are warmed after init: Forwards inputs of
+// the given kind, shaped like the model's input layer. One zero image leaves
+// some pipeline variants uncompiled, so the defaults feed a few varied ones.
+type warmupOpts struct {
+	Forwards int    `json:"forwards"`
+	Input    string `json:"input"` // "zeros" | "noise" | "images" (IMAGES_DIR, noise if empty)
+}
+
+// WARMUP_FORWARDS / WARMUP_INPUT set the warmup run after GPU init and
+// re-init (default 3 forwards of noise).
+var warmupDefault = warmupOpts{
+	Forwards: max(getEnvInt("WARMUP_FORWARDS", 3), 1),
+	Input:    warmupInputKind(getEnv("WARMUP_INPUT", "noise")),
+}
+
+func warmupInputKind(s string) string {
+	switch k := strings.ToLower(strings.TrimSpace(s)); k {
+	case "zeros", "noise", "images":
+		return k
+	}
+	warnf("unknown WARMUP_INPUT=%q, using noise", s)
+	return "noise"
+}
+
+func (o warmupOpts) validate() error {
+	if o.Forwards < 1 || o.Forwards > 1000 {
+		return newFieldError("forwards", "invalid", "forwards must be 1..1000")
+	}
+	switch o.Input {
+	case "zeros", "noise", "images":
+		return nil
+	}
+	return newFieldError("input", "invalid", fmt.Sprintf("bad warmup input %q (want zeros, noise or images)", o.Input))
+}
+
+// warmupInputs builds o.Forwards inputs for a network whose first layer is
+// w×h; a flat (w*h,1) layer gets images flattened to match.
+func warmupInputs(w, h int, o warmupOpts) [][][]float64 {
+	in, err := inputShapeFor(struct{ Width, Height int }{w, h})
+	if err != nil {
+		in = inputShape{W: w, H: h}
+	}
+	var files []string
+	if o.Input == "images" {
+		files, _ = listImages()
+	}
+	out := make([][][]float64, o.Forwards)
+	for i := range out {
+		var img [][]float64
+		if len(files) > 0 {
+			img, _ = loadImageToInput(filepath.Join(imagesDir, files[i%len(files)]), in.W, in.H)
+		}
+		if img == nil {
+			img = make([][]float64, in.H)
+			for y := range img {
+				img[y] = make([]float64, in.W)
+				if o.Input == "zeros" {
+					continue
+				}
+				for x := range img[y] {
+					img[y][x] = rand.Float64()
+				}
+			}
+		}
+		if in.Flat {
+			img = flatten(img)
+		}
+		out[i] = img
+	}
+	return out
+}
+
+// warmupGPU runs the warmup forwards on one network so its pipelines are
+// compiled before real requests arrive.
+func warmupGPU(nn network, o warmupOpts) error {
+	shapes, _, _ := nn.topology()
+	if len(shapes) == 0 {
+		return errors.New("model has no layers")
+	}
+	for _, img := range warmupInputs(shapes[0].Width, shapes[0].Height, o) {
+		nn.Forward(img)
+		_ = nn.ExtractOutput()
+	}
+	return nil
+}
+
+// warmup re-runs the warmup on every GPU copy in h, waiting for in-flight
+// forwards; it returns how many copies were warmed.
+func (h *ParagonHandle) warmup(o warmupOpts) int {
+	nets := make([]network, h.size)
+	for i := range nets {
+		nets[i] = <-h.pool
+	}
+	defer func() {
+		for _, nn := range nets {
+			h.pool <- nn
+		}
+	}()
+	n := 0
+	for _, nn := range nets {
+		if nn.gpuNative() {
+			_ = warmupGPU(nn, o)
+			n++
+		}
+	}
+	return n
+}
+
+// handleWarmup re-runs the GPU warmup of a model (?model=, default MODEL_JSON)
+// on demand; the JSON body may override WARMUP_FORWARDS and WARMUP_INPUT.
+func handleWarmup(w http.ResponseWriter, r *http.Request) {
+	o := warmupDefault
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &o); err != nil {
+			writeError(w, err)
+			return
+		}
+		o.Input = strings.ToLower(strings.TrimSpace(o.Input))
+	}
+	if err := o.validate(); err != nil {
+		writeError(w, err)
+		return
+	}
+	name := strings.TrimSpace(r.URL.Query().Get("model"))
+	_, gpu, ok, err := modelHandles(name)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !ok || gpu == nil {
+		http.Error(w, "GPU backend not available", http.StatusServiceUnavailable)
+		return
+	}
+	adminMu.Lock()
+	defer adminMu.Unlock()
+	start := time.Now()
+	copies := gpu.warmup(o)
+	infof("🔥 GPU warmup: %d copies × %d %s forwards in %.2fs", copies, o.Forwards, o.Input, time.Since(start).Seconds())
+	writeJSON(w, http.StatusOK, map[string]any{
+		"ok":         true,
+		"copies":     copies,
+		"forwards":   o.Forwards,
+		"input":      o.Input,
+		"warmup_sec": round6(time.Since(start).Seconds()),
+	})
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Jobs (see /jobs) can take a callback_url that gets a JSON POST when the
+// job finishes, fails or is canceled. WEBHOOK_SECRET must be set: every delivery is
+// signed with it, and callbacks are refused without it. WEBHOOK_HOSTS is a
+// comma-separated allow list of callback hosts (empty = any). Failed
+// deliveries are retried WEBHOOK_RETRIES times with doubling backoff, each
+// attempt bounded by WEBHOOK_TIMEOUT.
+var (
+	webhookSecret  = getEnv("WEBHOOK_SECRET", "")
+	webhookHosts   = parseOrigins(getEnv("WEBHOOK_HOSTS", ""))
+	webhookRetries = max(getEnvInt("WEBHOOK_RETRIES", 3), 0)
+	webhookTimeout = getEnvDuration("WEBHOOK_TIMEOUT", 10*time.Second)
+)
+
+// webhookBackoff is the wait before the first retry.
+const webhookBackoff = time.Second
+
+// WebhookDelivery is the state of a job's callback, reported with the job.
+type WebhookDelivery struct {
+	URL         string     `json:"url"`
+	Status      string     `json:"status"` // "pending" | "delivered" | "failed"
+	Attempts    int        `json:"attempts"`
+	LastError   string     `json:"last_error,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+}
+
+// checkCallbackURL rejects callbacks that can't or mustn't be delivered.
+func checkCallbackURL(raw string) error {
+	if webhookSecret == "" {
+		return newHTTPError(http.StatusBadRequest, "callbacks disabled (set WEBHOOK_SECRET)")
+	}
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return newHTTPError(http.StatusBadRequest, "callback_url must be an absolute http(s) URL")
+	}
+	if len(webhookHosts) > 0 && !webhookHosts[u.Hostname()] {
+		return newHTTPError(http.StatusBadRequest, "callback host "+u.Hostname()+" not in WEBHOOK_HOSTS")
+	}
+	return nil
+}
+
+// signWebhook is the X-Paragon-Signature of body sent at ts: an HMAC-SHA256
+// over "<ts>.<body>" with WEBHOOK_SECRET, so receivers can check both the
+// sender and that the timestamp wasn't altered.
+func signWebhook(ts int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	fmt.Fprintf(mac, "%d.", ts)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// sendWebhook delivers {"event", "sent_at", "data"} to callback in the
+// background, retrying failures, and reports progress through update after
+// every attempt.
+func sendWebhook(callback, event string, data any, update func(WebhookDelivery)) {
+	d := WebhookDelivery{URL: callback, Status: "pending"}
+	body, err := json.Marshal(map[string]any{"event": event, "sent_at": time.Now().UTC(), "data": data})
+	if err != nil {
+		d.Status, d.LastError = "failed", err.Error()
+		update(d)
+		return
+	}
+	go func() {
+		client := &http.Client{Timeout: webhookTimeout}
+		wait := webhookBackoff
+		for d.Attempts <= webhookRetries {
+			if d.Attempts > 0 {
+				time.Sleep(wait)
+				wait *= 2
+			}
+			d.Attempts++
+			err := postWebhook(client, callback, event, body)
+			if err == nil {
+				now := time.Now().UTC()
+				d.Status, d.LastError, d.DeliveredAt = "delivered", "", &now
+				update(d)
+				debugf("webhook %s delivered to %s", event, callback)
+				return
+			}
+			d.LastError = err.Error()
+			if d.Attempts > webhookRetries {
+				d.Status = "failed"
+			}
+			update(d)
+		}
+		warnf("webhook %s to %s failed after %d attempts: %s", event, callback, d.Attempts, d.LastError)
+	}()
+}
+
+func postWebhook(client *http.Client, callback, event string, body []byte) error {
+	req, err := http.NewRequest(http.MethodPost, callback, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Paragon-Event", event)
+	req.Header.Set("X-Paragon-Timestamp", strconv.FormatInt(ts, 10))
+	req.Header.Set("X-Paragon-Signature", signWebhook(ts, body))
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("callback answered %s", resp.Status)
+	}
+	return nil
+}
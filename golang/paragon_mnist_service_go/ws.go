@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/coder/websocket"
+)
+
+// /ws keeps one WebSocket open per client. Each text message is a JSON
+// prediction request; replies come back as they finish, tagged with the
+// request's id, so a drawing UI can predict on every stroke without paying
+// for a new HTTP request each time.
+//
+//	→ {"id":"s1","image_b64":"...","backend":"cpu","topk":3}
+//	→ {"id":"s2","tensor":[0,0.1,...],"model":"v2"}
+//	← {"id":"s1","prediction":7,"probabilities":[...],...}
+//	← {"id":"s2","error":"...","stage":"decode"}
+var (
+	wsMaxInflight = getEnvInt("WS_MAX_INFLIGHT", 4) // concurrent predictions per connection
+	wsMaxMessage  = int64(getEnvInt("WS_MAX_KB", 256)) << 10
+)
+
+type WSPredictRequest struct {
+	ID       json.RawMessage `json:"id,omitempty"` // echoed back verbatim
+	Image    string          `json:"image"`        // file in IMAGES_DIR
+	ImageB64 string          `json:"image_b64"`    // base64 PNG/JPEG/BMP/WebP or data URL
+	Tensor   json.RawMessage `json:"tensor"`       // HxW rows or flat W*H, values in [0,1]
+	Backend  string          `json:"backend"`
+	RawProbs bool            `json:"raw_probs"`
+	TopK     int             `json:"topk"`
+	Model    string          `json:"model"`
+
+	Preprocess Preprocess `json:"preprocess"`
+}
+
+func handleWS(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if o := r.Header.Get("Origin"); o != "" && !corsOrigins["*"] && !corsOrigins[o] && !sameOrigin(o, r.Host) {
+		http.Error(w, "origin not allowed", http.StatusForbidden)
+		return
+	}
+	// The origin was checked above against CORS_ORIGINS, which the library's
+	// host patterns can't express.
+	c, err := websocket.Accept(w, r, &websocket.AcceptOptions{InsecureSkipVerify: true})
+	if err != nil {
+		return // Accept has answered the client
+	}
+	defer c.CloseNow()
+	c.SetReadLimit(wsMaxMessage)
+
+	// The request context ends when the handler returns; predictions that are
+	// still running then are abandoned.
+	ctx, cancel := context.WithCancel(context.WithoutCancel(r.Context()))
+	defer cancel()
+	infof("🔌 websocket open %s", clientIP(r))
+	served := serveWS(ctx, c, requestID(r.Context()), clientIP(r))
+	infof("🔌 websocket closed %s after %d messages", clientIP(r), served)
+}
+
+// serveWS answers messages on c until it closes. Each message gets its own
+// requestInfo, with an ID of connID-n, since its prediction runs alongside
+// others from the same connection, and counts against the client's rate
+// limit like an HTTP prediction.
+func serveWS(ctx context.Context, c *websocket.Conn, connID, client string) int {
+	sem := make(chan struct{}, max(wsMaxInflight, 1))
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	n := 0
+	for {
+		typ, msg, err := c.Read(ctx)
+		if err != nil {
+			if websocket.CloseStatus(err) == -1 && !errors.Is(err, context.Canceled) {
+				debugf("websocket read: %v", err)
+			}
+			return n
+		}
+		if typ != websocket.MessageText {
+			c.Close(websocket.StatusUnsupportedData, "send JSON text messages")
+			return n
+		}
+		n++
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			return n
+		}
+		mctx := context.WithValue(ctx, requestInfoKey{}, &requestInfo{id: fmt.Sprintf("%s-%d", connID, n)})
+		wg.Add(1)
+		go func() {
+			defer func() { <-sem; wg.Done() }()
+			writeWSJSON(mctx, c, wsPredict(mctx, client, msg))
+		}()
+	}
+}
+
+func wsPredict(ctx context.Context, client string, msg []byte) map[string]any {
+	var req WSPredictRequest
+	if err := json.Unmarshal(msg, &req); err != nil {
+		return map[string]any{"error": "invalid JSON"}
+	}
+	if ok, wait := limiter.allow(client); !ok {
+		res := map[string]any{"error": "rate limit exceeded", "retry_after": retryAfter(wait)}
+		if len(req.ID) > 0 {
+			res["id"] = req.ID
+		}
+		return res
+	}
+	res, err := wsRun(ctx, req)
+	if err != nil {
+		res = map[string]any{"error": err.Error()}
+		var he *httpError
+		if errors.As(err, &he) && he.stage != "" {
+			res["stage"] = he.stage
+		}
+	}
+	if len(req.ID) > 0 {
+		res["id"] = req.ID
+	}
+	return res
+}
+
+func wsRun(ctx context.Context, req WSPredictRequest) (map[string]any, error) {
+	opts, err := predictOpts{RawProbs: req.RawProbs, TopK: req.TopK, Model: strings.TrimSpace(req.Model), Pre: req.Preprocess}.normalize()
+	if err != nil {
+		return nil, err
+	}
+	if requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, requestTimeout)
+		defer cancel()
+	}
+	switch {
+	case len(req.Tensor) > 0 && string(req.Tensor) != "null":
+		cpu, _, _, err := modelHandles(opts.Model)
+		if err != nil {
+			return nil, err
+		}
+		img, err := parseTensor(req.Tensor, cpu.Input())
+		if err != nil {
+			return nil, err
+		}
+		return predictTensor(img, "tensor", req.Backend, opts)
+	case strings.TrimSpace(req.ImageB64) != "":
+		data, err := decodeImageB64(req.ImageB64)
+		if err != nil {
+			return nil, err
+		}
+		return predictData(ctx, data, "image_b64", req.Backend, opts)
+	case strings.TrimSpace(req.Image) != "":
+		return predictCore(ctx, strings.TrimSpace(req.Image), req.Backend, opts)
+	}
+	return nil, newStageError(stageDecode, http.StatusBadRequest, "missing image, image_b64 or tensor")
+}
+
+// writeWSJSON sends v as one text message; Conn serializes concurrent writes.
+func writeWSJSON(ctx context.Context, c *websocket.Conn, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		b, _ = json.Marshal(map[string]any{"error": "encode: " + err.Error()})
+	}
+	if err := c.Write(ctx, websocket.MessageText, b); err != nil {
+		debugf("websocket write: %v", err)
+	}
+}
+
+// sameOrigin lets pages served from this host open /ws without a CORS entry.
+func sameOrigin(origin, host string) bool {
+	_, rest, ok := strings.Cut(origin, "://")
+	return ok && strings.EqualFold(rest, host)
+}
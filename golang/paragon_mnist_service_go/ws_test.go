@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+// TestWSConcurrentMessages sends several predictions at once over one
+// connection; run with -race to check messages don't share request state.
+func TestWSConcurrentMessages(t *testing.T) {
+	useTestModel(t)
+	srv := httptest.NewServer(withRequestLog(http.HandlerFunc(handleWS)))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	const n = 8
+	for i := range n {
+		msg := fmt.Sprintf(`{"id":%d,"image_b64":%q,"backend":"cpu"}`, i, base64.StdEncoding.EncodeToString(testDigit(t, 2+2*i)))
+		if err := c.Write(ctx, websocket.MessageText, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	seen := map[int]bool{}
+	for range n {
+		_, b, err := c.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res struct {
+			ID         int    `json:"id"`
+			Prediction *int   `json:"prediction"`
+			Error      string `json:"error"`
+		}
+		if err := json.Unmarshal(b, &res); err != nil {
+			t.Fatal(err)
+		}
+		if res.Error != "" || res.Prediction == nil {
+			t.Fatalf("message %d: %s", res.ID, b)
+		}
+		seen[res.ID] = true
+	}
+	if len(seen) != n {
+		t.Fatalf("got replies for %v, want ids 0..%d", seen, n-1)
+	}
+
+	if err := c.Write(ctx, websocket.MessageBinary, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Read(ctx); websocket.CloseStatus(err) != websocket.StatusUnsupportedData {
+		t.Fatalf("binary message: got %v, want close 1003", err)
+	}
+}
+
+func TestWSRateLimit(t *testing.T) {
+	useTestModel(t)
+	useTestLimiter(t, 0.001, 1)
+	srv := httptest.NewServer(http.HandlerFunc(handleWS))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	c, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(srv.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.CloseNow()
+
+	msg := fmt.Sprintf(`{"id":"a","image_b64":%q,"backend":"cpu"}`, base64.StdEncoding.EncodeToString(testDigit(t, 10)))
+	var errs []string
+	for range 2 {
+		if err := c.Write(ctx, websocket.MessageText, []byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		_, b, err := c.Read(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var res struct {
+			ID    string `json:"id"`
+			Error string `json:"error"`
+		}
+		if err := json.Unmarshal(b, &res); err != nil || res.ID != "a" {
+			t.Fatalf("reply %s: %v", b, err)
+		}
+		errs = append(errs, res.Error)
+	}
+	if errs[0] != "" || errs[1] != "rate limit exceeded" {
+		t.Fatalf("errors = %q, want second message limited", errs)
+	}
+}